@@ -1,6 +0,0 @@
-/*
-Package bpool implements leaky pools of byte arrays and Buffers as bounded
-channels.  It is based on the leaky buffer example from the Effective Go
-documentation: http://golang.org/doc/effective_go.html#leaky_buffer
-*/
-package bpool
@@ -1,45 +0,0 @@
-package bpool
-
-// BytePool implements a leaky pool of []byte in the form of a bounded
-// channel.
-type BytePool struct {
-	c chan []byte
-	w int
-}
-
-// NewBytePool creates a new BytePool bounded to the given maxSize, with new
-// byte arrays sized based on width.
-func NewBytePool(maxSize int, width int) (bp *BytePool) {
-	return &BytePool{
-		c: make(chan []byte, maxSize),
-		w: width,
-	}
-}
-
-// Get gets a []byte from the BytePool, or creates a new one if none are
-// available in the pool.
-func (bp *BytePool) Get() (b []byte) {
-	select {
-	case b = <-bp.c:
-	// reuse existing buffer
-	default:
-		// create new buffer
-		b = make([]byte, bp.w)
-	}
-	return
-}
-
-// Put returns the given Buffer to the BytePool.
-func (bp *BytePool) Put(b []byte) {
-	select {
-	case bp.c <- b:
-		// buffer went back into pool
-	default:
-		// buffer didn't go back into pool, just discard
-	}
-}
-
-// Width returns the width of the byte arrays in this pool.
-func (bp *BytePool) Width() (n int) {
-	return bp.w
-}
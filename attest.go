@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/utils"
+	"io/ioutil"
+	"os"
+)
+
+// Wrap standard input in a DSSE envelope signed with the same keys as the
+// message, and upload it
+func attest(name string, title string, payloadType string, opts utils.SignOptions) {
+	r := &report{Command: "attest", Login: name, Title: title}
+
+	payload, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fail(r, exitError, "error", "Error reading from standard input: %s", err.Error())
+	}
+
+	sigs, err := utils.SignWith(name, models.PAE(payloadType, payload), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	envelope := models.Envelope{PayloadType: payloadType, Payload: base64.StdEncoding.EncodeToString(payload)}
+	for _, sig := range sigs {
+		envSig, err := models.NewEnvelopeSignature(sig)
+		if err != nil {
+			failWith(r, err)
+		}
+		envelope.Signatures = append(envelope.Signatures, envSig)
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	b64Data := base64.StdEncoding.EncodeToString(data)
+	contentType := models.ContentTypeDSSE
+	message := models.Message{Version: models.SignedDataV2, GithubLogin: &name, Blob: &b64Data, Title: &title, RawBlob: data, ContentType: &contentType}
+
+	message.Signatures, err = utils.SignWith(name, message.SignedData(), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(message.Signatures)
+
+	created, err := apiClient().CreateMessage(context.Background(), &message)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	r.setMessage(created)
+	succeed(r, "Attestation %d %q signed by %s with %d signatures\n%s\n", *created.ID, title, name, len(envelope.Signatures), r.URL)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/sshkeys"
+	"github.com/andrewhamon/signist/utils"
+	"io/ioutil"
+	"time"
+)
+
+// Trust the certificate authority whose public key is in keyFile to certify
+// name's keys
+func addCertificateAuthority(name string, keyFile string, opts utils.SignOptions) {
+	r := &report{Command: "cas add", Login: name}
+
+	keyBytes, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		fail(r, exitError, "error", "Error reading %s: %s", keyFile, err.Error())
+	}
+
+	key, _, _, _, err := sshkeys.ParseAuthorizedKey(keyBytes)
+	if err != nil {
+		fail(r, exitError, "error", "Could not parse a public key from %s: %s", keyFile, err.Error())
+	}
+
+	// Leave time for signing with a security key before the request expires
+	expiresAt := time.Now().Add(models.MaxRequestValidity / 2).Truncate(time.Second)
+
+	sigs, err := utils.SignWith(name, models.CertificateAuthorityData(name, key, expiresAt), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(sigs)
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	ca := models.CertificateAuthority{GithubLogin: &name, Key: &models.PublicKey{PublicKey: key}, ExpiresAt: &expiresAt, Signatures: sigs}
+	created, err := apiClient().CreateCertificateAuthority(context.Background(), *user.ID, &ca)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.Authorities = []*models.CertificateAuthority{created}
+
+	succeed(r, "Certificate authority %d (%s) trusted for %s\n", *created.ID, models.Fingerprint(key), name)
+}
+
+func listCertificateAuthorities(name string) {
+	r := &report{Command: "cas list", Login: name}
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	r.Authorities, err = apiClient().ListCertificateAuthorities(context.Background(), *user.ID)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	if outputFormat() == "text" {
+		for _, ca := range r.Authorities {
+			fmt.Printf("%d %s %s\n", *ca.ID, ca.Key.Type(), models.Fingerprint(ca.Key.PublicKey))
+		}
+	}
+	succeed(r, "")
+}
+
+func removeCertificateAuthority(name string, id int, opts utils.SignOptions) {
+	r := &report{Command: "cas remove", Login: name}
+
+	sigs, err := utils.SignWith(name, models.CertificateAuthorityDeletionData(id), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(sigs)
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	del := models.CertificateAuthorityDeletion{AuthorityID: &id, GithubLogin: &name, Signatures: sigs}
+	deleted, err := apiClient().DeleteCertificateAuthority(context.Background(), *user.ID, &del)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.Authorities = []*models.CertificateAuthority{deleted}
+
+	succeed(r, "Certificate authority %d (%s) no longer trusted for %s\n", id, models.Fingerprint(deleted.Key.PublicKey), name)
+}
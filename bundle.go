@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/andrewhamon/signist/client"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/verify"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func bundle(id int, output string) {
+	ctx := context.Background()
+	r := &report{Command: "bundle", MessageID: &id}
+
+	message, err := apiClient().GetMessage(ctx, id)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.setMessage(message)
+
+	if message.GithubLogin == nil {
+		fail(r, exitError, "error", "Message %d does not record the login that signed it, so it can not be bundled", id)
+	}
+	r.Login = *message.GithubLogin
+
+	b, err := verify.NewBundle(ctx, message, signerKeys(r, false))
+	if err != nil {
+		failWith(r, err)
+	}
+
+	if b.LogEntry, err = apiClient().MessageLogEntry(ctx, message); err != nil {
+		failWith(r, err)
+	}
+	logKey, err := apiClient().LogPublicKey(ctx)
+	if err != nil {
+		failWith(r, err)
+	}
+	if b.LogPublicKey, err = client.MarshalLogPublicKey(logKey); err != nil {
+		failWith(r, err)
+	}
+
+	// Refuse to bundle a message that would not verify offline
+	if err := b.VerifyLogEntry(logKey); err != nil {
+		fail(r, exitVerifyFailed, "log_verification_failed", "Message %d failed log verification: %s", id, err.Error())
+	}
+	result, err := verify.Verify(ctx, message, b.KeySource(), verify.Policy{AllowExpired: true, AllowRevoked: true})
+	if err != nil {
+		r.addResults(result)
+		failVerification(r, message, result, err)
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		failWith(r, err)
+	}
+	data = append(data, '\n')
+
+	// The bundle is the result when it is written to standard output
+	if len(output) == 0 {
+		os.Stdout.Write(data)
+		return
+	}
+
+	if err := ioutil.WriteFile(output, data, 0644); err != nil {
+		fail(r, exitError, "error", "Error writing bundle: %s", err.Error())
+	}
+	succeed(r, "Message %d bundled to %s\n", id, output)
+}
+
+// Verify a bundle offline. The log key must be pinned with --log-key or the
+// profile's log_key: everything else in a bundle, including its log key,
+// comes from whoever made it.
+func verifyBundleFile(filename string, artifact string, logKeyFile string, strictPins bool, tsaCert string, revocationThreshold int) {
+	r := &report{Command: "verify"}
+	if len(artifact) == 0 {
+		fail(r, exitError, models.CodeInvalidInput, "--bundle requires --artifact")
+	}
+
+	if len(logKeyFile) == 0 {
+		logKeyFile = settings.LogKey
+	}
+	if len(logKeyFile) == 0 {
+		fail(r, exitError, models.CodeInvalidInput, "--bundle requires --log-key or a log_key in the profile, since the log key in a bundle could be forged along with it")
+	}
+
+	pem, err := ioutil.ReadFile(logKeyFile)
+	if err != nil {
+		fail(r, exitError, "error", "Error reading log key %s: %s", logKeyFile, err.Error())
+	}
+	logKey, err := client.ParseLogPublicKey(pem)
+	if err != nil {
+		fail(r, exitError, models.CodeInvalidInput, "Error parsing log key %s: %s", logKeyFile, err.Error())
+	}
+
+	b, err := verify.LoadBundle(filename)
+	if err != nil {
+		fail(r, exitError, models.CodeInvalidInput, "Error reading bundle: %s", err.Error())
+	}
+
+	data, err := ioutil.ReadFile(artifact)
+	if err != nil {
+		fail(r, exitError, "error", "Error reading artifact: %s", err.Error())
+	}
+
+	message := b.Message
+	r.setMessage(message)
+
+	// Keys pinned from earlier verifies outrank the bundle's
+	if dropped := b.RestrictToPins(knownSigners(r)); len(dropped) > 0 {
+		warnPinsChanged(*message.GithubLogin, dropped, nil)
+		if strictPins {
+			fail(r, exitVerifyFailed, "pins_changed", "%s has keys for %q that are not pinned. Run `signist pins accept %s` if the change is expected.", filename, *message.GithubLogin, *message.GithubLogin)
+		}
+	}
+
+	policy := verifyPolicy(r, tsaCert, revocationThreshold)
+	result, err := b.Verify(context.Background(), data, logKey, policy)
+	r.addResults(result)
+
+	switch {
+	case err == verify.ErrArtifactMismatch:
+		fail(r, exitVerifyFailed, "artifact_mismatch", "%s does not match the message in %s", artifact, filename)
+	case err == verify.ErrNotLogged:
+		fail(r, exitVerifyFailed, "not_logged", "%s has no log entry for message %d. Bundle it again with this version of signist.", filename, *message.ID)
+	case err == verify.ErrLogEntryMismatch:
+		fail(r, exitVerifyFailed, "log_entry_mismatch", "The log entry in %s is not for message %d", filename, *message.ID)
+	case errors.Is(err, verify.ErrLogEntryInvalid):
+		fail(r, exitVerifyFailed, "log_verification_failed", "The log entry in %s did not verify: %s", filename, err.Error())
+	case err == verify.ErrRevoked:
+		fail(r, exitVerifyFailed, "revoked", "Message %d had been revoked (%s) when it was bundled", *message.ID, *result.Revocation.Reason)
+	case err != nil:
+		failVerification(r, message, result, err)
+	}
+
+	if policy.TimestampRoots != nil {
+		succeed(r, "Message %d %q verified offline with %d signatures from %s, timestamped at %s, using keys fetched at %s, and is log entry %d checked against %s\n", *message.ID, *message.Title, result.ValidSignatures, *message.GithubLogin, result.TimestampedAt.UTC().Format(time.RFC3339), b.KeysFetchedAt, b.LogEntry.LogIndex, logKeyFile)
+		return
+	}
+	succeed(r, "Message %d %q verified offline with %d signatures from %s, using keys fetched at %s, and is log entry %d checked against %s\n", *message.ID, *message.Title, result.ValidSignatures, *message.GithubLogin, b.KeysFetchedAt, b.LogEntry.LogIndex, logKeyFile)
+}
@@ -1,73 +1,521 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/gopkg.in/alecthomas/kingpin.v2"
+	"github.com/andrewhamon/signist/client"
+	"github.com/andrewhamon/signist/github"
 	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/timestamp"
 	"github.com/andrewhamon/signist/utils"
+	"github.com/andrewhamon/signist/verify"
 	"io/ioutil"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
+var (
+	profileName = kingpin.Flag("profile", "Profile from the config file to use").OverrideDefaultFromEnvar("SIGNIST_PROFILE").String()
+	outputFlag  = kingpin.Flag("output", "Format of the result: text, json or quiet. Defaults to the profile's output, or text. For bundle, any other value is the file to write, as with --out.").String()
+	verbose     = kingpin.Flag("verbose", "Print the request sent to the server").Bool()
+
+	signCmd           = kingpin.Command("sign", "Sign standard input and upload it to signist.")
+	signName          = signCmd.Arg("name", "Name of a github user or organization to sign as. Defaults to the profile's identity.").String()
+	signTitle         = signCmd.Arg("title", "Title for this signed message").Default(time.Now().Format("Mon-Jan-2-150405-MST")).String()
+	signNotBefore     = signCmd.Flag("not-before", "Time the message becomes valid, in RFC 3339 format").String()
+	signValidFor      = signCmd.Flag("valid-for", "How long the message stays valid for, e.g. 24h").Duration()
+	signType          = signCmd.Flag("content-type", "Media type of the signed data, e.g. application/json").String()
+	signMetadata      = signCmd.Flag("metadata", "Signed metadata as key=value, e.g. version=1.4.2. May be repeated.").StringMap()
+	signDryRun        = signCmd.Flag("dry-run", "Check the signed message against github's keys locally instead of uploading it").Bool()
+	signKeys, signMax = signFlags(signCmd)
+
+	verifyCmd        = kingpin.Command("verify", "Verify the most recent message with a title, or a bundle.")
+	verifyName       = verifyCmd.Arg("name", "Name of the github user or organization that signed the message.").String()
+	verifyTitle      = verifyCmd.Arg("title", "Title of the signed message").String()
+	verifyStrictPins = verifyCmd.Flag("strict-pins", "Fail instead of warning when the signer's keys differ from those pinned on first use").Bool()
+	verifyBundle     = verifyCmd.Flag("bundle", "Verify offline using a bundle from `signist bundle` instead of the server").ExistingFile()
+	verifyArtifact   = verifyCmd.Flag("artifact", "File that must match the bundled message").ExistingFile()
+	verifyTSACert    = verifyCmd.Flag("tsa-cert", "Require a timestamp token from a timestamp authority whose certificate chains to one in this PEM file").ExistingFile()
+	verifyLogKey     = verifyCmd.Flag("log-key", "PEM public key of the log a --bundle's entry must be signed by. Defaults to the profile's log_key. The key in the bundle is never trusted.").ExistingFile()
+	verifyThreshold  = verifyCmd.Flag("revocation-threshold", "Number of distinct keys a revocation must be signed by to be trusted").Default("1").Int()
+
+	bundleCmd    = kingpin.Command("bundle", "Write a self-contained bundle for verifying a message offline.")
+	bundleID     = bundleCmd.Arg("id", "ID of the message to bundle").Required().Int()
+	bundleOutput = bundleCmd.Flag("out", "File to write the bundle to, instead of standard output").Short('o').String()
+
+	pinsCmd        = kingpin.Command("pins", "Manage the keys pinned for each signer on first verify.")
+	pinsListCmd    = pinsCmd.Command("list", "List pinned keys.")
+	pinsListName   = pinsListCmd.Arg("name", "Only list keys pinned for this github user or organization").String()
+	pinsAcceptCmd  = pinsCmd.Command("accept", "Pin the keys github currently lists for a signer, replacing any existing pins.")
+	pinsAcceptName = pinsAcceptCmd.Arg("name", "Name of the github user or organization").Required().String()
+	pinsRemoveCmd  = pinsCmd.Command("remove", "Forget the keys pinned for a signer.")
+	pinsRemoveName = pinsRemoveCmd.Arg("name", "Name of the github user or organization").Required().String()
+
+	revokeCmd             = kingpin.Command("revoke", "Revoke a signed message so that it is no longer trusted.")
+	revokeName            = revokeCmd.Arg("name", "Name of the github user or organization that signed the message.").Required().String()
+	revokeID              = revokeCmd.Arg("id", "ID of the message to revoke").Required().Int()
+	revokeReason          = revokeCmd.Flag("reason", "Why the message is being revoked").Default("unspecified").Enum(models.RevocationReasons...)
+	revokeKeys, revokeMax = signFlags(revokeCmd)
+
+	keysCmd           = kingpin.Command("keys", "List the keys in the SSH agent and on github, and which of them sign.")
+	keysName          = keysCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	keysKeys, keysMax = signFlags(keysCmd)
+
+	attestCmd             = kingpin.Command("attest", "Sign standard input as a DSSE envelope, such as an in-toto statement, and upload it to signist.")
+	attestName            = attestCmd.Arg("name", "Name of a github user or organization to sign as. Defaults to the profile's identity.").String()
+	attestTitle           = attestCmd.Arg("title", "Title for this attestation").String()
+	attestPayloadType     = attestCmd.Flag("payload-type", "DSSE payload type of standard input").Default(models.PayloadTypeInToto).String()
+	attestKeys, attestMax = signFlags(attestCmd)
+
+	gitCmd                        = kingpin.Command("git", "Sign and verify git tags and commits. Set gpg.format to ssh and gpg.ssh.program to signist to record every signed commit and tag under the profile's identity. Recording is best effort unless git config signist.strict is true.")
+	gitSignTagCmd                 = gitCmd.Command("sign-tag", "Sign the object ID the tag resolves to, the tag object for an annotated tag or the commit for a lightweight one, under the title git/<repo>/tags/<tag>.")
+	gitSignTagTag                 = gitSignTagCmd.Arg("tag", "Name of the tag").Required().String()
+	gitSignTagName                = gitSignTagCmd.Arg("name", "Name of a github user or organization to sign as. Defaults to the profile's identity.").String()
+	gitSignTagKeys, gitSignTagMax = signFlags(gitSignTagCmd)
+	gitVerifyCmd                  = gitCmd.Command("verify", "Verify a tag or commit against its signist record.")
+	gitVerifyRef                  = gitVerifyCmd.Arg("ref", "Tag, branch or object to verify").Required().String()
+	gitVerifyName                 = gitVerifyCmd.Arg("name", "Name of the github user or organization that signed it. Defaults to the profile's identity.").String()
+	gitVerifyStrictPins           = gitVerifyCmd.Flag("strict-pins", "Fail instead of warning when the signer's keys differ from those pinned on first use").Bool()
+	gitVerifyTSACert              = gitVerifyCmd.Flag("tsa-cert", "Require a timestamp token from a timestamp authority whose certificate chains to one in this PEM file").ExistingFile()
+	gitVerifyThreshold            = gitVerifyCmd.Flag("revocation-threshold", "Number of distinct keys a revocation must be signed by to be trusted").Default("1").Int()
+
+	webhooksCmd                                   = kingpin.Command("webhooks", "Manage webhooks notified when messages are signed or revoked.")
+	webhooksAddCmd                                = webhooksCmd.Command("add", "Register a webhook. Deliveries are JSON, signed with HMAC-SHA256 in the X-Signist-Signature-256 header.")
+	webhooksAddURL                                = webhooksAddCmd.Arg("url", "URL to POST events to").Required().String()
+	webhooksAddName                               = webhooksAddCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	webhooksAddPrefix                             = webhooksAddCmd.Flag("prefix", "Only deliver events for messages with titles starting with this prefix").String()
+	webhooksAddKeys, webhooksAddMax               = signFlags(webhooksAddCmd)
+	webhooksListCmd                               = webhooksCmd.Command("list", "List registered webhooks. The request is signed, since receiver URLs often embed credentials.")
+	webhooksListName                              = webhooksListCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	webhooksListKeys, webhooksListMax             = signFlags(webhooksListCmd)
+	webhooksRemoveCmd                             = webhooksCmd.Command("remove", "Remove a webhook, cancelling its pending deliveries.")
+	webhooksRemoveID                              = webhooksRemoveCmd.Arg("id", "ID of the webhook").Required().Int()
+	webhooksRemoveName                            = webhooksRemoveCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	webhooksRemoveKeys, webhooksRemoveMax         = signFlags(webhooksRemoveCmd)
+	webhooksDeliveriesCmd                         = webhooksCmd.Command("deliveries", "List the most recent deliveries to a webhook. The request is signed, since deliveries show what the receiver responded.")
+	webhooksDeliveriesID                          = webhooksDeliveriesCmd.Arg("id", "ID of the webhook").Required().Int()
+	webhooksDeliveriesName                        = webhooksDeliveriesCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	webhooksDeliveriesKeys, webhooksDeliveriesMax = signFlags(webhooksDeliveriesCmd)
+
+	casCmd                      = kingpin.Command("cas", "Manage the SSH certificate authorities trusted to certify an identity's signing keys. Changes must be signed with a key listed on github, not a certified one.")
+	casAddCmd                   = casCmd.Command("add", "Trust a certificate authority.")
+	casAddKey                   = casAddCmd.Arg("key", "File with the authority's public key, in authorized_keys format").Required().ExistingFile()
+	casAddName                  = casAddCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	casAddKeys, casAddMax       = signFlags(casAddCmd)
+	casListCmd                  = casCmd.Command("list", "List trusted certificate authorities.")
+	casListName                 = casListCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	casRemoveCmd                = casCmd.Command("remove", "Stop trusting a certificate authority.")
+	casRemoveID                 = casRemoveCmd.Arg("id", "ID of the certificate authority").Required().Int()
+	casRemoveName               = casRemoveCmd.Arg("name", "Name of a github user or organization. Defaults to the profile's identity.").String()
+	casRemoveKeys, casRemoveMax = signFlags(casRemoveCmd)
+)
+
+// The profile selected with --profile
+var settings *profile
+
 func main() {
-	name := kingpin.Arg("name", "Name of a github user or organization to sign as.").Required().String()
-	title := kingpin.Arg("title", "Title for this signed message").Default(time.Now().Format("Mon-Jan-2-150405-MST")).String()
-	kingpin.Parse()
+	kingpin.CommandLine.Help = "Sign and verify data with the SSH keys on github.\n\n" + exitCodesHelp
+
+	// git runs gpg.ssh.program as if it were ssh-keygen
+	if len(os.Args) > 1 && os.Args[1] == "-Y" {
+		settings = loadProfile(os.Getenv("SIGNIST_PROFILE"))
+		os.Exit(sshKeygen(os.Args[2:]))
+	}
+
+	command, err := kingpin.CommandLine.Parse(legacyArgs(os.Args[1:]))
+	if err == nil && len(command) == 0 {
+		kingpin.Usage()
+		os.Exit(0)
+	}
+	command = kingpin.MustParse(command, err)
+	checkOutputFlag(command)
+	settings = loadProfile(*profileName)
+
+	switch command {
+	case signCmd.FullCommand():
+		sign(identity(*signName), *signTitle, *signNotBefore, *signValidFor, *signType, *signMetadata, *signDryRun, signOptions(*signKeys, *signMax))
+	case verifyCmd.FullCommand():
+		if len(*verifyBundle) > 0 {
+			verifyBundleFile(*verifyBundle, *verifyArtifact, *verifyLogKey, *verifyStrictPins, *verifyTSACert, *verifyThreshold)
+		} else if len(*verifyName) > 0 && len(*verifyTitle) > 0 {
+			verifyLatest(*verifyName, *verifyTitle, *verifyStrictPins, *verifyTSACert, *verifyThreshold)
+		} else {
+			kingpin.Fatalf("verify requires a name and title, or --bundle")
+		}
+	case bundleCmd.FullCommand():
+		bundle(*bundleID, *bundleOutput)
+	case pinsListCmd.FullCommand():
+		listPins(*pinsListName)
+	case pinsAcceptCmd.FullCommand():
+		acceptPins(*pinsAcceptName)
+	case pinsRemoveCmd.FullCommand():
+		removePins(*pinsRemoveName)
+	case revokeCmd.FullCommand():
+		revoke(*revokeName, *revokeID, *revokeReason, signOptions(*revokeKeys, *revokeMax))
+	case keysCmd.FullCommand():
+		listKeys(identity(*keysName), signOptions(*keysKeys, *keysMax))
+	case attestCmd.FullCommand():
+		if len(*attestTitle) == 0 {
+			kingpin.Fatalf("attest requires a name and title")
+		}
+		attest(identity(*attestName), *attestTitle, *attestPayloadType, signOptions(*attestKeys, *attestMax))
+	case gitSignTagCmd.FullCommand():
+		gitSignTag(*gitSignTagTag, identity(*gitSignTagName), signOptions(*gitSignTagKeys, *gitSignTagMax))
+	case gitVerifyCmd.FullCommand():
+		gitVerify(*gitVerifyRef, identity(*gitVerifyName), *gitVerifyStrictPins, *gitVerifyTSACert, *gitVerifyThreshold)
+	case webhooksAddCmd.FullCommand():
+		addWebhook(identity(*webhooksAddName), *webhooksAddURL, *webhooksAddPrefix, signOptions(*webhooksAddKeys, *webhooksAddMax))
+	case webhooksListCmd.FullCommand():
+		listWebhooks(identity(*webhooksListName), signOptions(*webhooksListKeys, *webhooksListMax))
+	case webhooksRemoveCmd.FullCommand():
+		removeWebhook(identity(*webhooksRemoveName), *webhooksRemoveID, signOptions(*webhooksRemoveKeys, *webhooksRemoveMax))
+	case webhooksDeliveriesCmd.FullCommand():
+		listWebhookDeliveries(identity(*webhooksDeliveriesName), *webhooksDeliveriesID, signOptions(*webhooksDeliveriesKeys, *webhooksDeliveriesMax))
+	case casAddCmd.FullCommand():
+		addCertificateAuthority(identity(*casAddName), *casAddKey, signOptions(*casAddKeys, *casAddMax))
+	case casListCmd.FullCommand():
+		listCertificateAuthorities(identity(*casListName))
+	case casRemoveCmd.FullCommand():
+		removeCertificateAuthority(identity(*casRemoveName), *casRemoveID, signOptions(*casRemoveKeys, *casRemoveMax))
+	}
+}
+
+func sign(name string, title string, notBefore string, validFor time.Duration, contentType string, metadata map[string]string, dryRun bool, opts utils.SignOptions) {
+	r := &report{Command: "sign", Login: name, Title: title}
 
 	data, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
-		log.Fatalf("Error reading from standard input: %s\n", err.Error())
+		fail(r, exitError, "error", "Error reading from standard input: %s", err.Error())
 	}
 
-	sigs := utils.Sign(*name, data)
-
 	b64Data := base64.StdEncoding.EncodeToString(data)
 
-	message := models.Message{GithubLogin: name, Blob: &b64Data, Title: title}
-	message.Signatures = sigs
-	payload, err := json.Marshal(message)
+	message := models.Message{Version: models.SignedDataV2, GithubLogin: &name, Blob: &b64Data, Title: &title, RawBlob: data}
+
+	if len(contentType) > 0 {
+		message.ContentType = &contentType
+	}
+
+	if len(metadata) > 0 {
+		message.Metadata = models.Metadata(metadata)
+		if err := message.Metadata.Check(); err != nil {
+			fail(r, exitError, models.CodeInvalidInput, "Invalid --metadata: %s", err.Error())
+		}
+	}
+
+	start := time.Now()
+	if len(notBefore) > 0 {
+		start, err = time.Parse(time.RFC3339, notBefore)
+		if err != nil {
+			fail(r, exitError, models.CodeInvalidInput, "Error parsing --not-before: %s", err.Error())
+		}
+		if start.Nanosecond() != 0 {
+			fail(r, exitError, models.CodeInvalidInput, "--not-before must be a whole second")
+		}
+		start = start.UTC()
+		message.NotBefore = &start
+	}
+
+	if validFor > 0 {
+		expiresAt := start.Add(validFor).UTC().Truncate(time.Second)
+		message.ExpiresAt = &expiresAt
+	}
+
+	message.Signatures, err = utils.SignWith(name, message.SignedData(), opts)
+	r.addSignatures(message.Signatures)
+
+	if *verbose {
+		payload, err := json.Marshal(message)
+		if err != nil {
+			fail(r, exitError, "error", "%s", err.Error())
+		}
+		log.Println(string(payload))
+	}
+
+	if dryRun {
+		checkLocally(r, &message, opts, err)
+		return
+	}
+
+	if err != nil {
+		failWith(r, err)
+	}
+
+	created, err := apiClient().CreateMessage(context.Background(), &message)
 	if err != nil {
-		log.Fatalln(err)
+		failWith(r, err)
 	}
 
-	println(string(payload))
+	r.setMessage(created)
+	succeed(r, "Message %d %q signed by %s with %d signatures\n%s\n", *created.ID, title, name, len(message.Signatures), r.URL)
+}
+
+// Check --output is a format. bundle wrote its file with --output before the
+// flag chose the format, so for bundle any other value is taken as --out.
+func checkOutputFlag(command string) {
+	if len(*outputFlag) == 0 || contains(outputFormats, *outputFlag) {
+		return
+	}
+
+	if command == bundleCmd.FullCommand() && len(*bundleOutput) == 0 {
+		*bundleOutput, *outputFlag = *outputFlag, ""
+		return
+	}
+	kingpin.Fatalf("--output must be one of %s, not %q", strings.Join(outputFormats, ", "), *outputFlag)
+}
+
+// Arguments with sign inserted before the first one when it is not a command,
+// so that the original `signist <name> [title]` still signs
+func legacyArgs(args []string) []string {
+	model := kingpin.CommandLine.Model()
+
+	takesValue := map[string]bool{}
+	for _, flag := range model.Flags {
+		if !flag.IsBoolFlag() {
+			takesValue["--"+flag.Name] = true
+			if flag.Short != 0 {
+				takesValue["-"+string(flag.Short)] = true
+			}
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--" && strings.HasPrefix(args[i], "-") {
+			if takesValue[args[i]] {
+				i++
+			}
+			continue
+		}
+
+		first := i
+		if args[i] == "--" {
+			first++
+		}
+		if first >= len(args) {
+			return args
+		}
+		if args[first] == "help" {
+			return args
+		}
+		for _, cmd := range model.Commands {
+			if cmd.Name == args[first] {
+				return args
+			}
+		}
+
+		legacy := append([]string{}, args[:i]...)
+		legacy = append(legacy, signCmd.FullCommand())
+		return append(legacy, args[i:]...)
+	}
+
+	return args
+}
+
+func verifyLatest(name string, title string, strictPins bool, tsaCert string, revocationThreshold int) {
+	r := &report{Command: "verify", Login: name, Title: title}
 
-	res, err := postToApi(*name, *title, payload)
+	policy := verifyPolicy(r, tsaCert, revocationThreshold)
 
+	message, result, err := apiClient().Verify(context.Background(), name, title, signerKeys(r, strictPins), policy)
+	if message != nil {
+		r.setMessage(message)
+		r.addResults(result)
+	}
+	if err != nil {
+		verifyFailed(r, name, message, result, err)
+	}
+
+	if policy.TimestampRoots != nil {
+		succeed(r, "Message %d %q verified with %d signatures from %s, timestamped at %s\n", *message.ID, title, result.ValidSignatures, name, result.TimestampedAt.UTC().Format(time.RFC3339))
+		return
+	}
+	succeed(r, "Message %d %q verified with %d signatures from %s\n", *message.ID, title, result.ValidSignatures, name)
+}
+
+// The policy set by --tsa-cert and --revocation-threshold
+func verifyPolicy(r *report, tsaCert string, revocationThreshold int) verify.Policy {
+	return verify.Policy{MinRevocationSignatures: revocationThreshold, TimestampRoots: timestampRoots(r, tsaCert)}
+}
+
+// The timestamp authority roots in the --tsa-cert file, or nil when there is
+// none
+func timestampRoots(r *report, tsaCert string) *x509.CertPool {
+	if len(tsaCert) == 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(tsaCert)
+	if err != nil {
+		fail(r, exitError, "error", "Error reading --tsa-cert: %s", err.Error())
+	}
+	roots, err := timestamp.ParseRoots(data)
+	if err != nil {
+		fail(r, exitError, models.CodeInvalidInput, "Error parsing --tsa-cert: %s", err.Error())
+	}
+	return roots
+}
+
+// The signer's keys from github, pinned on first use, and the certificate
+// authorities the server lists for them
+func signerKeys(r *report, strictPins bool) verify.TOFU {
+	return verify.TOFU{
+		Source:   client.WithServerCAs{KeySource: verify.GithubKeys{}, Client: apiClient()},
+		Store:    knownSigners(r),
+		Strict:   strictPins,
+		OnChange: warnPinsChanged,
+	}
+}
+
+// Fail with the reason verifying the latest message from name did not succeed
+func verifyFailed(r *report, name string, message *models.Message, result verify.Result, err error) {
+	switch {
+	case err == verify.ErrPinsChanged:
+		fail(r, exitVerifyFailed, "pins_changed", "The keys for %q have changed. Run `signist pins accept %s` if the change is expected.", name, name)
+	case err == verify.ErrRevoked && !result.RevocationVerified:
+		log.Printf("Warning: the revocation of message %d could not be verified\n", *message.ID)
+		failVerification(r, message, result, err)
+	case message != nil:
+		failVerification(r, message, result, err)
+	default:
+		failWith(r, err)
+	}
+}
+
+// Fail with the reason a message did not verify
+func failVerification(r *report, message *models.Message, result verify.Result, err error) {
+	switch err {
+	case verify.ErrSignatureInvalid, verify.ErrNotEnoughSignatures:
+		if outputFormat() == "text" {
+			printSignatureResults(result)
+		}
+		fail(r, exitVerifyFailed, models.CodeSignatureInvalid, "Message %d failed verification", *message.ID)
+	case verify.ErrNotYetValid:
+		fail(r, exitVerifyFailed, "not_yet_valid", "Message %d is not valid until %s", *message.ID, message.NotBefore)
+	case verify.ErrExpired:
+		fail(r, exitVerifyFailed, "expired", "Message %d expired at %s", *message.ID, message.ExpiresAt)
+	case verify.ErrTimestampMissing:
+		fail(r, exitVerifyFailed, "timestamp_missing", "Message %d has no timestamp token", *message.ID)
+	case verify.ErrTimestampInvalid:
+		fail(r, exitVerifyFailed, "timestamp_invalid", "Message %d has a timestamp token that did not verify: %s", *message.ID, result.TimestampError.Error())
+	case verify.ErrRevoked:
+		fail(r, exitVerifyFailed, "revoked", "Message %d has been revoked (%s) at %s", *message.ID, *result.Revocation.Reason, result.Revocation.CreatedAt)
+	default:
+		failWith(r, err)
+	}
+}
+
+func revoke(name string, id int, reason string, opts utils.SignOptions) {
+	r := &report{Command: "revoke", Login: name, MessageID: &id}
+
+	// The revocation names the message by its content as well as its ID, so
+	// it can not be applied to a different message with the same ID
+	message, err := apiClient().GetMessage(context.Background(), id)
 	if err != nil {
-		log.Fatalf("Error sending data to server: %s\n", err.Error())
+		failWith(r, err)
+	}
+	if *message.GithubLogin != name {
+		fail(r, exitError, models.CodeRevocationMismatch, "Message %d was signed by %s, not %s", id, *message.GithubLogin, name)
+	}
+	if err := message.ValidateBlob(); err != nil {
+		fail(r, exitError, err.Code, "Message %d: %s", id, err.Message)
+	}
+	digest := message.RevocationDigest()
+
+	sigs, err := utils.SignWith(name, models.RevocationData(id, digest, reason), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(sigs)
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	rev := models.Revocation{GithubLogin: &name, MessageID: &id, MessageDigest: &digest, Reason: &reason, Signatures: sigs}
+	if _, err := apiClient().RevokeMessage(context.Background(), *user.ID, &rev); err != nil {
+		failWith(r, err)
 	}
 
-	defer res.Body.Close()
+	r.URL = apiClient().MessageURL(id)
+	succeed(r, "Message %d revoked (%s)\n", id, reason)
+}
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		body, _ := ioutil.ReadAll(res.Body)
-		log.Fatalf("%s %s\n", res.StatusCode, body)
+func printErrors(errs models.Errors) {
+	for _, err := range errs {
+		if err.SignatureIndex != nil {
+			log.Printf("  signature %d: %s (%s)\n", *err.SignatureIndex, err.Message, err.Code)
+		} else {
+			log.Printf("  %s (%s)\n", err.Message, err.Code)
+		}
 	}
 }
 
-func apiUrl() *url.URL {
-	rawurl := os.Getenv("SIGNIST_API_URL")
-	if len(rawurl) == 0 {
-		rawurl = "https://api.signist.org"
+func printSignatureResults(result verify.Result) {
+	for _, sig := range result.Signatures {
+		if sig.Err != nil {
+			log.Printf("  signature %d: %s (%s)\n", sig.Index, sig.Err.Message, sig.Err.Code)
+		} else {
+			log.Printf("  signature %d: verified with %s\n", sig.Index, sig.Fingerprint)
+		}
 	}
+}
 
-	parsedurl, err := url.Parse(rawurl)
+// Load the config file and return the named profile, configuring the github
+// client with its credentials
+func loadProfile(name string) *profile {
+	cfg, err := loadConfig(configPath())
+	if err != nil {
+		kingpin.Fatalf("Invalid config: %s", err.Error())
+	}
 
+	p, err := cfg.profile(name)
 	if err != nil {
-		panic("Could not parse URL " + rawurl + "\n" + err.Error())
+		kingpin.Fatalf("%s", err.Error())
 	}
 
-	return parsedurl
+	if len(p.GithubToken) > 0 {
+		github.SetToken(p.GithubToken)
+	}
+	return p
 }
 
-func postToApi(login string, title string, payload []byte) (*http.Response, error) {
-	destUrl := apiUrl()
-	return http.Post(destUrl.String(), "application/json", bytes.NewReader(payload))
+// The login given on the command line, or the profile's identity
+func identity(name string) string {
+	if len(name) > 0 {
+		return name
+	}
+	if len(settings.Identity) == 0 {
+		kingpin.Fatalf("no name given and the profile has no identity")
+	}
+	return settings.Identity
+}
+
+// The api_url of a profile chosen with --profile, $SIGNIST_API_URL, the
+// default profile's api_url, or the default
+func apiUrl() string {
+	if settings.Selected && len(settings.APIURL) > 0 {
+		return settings.APIURL
+	}
+	if rawurl := os.Getenv("SIGNIST_API_URL"); len(rawurl) > 0 {
+		return rawurl
+	}
+	if len(settings.APIURL) > 0 {
+		return settings.APIURL
+	}
+	return client.DefaultBaseURL
+}
+
+func apiClient() *client.Client {
+	c, err := client.New(apiUrl())
+	if err != nil {
+		kingpin.Fatalf("Invalid API URL: %s", err.Error())
+	}
+
+	return c
 }
@@ -0,0 +1,300 @@
+// Package client is a Go client for the signist API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/andrewhamon/signist/models"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const DefaultBaseURL = "https://api.signist.org"
+
+// Returned when the server responds with an error
+type ResponseError struct {
+	StatusCode int
+	models.APIError
+}
+
+func (err *ResponseError) Error() string {
+	return fmt.Sprintf("%d %s: %s", err.StatusCode, err.Code, err.Message)
+}
+
+type Client struct {
+	BaseURL    *url.URL
+	HTTPClient *http.Client
+
+	// GET requests that fail with a network error or a 5xx are retried up to
+	// MaxRetries times, waiting RetryWait and then twice as long as the last
+	// wait before each attempt. Other requests are not: a DELETE the server
+	// handled before failing would be rejected when repeated, as a revocation
+	// is with already_revoked.
+	MaxRetries int
+	RetryWait  time.Duration
+}
+
+// Filters for listing messages. Zero values are ignored.
+type ListOptions struct {
+	Prefix      string
+	ContentType string
+	Metadata    map[string]string
+}
+
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("signist API URL %q must be http or https", baseURL)
+	}
+
+	return &Client{
+		BaseURL:    u,
+		HTTPClient: http.DefaultClient,
+		MaxRetries: 3,
+		RetryWait:  500 * time.Millisecond,
+	}, nil
+}
+
+// List the messages signed by a github user or organization, by github ID
+func (c *Client) ListMessages(ctx context.Context, githubID int, opts *ListOptions) ([]*models.Message, error) {
+	messages := []*models.Message{}
+	err := c.do(ctx, "GET", "/"+strconv.Itoa(githubID), opts.query(), nil, &messages)
+	return messages, err
+}
+
+// Fetch a single message by its ID
+func (c *Client) GetMessage(ctx context.Context, id int) (*models.Message, error) {
+	message := &models.Message{}
+	err := c.do(ctx, "GET", "/messages/"+strconv.Itoa(id), nil, nil, message)
+	return message, err
+}
+
+// The URL a message can be fetched from
+func (c *Client) MessageURL(id int) string {
+	u := *c.BaseURL
+	u.Path = u.Path + "/messages/" + strconv.Itoa(id)
+	return u.String()
+}
+
+// List the messages signed by a github user or organization, by login
+func (c *Client) ListUserMessages(ctx context.Context, login string, opts *ListOptions) ([]*models.Message, error) {
+	messages := []*models.Message{}
+	err := c.do(ctx, "GET", "/users/"+url.PathEscape(login)+"/messages", opts.query(), nil, &messages)
+	return messages, err
+}
+
+// List the DSSE messages, from any identity, with an in-toto subject whose
+// digest is given, e.g. "sha256" and a hex digest
+func (c *Client) ListSubjectMessages(ctx context.Context, algorithm string, digest string) ([]*models.Message, error) {
+	messages := []*models.Message{}
+	err := c.do(ctx, "GET", "/subjects/"+url.PathEscape(algorithm)+"/"+url.PathEscape(digest), nil, nil, &messages)
+	return messages, err
+}
+
+// Return the most recent message with exactly this title
+func (c *Client) LatestMessage(ctx context.Context, login string, title string) (*models.Message, error) {
+	messages, err := c.ListUserMessages(ctx, login, &ListOptions{Prefix: title})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *models.Message
+	for _, m := range messages {
+		if *m.Title == title && (latest == nil || m.CreatedAt.After(*latest.CreatedAt)) {
+			latest = m
+		}
+	}
+
+	if latest == nil {
+		return nil, &ResponseError{StatusCode: http.StatusNotFound, APIError: models.APIError{
+			Code:    models.CodeMessageNotFound,
+			Message: fmt.Sprintf("No message titled %q found for %q", title, login),
+		}}
+	}
+
+	latest.GithubLogin = &login
+	return latest, nil
+}
+
+// Upload a signed message, returning it as stored
+func (c *Client) CreateMessage(ctx context.Context, message *models.Message) (*models.Message, error) {
+	created := &models.Message{}
+	err := c.do(ctx, "POST", "/", nil, message, created)
+	return created, err
+}
+
+// Revoke a message, returning the revocation as stored
+func (c *Client) RevokeMessage(ctx context.Context, githubID int, rev *models.Revocation) (*models.Revocation, error) {
+	created := &models.Revocation{}
+	err := c.do(ctx, "DELETE", "/"+strconv.Itoa(githubID)+"/"+strconv.Itoa(*rev.MessageID), nil, rev, created)
+	return created, err
+}
+
+// Register a webhook, returning it as stored with the secret its deliveries
+// are signed with. The secret is not returned again.
+func (c *Client) CreateWebhook(ctx context.Context, githubID int, hook *models.Webhook) (*models.Webhook, error) {
+	created := &models.Webhook{}
+	err := c.do(ctx, "POST", "/"+strconv.Itoa(githubID)+"/webhooks", nil, hook, created)
+	return created, err
+}
+
+// List the webhooks registered for a github user or organization
+func (c *Client) ListWebhooks(ctx context.Context, githubID int, lr *models.WebhookListRequest) ([]*models.Webhook, error) {
+	hooks := []*models.Webhook{}
+	err := c.do(ctx, "POST", "/"+strconv.Itoa(githubID)+"/webhooks/list", nil, lr, &hooks)
+	return hooks, err
+}
+
+// Remove a webhook, returning it as it was registered
+func (c *Client) DeleteWebhook(ctx context.Context, githubID int, del *models.WebhookDeletion) (*models.Webhook, error) {
+	deleted := &models.Webhook{}
+	err := c.do(ctx, "DELETE", "/"+strconv.Itoa(githubID)+"/webhooks/"+strconv.Itoa(*del.WebhookID), nil, del, deleted)
+	return deleted, err
+}
+
+// List the most recent deliveries to a webhook, newest first
+func (c *Client) ListWebhookDeliveries(ctx context.Context, githubID int, dr *models.WebhookDeliveriesRequest) ([]*models.WebhookDelivery, error) {
+	deliveries := []*models.WebhookDelivery{}
+	err := c.do(ctx, "POST", "/"+strconv.Itoa(githubID)+"/webhooks/"+strconv.Itoa(*dr.WebhookID)+"/deliveries", nil, dr, &deliveries)
+	return deliveries, err
+}
+
+// Trust a certificate authority to certify an identity's signing keys
+func (c *Client) CreateCertificateAuthority(ctx context.Context, githubID int, ca *models.CertificateAuthority) (*models.CertificateAuthority, error) {
+	created := &models.CertificateAuthority{}
+	err := c.do(ctx, "POST", "/"+strconv.Itoa(githubID)+"/certificate_authorities", nil, ca, created)
+	return created, err
+}
+
+// List the certificate authorities a github user or organization trusts
+func (c *Client) ListCertificateAuthorities(ctx context.Context, githubID int) ([]*models.CertificateAuthority, error) {
+	cas := []*models.CertificateAuthority{}
+	err := c.do(ctx, "GET", "/"+strconv.Itoa(githubID)+"/certificate_authorities", nil, nil, &cas)
+	return cas, err
+}
+
+// Stop trusting a certificate authority, returning it as it was trusted
+func (c *Client) DeleteCertificateAuthority(ctx context.Context, githubID int, del *models.CertificateAuthorityDeletion) (*models.CertificateAuthority, error) {
+	deleted := &models.CertificateAuthority{}
+	err := c.do(ctx, "DELETE", "/"+strconv.Itoa(githubID)+"/certificate_authorities/"+strconv.Itoa(*del.AuthorityID), nil, del, deleted)
+	return deleted, err
+}
+
+func (opts *ListOptions) query() url.Values {
+	q := url.Values{}
+	if opts == nil {
+		return q
+	}
+
+	if opts.Prefix != "" {
+		q.Set("prefix", opts.Prefix)
+	}
+	if opts.ContentType != "" {
+		q.Set("content_type", opts.ContentType)
+	}
+	for k, v := range opts.Metadata {
+		q.Set("metadata."+k, v)
+	}
+	return q
+}
+
+// Send a request with body encoded as JSON, decoding a successful response
+// into out
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	u := *c.BaseURL
+	u.Path = u.Path + path
+	u.RawQuery = query.Encode()
+
+	retries := 0
+	if method == "GET" {
+		retries = c.MaxRetries
+	}
+
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, method, u.String(), payload)
+		if err == nil && res.StatusCode < 500 {
+			return decodeResponse(res, out)
+		}
+
+		if attempt >= retries || ctx.Err() != nil {
+			if err != nil {
+				return err
+			}
+			return decodeResponse(res, out)
+		}
+
+		if res != nil {
+			res.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, rawurl string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawurl, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.HTTPClient.Do(req)
+}
+
+func decodeResponse(res *http.Response, out interface{}) error {
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		envelope := models.ErrorEnvelope{}
+		if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error.Code == "" {
+			return &ResponseError{StatusCode: res.StatusCode, APIError: models.APIError{
+				Code:    models.CodeInternal,
+				Message: fmt.Sprintf("unexpected response: %s", body),
+			}}
+		}
+		return &ResponseError{StatusCode: res.StatusCode, APIError: envelope.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/models"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+)
+
+// Fetch the log's size, root hash and signed checkpoint
+func (c *Client) LogInfo(ctx context.Context) (*models.LogInfo, error) {
+	info := &models.LogInfo{}
+	err := c.do(ctx, "GET", "/api/v1/log", nil, nil, info)
+	return info, err
+}
+
+// Fetch the log entry at index, returning its UUID and the entry
+func (c *Client) LogEntry(ctx context.Context, index int) (string, *models.LogEntry, error) {
+	entries := map[string]models.LogEntry{}
+	err := c.do(ctx, "GET", "/api/v1/log/entries", url.Values{"logIndex": {strconv.Itoa(index)}}, nil, &entries)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(entries) != 1 {
+		return "", nil, fmt.Errorf("expected one log entry at index %d, got %d", index, len(entries))
+	}
+	for uuid, entry := range entries {
+		return uuid, &entry, nil
+	}
+	return "", nil, nil
+}
+
+// Fetch the log entries from start up to end, in order, as their UUIDs and
+// the entries. At most models.MaxLogEntryRange are returned at once.
+func (c *Client) LogEntries(ctx context.Context, start int, end int) ([]string, []*models.LogEntry, error) {
+	pages := []map[string]models.LogEntry{}
+	err := c.do(ctx, "GET", "/api/v1/log/entries", url.Values{"start": {strconv.Itoa(start)}, "end": {strconv.Itoa(end)}}, nil, &pages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uuids := make([]string, 0, len(pages))
+	entries := make([]*models.LogEntry, 0, len(pages))
+	for _, page := range pages {
+		if len(page) != 1 {
+			return nil, nil, fmt.Errorf("expected one log entry per item, got %d", len(page))
+		}
+		for uuid, entry := range page {
+			entry := entry
+			uuids = append(uuids, uuid)
+			entries = append(entries, &entry)
+		}
+	}
+	return uuids, entries, nil
+}
+
+// Fetch the log entry with uuid, with its inclusion proof
+func (c *Client) LogEntryByUUID(ctx context.Context, uuid string) (*models.LogEntry, error) {
+	entries := map[string]models.LogEntry{}
+	if err := c.do(ctx, "GET", "/api/v1/log/entries/"+uuid, nil, nil, &entries); err != nil {
+		return nil, err
+	}
+
+	entry, ok := entries[uuid]
+	if !ok {
+		return nil, fmt.Errorf("log entry %s was not returned", uuid)
+	}
+	return &entry, nil
+}
+
+// The UUIDs of the log entries for messages whose blob has the hex SHA-256
+func (c *Client) SearchLogIndex(ctx context.Context, blobSHA256 string) ([]string, error) {
+	uuids := []string{}
+	err := c.do(ctx, "POST", "/api/v1/index/retrieve", nil, models.SearchIndex{Hash: "sha256:" + blobSHA256}, &uuids)
+	return uuids, err
+}
+
+// Find the log entry a message was appended to the log in
+func (c *Client) MessageLogEntry(ctx context.Context, message *models.Message) (*models.LogEntry, error) {
+	blob, err := base64.StdEncoding.DecodeString(*message.Blob)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(blob)
+
+	uuids, err := c.SearchLogIndex(ctx, hex.EncodeToString(hash[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uuid := range uuids {
+		entry, err := c.LogEntryByUUID(ctx, uuid)
+		if err != nil {
+			return nil, err
+		}
+		spec, err := entry.Spec()
+		if err != nil {
+			return nil, err
+		}
+		if spec.Message != nil && spec.Message.ID != nil && *spec.Message.ID == *message.ID {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("message %d is not in the log", *message.ID)
+}
+
+// Fetch the proof that the log of firstSize entries is a prefix of the log of
+// lastSize entries
+func (c *Client) ConsistencyProof(ctx context.Context, firstSize int, lastSize int) (*models.ConsistencyProof, error) {
+	proof := &models.ConsistencyProof{}
+	query := url.Values{"firstSize": {strconv.Itoa(firstSize)}, "lastSize": {strconv.Itoa(lastSize)}}
+	err := c.do(ctx, "GET", "/api/v1/log/proof", query, nil, proof)
+	return proof, err
+}
+
+// Fetch the key the log signs its checkpoints with
+func (c *Client) LogPublicKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	u := *c.BaseURL
+	u.Path = u.Path + "/api/v1/log/publicKey"
+
+	res, err := c.send(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		if err := decodeResponse(res, nil); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected status %d fetching the log key", res.StatusCode)
+	}
+
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLogPublicKey(data)
+}
+
+// Parse a PEM encoded ECDSA log public key
+func ParseLogPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("log key must be an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// Encode a log public key as PEM
+func MarshalLogPublicKey(key *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/verify"
+)
+
+// A KeySource that also trusts the certificate authorities the server lists
+// for a login
+type WithServerCAs struct {
+	verify.KeySource
+	Client *Client
+}
+
+func (w WithServerCAs) CertificateAuthorities(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	user, err := github.UserFor(login)
+	if err != nil {
+		return nil, err
+	}
+	return w.Client.CertificateAuthorityKeys(ctx, *user.ID)
+}
+
+// The keys of the certificate authorities a github user or organization
+// trusts
+func (c *Client) CertificateAuthorityKeys(ctx context.Context, githubID int) ([]ssh.PublicKey, error) {
+	cas, err := c.ListCertificateAuthorities(ctx, githubID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]ssh.PublicKey, 0, len(cas))
+	for _, ca := range cas {
+		if ca.Key != nil && ca.Key.PublicKey != nil {
+			keys = append(keys, ca.Key.PublicKey)
+		}
+	}
+	return keys, nil
+}
+
+// Fetch the most recent message titled title signed by login and verify it
+// against the keys keySource trusts for login
+func (c *Client) Verify(ctx context.Context, login string, title string, keySource verify.KeySource, policy verify.Policy) (*models.Message, verify.Result, error) {
+	message, err := c.LatestMessage(ctx, login, title)
+	if err != nil {
+		return nil, verify.Result{}, err
+	}
+
+	result, err := verify.Verify(ctx, message, keySource, policy)
+	return message, result, err
+}
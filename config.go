@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/andrewhamon/signist/client"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultProfile = "default"
+
+var loginRegex = regexp.MustCompile(`\A[a-zA-Z\d](-?[a-zA-Z\d]){0,38}\z`)
+
+var outputFormats = []string{"text", "json", "quiet"}
+
+// Settings for one server. Empty fields fall back to the defaults.
+type profile struct {
+	APIURL      string
+	Identity    string
+	Key         string
+	GithubToken string
+	Output      string
+
+	// PEM file with the public key of the server's log, which bundles are
+	// verified against
+	LogKey string
+
+	// Whether the profile was chosen by name rather than being the default
+	Selected bool
+}
+
+// Profiles from the config file, by name. The file is INI-like:
+//
+//	[default]
+//	api_url = https://api.signist.org
+//	identity = octocat
+//	log_key = /home/octocat/.config/signist/log.pem
+//
+//	[staging]
+//	api_url = https://staging.signist.org
+//
+// Settings before the first section belong to the default profile.
+type config map[string]*profile
+
+// Where the config file is kept, $SIGNIST_CONFIG or ~/.config/signist/config
+// by default
+func configPath() string {
+	if path := os.Getenv("SIGNIST_CONFIG"); len(path) > 0 {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("Error finding home directory: %s\n", err.Error())
+	}
+	return filepath.Join(home, ".config", "signist", "config")
+}
+
+// Read and validate the config file at path. A missing file has only an
+// empty default profile.
+func loadConfig(path string) (config, error) {
+	cfg := config{defaultProfile: &profile{}}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	current := cfg[defaultProfile]
+	scanner := bufio.NewScanner(f)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("%s line %d: unterminated section header", path, n)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if len(name) == 0 {
+				return nil, fmt.Errorf("%s line %d: empty profile name", path, n)
+			}
+			if _, ok := cfg[name]; !ok {
+				cfg[name] = &profile{}
+			}
+			current = cfg[name]
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i == -1 {
+			return nil, fmt.Errorf("%s line %d: expected key = value", path, n)
+		}
+
+		key := strings.TrimSpace(line[:i])
+		value := strings.Trim(strings.TrimSpace(line[i+1:]), `"`)
+		if err := current.set(key, value); err != nil {
+			return nil, fmt.Errorf("%s line %d: %s", path, n, err.Error())
+		}
+	}
+
+	return cfg, scanner.Err()
+}
+
+func (p *profile) set(key string, value string) error {
+	switch key {
+	case "api_url":
+		if _, err := client.New(value); err != nil {
+			return err
+		}
+		p.APIURL = value
+	case "identity":
+		if !loginRegex.MatchString(value) {
+			return fmt.Errorf("identity %q is not a valid github login", value)
+		}
+		p.Identity = value
+	case "key":
+		if !strings.HasPrefix(value, "SHA256:") {
+			return fmt.Errorf("key must be a SHA256 fingerprint, e.g. SHA256:abc..., not %q", value)
+		}
+		p.Key = value
+	case "github_token":
+		p.GithubToken = value
+	case "log_key":
+		if len(value) == 0 {
+			return fmt.Errorf("log_key must be the path of a PEM public key")
+		}
+		p.LogKey = value
+	case "output":
+		if !contains(outputFormats, value) {
+			return fmt.Errorf("output must be one of %s, not %q", strings.Join(outputFormats, ", "), value)
+		}
+		p.Output = value
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return nil
+}
+
+// The named profile, or the default profile when name is empty
+func (cfg config) profile(name string) (*profile, error) {
+	if len(name) == 0 {
+		return cfg[defaultProfile], nil
+	}
+
+	p, ok := cfg[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile named %q in %s", name, configPath())
+	}
+	p.Selected = true
+	return p, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/utils"
+)
+
+// Validate a signed message the way the server would, against the keys github
+// lists now and the certificate authorities the server trusts, without
+// uploading it. signErr is the error from signing, if any.
+func checkLocally(r *report, message *models.Message, opts utils.SignOptions, signErr error) {
+	r.DryRun = true
+
+	aks, gks, err := utils.AgentAndGithubKeys(*message.GithubLogin)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.Keys = keyReports(utils.ChooseKeys(*message.GithubLogin, aks, gks, opts))
+
+	if outputFormat() == "text" {
+		printKeyReports(r.Keys)
+	}
+
+	if signErr != nil {
+		failWith(r, signErr)
+	}
+
+	// The server checks certificates against the authorities it lists
+	models.CertificateAuthoritiesFor = func(githubID int) ([]ssh.PublicKey, error) {
+		return apiClient().CertificateAuthorityKeys(context.Background(), githubID)
+	}
+
+	if errs := message.Validate(); len(errs) > 0 {
+		exitCode := exitError
+		for _, err := range errs {
+			if err.SignatureIndex != nil {
+				exitCode = exitVerifyFailed
+			}
+		}
+
+		r.Error = &errorReport{Details: errs}
+		if len(errs) == 1 {
+			fail(r, exitCode, errs[0].Code, "Message would be rejected: %s", errs[0].Message)
+		}
+		fail(r, exitCode, models.CodeValidationFailed, "Message would be rejected")
+	}
+
+	succeed(r, "Message %q would be accepted with %d signatures from %s (dry run, not uploaded)\n", *message.Title, len(message.Signatures), *message.GithubLogin)
+}
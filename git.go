@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/client"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/sshkeys"
+	"github.com/andrewhamon/signist/utils"
+	"github.com/andrewhamon/signist/verify"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Messages for git objects are titled git/<repo>/tags/<tag> for tags signed
+// with `signist git sign-tag`, and git/<repo>/objects/<sha256> for commits
+// and tags signed by git through gpg.ssh.program, where sha256 is the hash
+// of the signed object without its signature. The repo name is the git
+// config value signist.repo, or the name of the repository's directory.
+
+var repoNameInvalid = regexp.MustCompile(`[^a-zA-Z\d\-\_\.]+`)
+
+var errBlobMismatch = errors.New("signed message does not match the git object")
+
+// Run git and return its trimmed standard output
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+// The repository name used in titles
+func gitRepoName() (string, error) {
+	if name, err := gitOutput("config", "--get", "signist.repo"); err == nil && len(name) > 0 {
+		return name, nil
+	}
+
+	top, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(repoNameInvalid.ReplaceAllString(filepath.Base(top), "-"), "."), nil
+}
+
+func gitTagTitle(repo string, tag string) string {
+	return "git/" + repo + "/tags/" + tag
+}
+
+func gitObjectTitle(repo string, payload []byte) string {
+	hash := sha256.Sum256(payload)
+	return "git/" + repo + "/objects/" + hex.EncodeToString(hash[:])
+}
+
+// The object type of a signed payload, judged by its first header
+func gitPayloadType(payload []byte) string {
+	if bytes.HasPrefix(payload, []byte("object ")) {
+		return "tag"
+	}
+	return "commit"
+}
+
+// Return a commit or tag object as git signed it, without its signature
+func gitPayload(objectType string, content []byte) []byte {
+	if objectType == "tag" {
+		for _, marker := range []string{"-----BEGIN SSH SIGNATURE-----", "-----BEGIN PGP SIGNATURE-----"} {
+			if i := bytes.Index(content, []byte("\n"+marker)); i != -1 {
+				return content[:i+1]
+			}
+		}
+		return content
+	}
+
+	headerEnd := bytes.Index(content, []byte("\n\n"))
+	if headerEnd == -1 {
+		return content
+	}
+
+	payload := bytes.Buffer{}
+	inSignature := false
+	for _, line := range bytes.SplitAfter(content[:headerEnd+1], []byte("\n")) {
+		if inSignature && bytes.HasPrefix(line, []byte(" ")) {
+			continue
+		}
+		inSignature = bytes.HasPrefix(line, []byte("gpgsig ")) || bytes.HasPrefix(line, []byte("gpgsig-sha256 "))
+		if !inSignature {
+			payload.Write(line)
+		}
+	}
+	payload.Write(content[headerEnd+1:])
+	return payload.Bytes()
+}
+
+// Sign the object ID refs/tags/<tag> resolves to under the tag's title: the
+// tag object for an annotated tag, or the commit for a lightweight one
+func gitSignTag(tag string, name string, opts utils.SignOptions) {
+	r := &report{Command: "git sign-tag", Login: name}
+
+	repo, err := gitRepoName()
+	if err != nil {
+		fail(r, exitError, "error", "%s", err.Error())
+	}
+
+	oid, err := gitOutput("rev-parse", "--verify", "refs/tags/"+tag)
+	if err != nil {
+		fail(r, exitError, "error", "No tag named %q: %s", tag, err.Error())
+	}
+
+	title := gitTagTitle(repo, tag)
+	r.Title = title
+	if !models.ValidTitle(title) {
+		fail(r, exitError, models.CodeInvalidInput, "Tag %q cannot be used in a signist title", tag)
+	}
+
+	b64Data := base64.StdEncoding.EncodeToString([]byte(oid))
+	message := models.Message{Version: models.SignedDataV2, GithubLogin: &name, Blob: &b64Data, Title: &title, RawBlob: []byte(oid)}
+	message.Metadata = models.Metadata{"git.ref": "refs/tags/" + tag}
+
+	message.Signatures, err = utils.SignWith(name, message.SignedData(), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(message.Signatures)
+
+	created, err := apiClient().CreateMessage(context.Background(), &message)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	r.setMessage(created)
+	succeed(r, "Tag %s (%s) signed by %s as message %d\n%s\n", tag, oid, name, *created.ID, r.URL)
+}
+
+// Verify a ref against the signist record for it: the sign-tag message when
+// ref is a signed tag, otherwise the message recorded when git signed the
+// object ref names
+func gitVerify(ref string, name string, strictPins bool, tsaCert string, revocationThreshold int) {
+	r := &report{Command: "git verify", Login: name}
+	policy := verifyPolicy(r, tsaCert, revocationThreshold)
+
+	repo, err := gitRepoName()
+	if err != nil {
+		fail(r, exitError, "error", "%s", err.Error())
+	}
+
+	if oid, err := gitOutput("rev-parse", "-q", "--verify", "refs/tags/"+ref); err == nil {
+		title := gitTagTitle(repo, ref)
+		if models.ValidTitle(title) {
+			r.Title = title
+			message, ok := gitVerifyMessage(r, name, title, []byte(oid), strictPins, policy)
+			if ok {
+				succeed(r, "Tag %s (%s) verified against message %d from %s\n", ref, oid, *message.ID, name)
+				return
+			}
+		}
+	}
+
+	oid, err := gitOutput("rev-parse", "--verify", ref+"^{object}")
+	if err != nil {
+		fail(r, exitError, "error", "No object named %q: %s", ref, err.Error())
+	}
+	objectType, err := gitOutput("cat-file", "-t", oid)
+	if err != nil {
+		fail(r, exitError, "error", "%s", err.Error())
+	}
+	if objectType != "commit" && objectType != "tag" {
+		fail(r, exitError, "error", "%s is a %s, only commits and tags are signed", ref, objectType)
+	}
+
+	content, err := exec.Command("git", "cat-file", objectType, oid).Output()
+	if err != nil {
+		fail(r, exitError, "error", "Error reading %s: %s", oid, err.Error())
+	}
+
+	payload := gitPayload(objectType, content)
+	r.Title = gitObjectTitle(repo, payload)
+	message, ok := gitVerifyMessage(r, name, r.Title, payload, strictPins, policy)
+	if !ok {
+		fail(r, exitVerifyFailed, models.CodeMessageNotFound, "No signist record of %s %s for %s", objectType, oid, name)
+	}
+
+	succeed(r, "%s %s verified against message %d from %s\n", strings.ToUpper(objectType[:1])+objectType[1:], oid, *message.ID, name)
+}
+
+// Verify the latest message titled title against blob. Returns false when
+// there is no such message, and fails on anything else that goes wrong.
+func gitVerifyMessage(r *report, name string, title string, blob []byte, strictPins bool, policy verify.Policy) (*models.Message, bool) {
+	message, result, err := apiClient().Verify(context.Background(), name, title, signerKeys(r, strictPins), policy)
+
+	var resErr *client.ResponseError
+	if errors.As(err, &resErr) && resErr.Code == models.CodeMessageNotFound {
+		return nil, false
+	}
+
+	if message != nil {
+		r.setMessage(message)
+		r.addResults(result)
+	}
+	if err != nil {
+		verifyFailed(r, name, message, result, err)
+	}
+
+	signed, err := base64.StdEncoding.DecodeString(*message.Blob)
+	if err != nil || !bytes.Equal(signed, blob) {
+		fail(r, exitVerifyFailed, "blob_mismatch", "Message %d: %s", *message.ID, errBlobMismatch.Error())
+	}
+	return message, true
+}
+
+// Act as ssh-keygen for git's gpg.ssh.program: `-Y sign` makes an SSHSIG
+// signature with the SSH agent and records the signed object in signist
+// under the profile's identity. Everything else is passed to ssh-keygen.
+//
+// The signature is written before the object is recorded, and recording is
+// best effort: a failure is reported on standard error and git still gets
+// its signature, unless the git config value signist.strict is true. Only
+// the signature is ever written to standard output.
+func sshKeygen(args []string) int {
+	if len(args) == 0 || args[0] != "sign" {
+		return runSSHKeygen(args)
+	}
+
+	var namespace, keyFile string
+	var files []string
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-n", "-f", "-O":
+			if i+1 == len(args) {
+				log.Printf("signist: %s requires an argument\n", args[i])
+				return exitError
+			}
+			if args[i] == "-n" {
+				namespace = args[i+1]
+			} else if args[i] == "-f" {
+				keyFile = args[i+1]
+			}
+			i++
+		case "-U", "-q":
+		default:
+			files = append(files, args[i])
+		}
+	}
+
+	if namespace != "git" {
+		return runSSHKeygen(args)
+	}
+	if len(keyFile) == 0 || len(files) == 0 {
+		log.Println("signist: usage: signist -Y sign -n git -f key_file file ...")
+		return exitError
+	}
+
+	key, err := readSigningKey(keyFile)
+	if err != nil {
+		log.Printf("signist: error reading %s: %s\n", keyFile, err.Error())
+		return exitError
+	}
+
+	for _, file := range files {
+		var data []byte
+		if file == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(file)
+		}
+		if err != nil {
+			log.Printf("signist: %s\n", err.Error())
+			return exitError
+		}
+
+		armored, err := sshsigSign(key, namespace, data)
+		if err != nil {
+			log.Printf("signist: error signing with %s: %s\n", models.Fingerprint(key), err.Error())
+			return exitError
+		}
+
+		if file == "-" {
+			_, err = os.Stdout.Write(armored)
+		} else {
+			err = ioutil.WriteFile(file+".sig", armored, 0644)
+		}
+		if err != nil {
+			log.Printf("signist: %s\n", err.Error())
+			return exitError
+		}
+
+		if err := recordGitSignature(data, key); err != nil {
+			log.Printf("signist: could not record the signed object: %s\n", err.Error())
+			if gitStrict() {
+				return exitError
+			}
+		}
+	}
+	return 0
+}
+
+// Whether failing to record a git signature should fail the signing
+func gitStrict() bool {
+	strict, err := gitOutput("config", "--type=bool", "--get", "signist.strict")
+	return err == nil && strict == "true"
+}
+
+// Upload the object git is signing, signed with the same key. Progress and
+// errors go to standard error, since git reads the signature from standard
+// output.
+func recordGitSignature(payload []byte, key ssh.PublicKey) error {
+	name := settings.Identity
+	if len(name) == 0 {
+		return errors.New("set identity in the signist profile to record git signatures")
+	}
+
+	repo, err := gitRepoName()
+	if err != nil {
+		return err
+	}
+
+	title := gitObjectTitle(repo, payload)
+	b64Data := base64.StdEncoding.EncodeToString(payload)
+	message := models.Message{Version: models.SignedDataV2, GithubLogin: &name, Blob: &b64Data, Title: &title, RawBlob: payload}
+	message.ContentType = strPtr("application/x-git-" + gitPayloadType(payload))
+
+	message.Signatures, err = utils.SignWith(name, message.SignedData(), utils.SignOptions{KeyFingerprints: []string{models.Fingerprint(key)}})
+	if err != nil {
+		return err
+	}
+
+	api, err := client.New(apiUrl())
+	if err != nil {
+		return err
+	}
+
+	created, err := api.CreateMessage(context.Background(), &message)
+	if err != nil {
+		return err
+	}
+	log.Printf("signist: recorded %s as message %d %s\n", title, *created.ID, api.MessageURL(*created.ID))
+	return nil
+}
+
+// Make an armored SSHSIG signature over data with the agent's key
+func sshsigSign(key ssh.PublicKey, namespace string, data []byte) ([]byte, error) {
+	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sig, err := sshkeys.AgentSign(conn, key, sshkeys.SSHSIGSignedData(namespace, data))
+	if err != nil {
+		return nil, err
+	}
+	return sshkeys.ArmorSSHSIG(key, namespace, sig), nil
+}
+
+// Read the public key git names with user.signingkey, which may be a public
+// key file or a private key file with a .pub file next to it
+func readSigningKey(path string) (ssh.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, _, _, _, err := sshkeys.ParseAuthorizedKey(data); err == nil {
+		return key, nil
+	}
+
+	data, err = ioutil.ReadFile(path + ".pub")
+	if err != nil {
+		return nil, err
+	}
+	key, _, _, _, err := sshkeys.ParseAuthorizedKey(data)
+	return key, err
+}
+
+func runSSHKeygen(args []string) int {
+	cmd := exec.Command("ssh-keygen", append([]string{"-Y"}, args...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	} else if err != nil {
+		log.Printf("signist: %s\n", err.Error())
+		return exitError
+	}
+	return 0
+}
+
+func strPtr(s string) *string {
+	return &s
+}
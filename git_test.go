@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const gitTestSignature = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgJKxoLBJBivUPNTUJUSslQTt2hD
+jozKvHarKeN8uYFqgAAAADZ2l0AAAAAAAAAAZzaGE1MTIAAABTAAAAC3NzaC1lZDI1NTE5
+AAAAQLmVbUGS0ZeNhGXVWq9Wz3x0vHJDRIMBXVMe3Q4Ar1HyBHmbJfMvOxbR4rtW4EOaHz
+-----END SSH SIGNATURE-----
+`
+
+// A signature as a commit header: the first line after the header name, and
+// the rest indented by a space
+func gitHeaderSignature(header string) string {
+	lines := strings.Split(strings.TrimSuffix(gitTestSignature, "\n"), "\n")
+	return header + " " + strings.Join(lines, "\n ") + "\n"
+}
+
+func TestGitPayload(t *testing.T) {
+	commitHeaders := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"parent 1f6f1b3c2e6f3a9d5b9c8e7d6a5b4c3d2e1f0a9b\n" +
+		"author Mona Lisa Octocat <mona@example.com> 1700000000 +0000\n" +
+		"committer Mona Lisa Octocat <mona@example.com> 1700000000 +0000\n"
+	commitBody := "\nRelease v1.0.0\n\nSigned-off-by: Mona Lisa Octocat <mona@example.com>\n"
+
+	mergetag := "mergetag object 1f6f1b3c2e6f3a9d5b9c8e7d6a5b4c3d2e1f0a9b\n" +
+		" type commit\n" +
+		" tag v0.9.0\n" +
+		" tagger Mona Lisa Octocat <mona@example.com> 1690000000 +0000\n" +
+		" \n" +
+		" v0.9.0\n" +
+		" " + strings.Join(strings.Split(strings.TrimSuffix(gitTestSignature, "\n"), "\n"), "\n ") + "\n"
+
+	tagHeaders := "object 1f6f1b3c2e6f3a9d5b9c8e7d6a5b4c3d2e1f0a9b\n" +
+		"type commit\n" +
+		"tag v1.0.0\n" +
+		"tagger Mona Lisa Octocat <mona@example.com> 1700000000 +0000\n" +
+		"\n" +
+		"Release v1.0.0\n"
+
+	tests := []struct {
+		name       string
+		objectType string
+		content    string
+		payload    string
+	}{
+		{
+			name:       "unsigned commit",
+			objectType: "commit",
+			content:    commitHeaders + commitBody,
+			payload:    commitHeaders + commitBody,
+		},
+		{
+			name:       "commit signed with gpgsig",
+			objectType: "commit",
+			content:    commitHeaders + gitHeaderSignature("gpgsig") + commitBody,
+			payload:    commitHeaders + commitBody,
+		},
+		{
+			name:       "commit signed with gpgsig-sha256",
+			objectType: "commit",
+			content:    commitHeaders + gitHeaderSignature("gpgsig-sha256") + commitBody,
+			payload:    commitHeaders + commitBody,
+		},
+		{
+			name:       "commit signed for both hash algorithms",
+			objectType: "commit",
+			content:    commitHeaders + gitHeaderSignature("gpgsig") + gitHeaderSignature("gpgsig-sha256") + commitBody,
+			payload:    commitHeaders + commitBody,
+		},
+		{
+			name:       "signed merge keeps its mergetag",
+			objectType: "commit",
+			content:    commitHeaders + mergetag + gitHeaderSignature("gpgsig") + commitBody,
+			payload:    commitHeaders + mergetag + commitBody,
+		},
+		{
+			name:       "signature-like lines in the message are kept",
+			objectType: "commit",
+			content:    commitHeaders + gitHeaderSignature("gpgsig") + "\ngpgsig in the message\n " + "indented\n",
+			payload:    commitHeaders + "\ngpgsig in the message\n " + "indented\n",
+		},
+		{
+			name:       "unsigned tag",
+			objectType: "tag",
+			content:    tagHeaders,
+			payload:    tagHeaders,
+		},
+		{
+			name:       "tag with an SSH signature",
+			objectType: "tag",
+			content:    tagHeaders + gitTestSignature,
+			payload:    tagHeaders,
+		},
+		{
+			name:       "tag with a PGP signature",
+			objectType: "tag",
+			content:    tagHeaders + "-----BEGIN PGP SIGNATURE-----\n\niQEzBAABCAAdFiEE\n-----END PGP SIGNATURE-----\n",
+			payload:    tagHeaders,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if payload := string(gitPayload(test.objectType, []byte(test.content))); payload != test.payload {
+				t.Errorf("payload is\n%s\nwant\n%s", payload, test.payload)
+			}
+		})
+	}
+}
+
+func TestGitPayloadType(t *testing.T) {
+	if objectType := gitPayloadType([]byte("object 1f6f1b3c\ntype commit\n")); objectType != "tag" {
+		t.Errorf("tag payload is a %s", objectType)
+	}
+	if objectType := gitPayloadType([]byte("tree 4b825dc6\nauthor a\n")); objectType != "commit" {
+		t.Errorf("commit payload is a %s", objectType)
+	}
+}
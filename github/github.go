@@ -1,14 +1,31 @@
 package github
 
 import (
+	"fmt"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/google/go-github/github"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/sshkeys"
 	"log"
+	"net/http"
 )
 
 // Global github client
 var client = github.NewClient(nil)
 
+// Authenticate requests to github with a personal access token, which raises
+// the API rate limit
+func SetToken(token string) {
+	client = github.NewClient(&http.Client{Transport: tokenTransport(token)})
+}
+
+type tokenTransport string
+
+func (token tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+string(token))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
 // Return a github user for a particular login
 // or exit on failure
 func UserFor(login string) (*github.User, error) {
@@ -20,6 +37,12 @@ func UserFor(login string) (*github.User, error) {
 	return user, err
 }
 
+// Whether err is github reporting that a user or organization does not exist
+func IsNotFound(err error) bool {
+	resErr, ok := err.(*github.ErrorResponse)
+	return ok && resErr.Response != nil && resErr.Response.StatusCode == http.StatusNotFound
+}
+
 func isUser(user *github.User) bool {
 	return *(user.Type) == "User"
 }
@@ -29,57 +52,109 @@ func isOrg(user *github.User) bool {
 }
 
 // Return a slice containing all the publicly accessible organization admins
-func orgAdmins(org string) (admins []github.User) {
+func orgAdmins(org string) ([]github.User, error) {
 	admins, _, err := client.Organizations.ListMembers(org, &github.ListMembersOptions{Role: "admin"})
 	if err != nil {
-		log.Printf("Error getting administrators for %q: %s\n", org, err.Error())
-		return []github.User{}
+		return nil, fmt.Errorf("error getting administrators for %q: %s", org, err.Error())
 	}
-	return admins
+	return admins, nil
 }
 
-func GithubKeysForUser(user string) (pubKeys []ssh.PublicKey) {
+// Return the public keys of a github user, or an error if github could not
+// list them
+func keysForUser(user string) ([]ssh.PublicKey, error) {
 	keys, _, err := client.Users.ListKeys(user, nil)
 	if err != nil {
-		log.Printf("Error getting public keys for github user %q: %s\n", user, err.Error())
-		return []ssh.PublicKey{}
+		return nil, fmt.Errorf("error getting public keys for github user %q: %s", user, err.Error())
 	}
 
-	pubKeys = make([]ssh.PublicKey, 0, len(keys))
+	pubKeys := make([]ssh.PublicKey, 0, len(keys))
 
 	for _, key := range keys {
-		pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(*key.Key))
+		pubKey, _, _, _, err := sshkeys.ParseAuthorizedKey([]byte(*key.Key))
 		if err == nil {
 			pubKeys = append(pubKeys, pubKey)
 		}
 	}
-	return pubKeys
+	return pubKeys, nil
 }
 
-// Return all the public keys for all the admins of an org
-func GithubKeysForOrg(org string) (pubKeys []ssh.PublicKey) {
-	admins := orgAdmins(org)
-	results := make(chan []ssh.PublicKey)
-	pubKeys = []ssh.PublicKey{}
+// Return all the public keys for all the admins of an org, or an error if
+// any of them could not be listed
+func keysForOrg(org string) ([]ssh.PublicKey, error) {
+	admins, err := orgAdmins(org)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		keys []ssh.PublicKey
+		err  error
+	}
+	results := make(chan result)
 
 	for _, admin := range admins {
 		admin := admin
 		go func() {
-			results <- GithubKeysForUser(*admin.Login)
+			keys, err := keysForUser(*admin.Login)
+			results <- result{keys, err}
 		}()
 	}
 
+	pubKeys := []ssh.PublicKey{}
+	var firstErr error
 	for i := 0; i < len(admins); i++ {
-		keys := <-results
-		pubKeys = append(pubKeys, keys...)
+		r := <-results
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+		pubKeys = append(pubKeys, r.keys...)
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return pubKeys, nil
+}
+
+// Determine if a user is a user or organization and return the user's keys
+// or the keys for all admins of the org. Unlike GithubKeysFor, an incomplete
+// lookup is an error rather than fewer keys.
+func KeysFor(user *github.User) ([]ssh.PublicKey, error) {
+	if isUser(user) {
+		return keysForUser(*user.Login)
+	}
+
+	if isOrg(user) {
+		return keysForOrg(*user.Login)
+	}
+
+	return nil, fmt.Errorf("github identity %q is not a user or organization", *user.Login)
+}
+
+func GithubKeysForUser(user string) []ssh.PublicKey {
+	keys, err := keysForUser(user)
+	if err != nil {
+		log.Println(err.Error())
+		return []ssh.PublicKey{}
 	}
+	return keys
+}
 
-	return pubKeys
+// Return all the public keys for all the admins of an org, or none if any
+// of them could not be listed
+func GithubKeysForOrg(org string) []ssh.PublicKey {
+	keys, err := keysForOrg(org)
+	if err != nil {
+		log.Println(err.Error())
+		return []ssh.PublicKey{}
+	}
+	return keys
 }
 
 // Determine of a user is a user or organization and return the users keys
 // or the keys for all admins of the org
-func GithubKeysFor(user *github.User) (pubKeys []ssh.PublicKey) {
+func GithubKeysFor(user *github.User) []ssh.PublicKey {
 	if isUser(user) {
 		return GithubKeysForUser(*user.Login)
 	}
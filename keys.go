@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/gopkg.in/alecthomas/kingpin.v2"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/utils"
+)
+
+type keyReport struct {
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	InAgent     bool   `json:"in_agent"`
+	OnGithub    bool   `json:"on_github"`
+	Certificate bool   `json:"certificate,omitempty"`
+	Excluded    string `json:"excluded,omitempty"`
+}
+
+// Add --key-fingerprint and --max-signatures to a command that signs
+func signFlags(cmd *kingpin.CmdClause) (fingerprints *[]string, max *int) {
+	fingerprints = cmd.Flag("key-fingerprint", "Only sign with the key with this SHA256 fingerprint. May be repeated. Defaults to the profile's key.").Strings()
+	max = cmd.Flag("max-signatures", "Sign with at most this many keys").Int()
+	return fingerprints, max
+}
+
+// Options for signing from the command line, falling back to the profile
+func signOptions(fingerprints []string, max int) utils.SignOptions {
+	if max < 0 {
+		kingpin.Fatalf("--max-signatures can not be negative")
+	}
+	if len(fingerprints) == 0 && len(settings.Key) > 0 {
+		fingerprints = []string{settings.Key}
+	}
+	return utils.SignOptions{KeyFingerprints: fingerprints, MaxSignatures: max}
+}
+
+// List the keys in the agent and on github for name, and which would sign
+func listKeys(name string, opts utils.SignOptions) {
+	r := &report{Command: "keys", Login: name}
+
+	aks, gks, err := utils.AgentAndGithubKeys(name)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.Keys = keyReports(utils.ChooseKeys(name, aks, gks, opts))
+
+	if outputFormat() == "text" {
+		printKeyReports(r.Keys)
+	}
+
+	for _, k := range r.Keys {
+		if len(k.Excluded) == 0 {
+			succeed(r, "")
+			return
+		}
+	}
+	fail(r, exitNoMatchingKeys, "no_matching_keys", "None of the keys in the SSH agent can sign for %q", name)
+}
+
+func keyReports(choices []utils.KeyChoice) []keyReport {
+	reports := make([]keyReport, 0, len(choices))
+	for _, c := range choices {
+		reports = append(reports, keyReport{
+			Type:        c.Key.Type(),
+			Fingerprint: models.Fingerprint(c.Key),
+			InAgent:     c.InAgent,
+			OnGithub:    c.OnGithub,
+			Certificate: c.Certificate != nil,
+			Excluded:    c.Excluded,
+		})
+	}
+	return reports
+}
+
+func printKeyReports(reports []keyReport) {
+	for _, k := range reports {
+		if len(k.Excluded) == 0 {
+			fmt.Printf("  %s %s: signs\n", k.Fingerprint, k.Type)
+		} else {
+			fmt.Printf("  %s %s: skipped, %s\n", k.Fingerprint, k.Type, k.Excluded)
+		}
+	}
+}
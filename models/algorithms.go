@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/sshkeys"
+)
+
+// Which signature formats and key types are accepted. DSA keys are never
+// accepted, whatever KeyTypes lists.
+type AlgorithmPolicy struct {
+	SignatureFormats []string
+	KeyTypes         []string
+	MinRSABits       int
+}
+
+// SHA-2 signatures from RSA keys of at least 2048 bits, ECDSA and security
+// keys. Legacy ssh-rsa signatures, which use SHA-1, are not accepted.
+var DefaultAlgorithmPolicy = AlgorithmPolicy{
+	SignatureFormats: []string{
+		sshkeys.SigAlgoRSASHA256,
+		sshkeys.SigAlgoRSASHA512,
+		ssh.KeyAlgoECDSA256,
+		ssh.KeyAlgoECDSA384,
+		ssh.KeyAlgoECDSA521,
+		sshkeys.KeyAlgoSKECDSA256,
+		sshkeys.KeyAlgoSKED25519,
+	},
+	KeyTypes: []string{
+		ssh.KeyAlgoRSA,
+		ssh.KeyAlgoECDSA256,
+		ssh.KeyAlgoECDSA384,
+		ssh.KeyAlgoECDSA521,
+		sshkeys.KeyAlgoSKECDSA256,
+		sshkeys.KeyAlgoSKED25519,
+	},
+	MinRSABits: 2048,
+}
+
+// The policy new signatures are held to
+var Algorithms = DefaultAlgorithmPolicy
+
+// Check the format of a verified signature and the key that made it
+func (policy AlgorithmPolicy) Check(sig *Signature) *Error {
+	if !containsString(policy.SignatureFormats, *sig.Format) {
+		return &Error{
+			Fields:  []string{"format"},
+			Code:    CodeFormatNotAllowed,
+			Message: fmt.Sprintf("Signature format %s is not allowed", *sig.Format),
+		}
+	}
+
+	return policy.CheckKey(sig.Key.PublicKey)
+}
+
+// Check the type and size of a key
+func (policy AlgorithmPolicy) CheckKey(key ssh.PublicKey) *Error {
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+
+	keyType := key.Type()
+	if keyType == ssh.KeyAlgoDSA || !containsString(policy.KeyTypes, keyType) {
+		return &Error{
+			Fields:  []string{"key"},
+			Code:    CodeKeyTypeNotAllowed,
+			Message: fmt.Sprintf("Keys of type %s are not allowed", keyType),
+		}
+	}
+
+	if bits := sshkeys.RSABits(key); bits > 0 && bits < policy.MinRSABits {
+		return &Error{
+			Fields:  []string{"key"},
+			Code:    CodeKeyTooWeak,
+			Message: fmt.Sprintf("RSA keys must be at least %d bits, not %d", policy.MinRSABits, bits),
+		}
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/sshkeys"
+	"testing"
+)
+
+func rsaKey(t *testing.T, bits int) ssh.PublicKey {
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func ecdsaKey(t *testing.T) ssh.PublicKey {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestCheckKeyRSABitFloor(t *testing.T) {
+	rsa1024, rsa2048, rsa3072 := rsaKey(t, 1024), rsaKey(t, 2048), rsaKey(t, 3072)
+
+	strict := DefaultAlgorithmPolicy
+	strict.MinRSABits = 3072
+
+	noRSA := DefaultAlgorithmPolicy
+	noRSA.KeyTypes = []string{ssh.KeyAlgoECDSA256}
+
+	tests := []struct {
+		name   string
+		policy AlgorithmPolicy
+		key    ssh.PublicKey
+		code   string
+	}{
+		{"1024 bits under the default floor", DefaultAlgorithmPolicy, rsa1024, CodeKeyTooWeak},
+		{"2048 bits at the default floor", DefaultAlgorithmPolicy, rsa2048, ""},
+		{"3072 bits over the default floor", DefaultAlgorithmPolicy, rsa3072, ""},
+		{"2048 bits under a raised floor", strict, rsa2048, CodeKeyTooWeak},
+		{"3072 bits at a raised floor", strict, rsa3072, ""},
+		{"floor does not apply to ecdsa", strict, ecdsaKey(t), ""},
+		{"rsa not an allowed type", noRSA, rsa3072, CodeKeyTypeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.CheckKey(tt.key)
+			if tt.code == "" && err != nil {
+				t.Fatalf("rejected: %s", err.Message)
+			}
+			if tt.code != "" && (err == nil || err.Code != tt.code) {
+				t.Fatalf("got %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestCheckRSASignatureFormats(t *testing.T) {
+	rsa1024, rsa2048 := rsaKey(t, 1024), rsaKey(t, 2048)
+
+	sha512Only := DefaultAlgorithmPolicy
+	sha512Only.SignatureFormats = []string{sshkeys.SigAlgoRSASHA512}
+
+	tests := []struct {
+		name   string
+		policy AlgorithmPolicy
+		format string
+		key    ssh.PublicKey
+		code   string
+	}{
+		{"rsa-sha2-256", DefaultAlgorithmPolicy, sshkeys.SigAlgoRSASHA256, rsa2048, ""},
+		{"rsa-sha2-512", DefaultAlgorithmPolicy, sshkeys.SigAlgoRSASHA512, rsa2048, ""},
+		{"sha-1 ssh-rsa", DefaultAlgorithmPolicy, ssh.KeyAlgoRSA, rsa2048, CodeFormatNotAllowed},
+		{"rsa-sha2-256 from a weak key", DefaultAlgorithmPolicy, sshkeys.SigAlgoRSASHA256, rsa1024, CodeKeyTooWeak},
+		{"rsa-sha2-256 when only 512 is allowed", sha512Only, sshkeys.SigAlgoRSASHA256, rsa2048, CodeFormatNotAllowed},
+		{"rsa-sha2-512 when only 512 is allowed", sha512Only, sshkeys.SigAlgoRSASHA512, rsa2048, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := tt.format
+			err := tt.policy.Check(&Signature{Format: &format, Key: &PublicKey{tt.key}})
+			if tt.code == "" && err != nil {
+				t.Fatalf("rejected: %s", err.Message)
+			}
+			if tt.code != "" && (err == nil || err.Code != tt.code) {
+				t.Fatalf("got %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
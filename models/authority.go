@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"strings"
+	"time"
+)
+
+// Signed requests that change what an identity trusts, or reveal what only
+// it may see, expire so that a captured request cannot be replayed once it
+// has been undone. They may expire at most this far in the future.
+const MaxRequestValidity = 10 * time.Minute
+
+// An OpenSSH certificate authority an identity trusts to certify its signing
+// keys. Adding one must be signed by one of the identity's github keys: keys
+// certified by a trusted authority cannot add others.
+type CertificateAuthority struct {
+	ID          *int            `json:"id,omitempty" db:"id"`
+	GithubLogin *string         `json:"github_login" db:"github_login" binding:"required"`
+	GithubID    *int            `json:"-" db:"github_id"`
+	GithubKeys  []ssh.PublicKey `json:"-"`
+	Key         *PublicKey      `json:"key" db:"key" binding:"required"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty" db:"-" binding:"required"`
+	Signatures  []*Signature    `json:"signatures,omitempty" db:"-" binding:"required"`
+	CreatedAt   *time.Time      `json:"created_at,omitempty" db:"created_at"`
+}
+
+// A request to stop trusting a certificate authority, signed by one of the
+// identity's github keys
+type CertificateAuthorityDeletion struct {
+	AuthorityID *int            `json:"authority_id" binding:"required"`
+	GithubLogin *string         `json:"github_login" binding:"required"`
+	GithubID    *int            `json:"-"`
+	GithubKeys  []ssh.PublicKey `json:"-"`
+	Signatures  []*Signature    `json:"signatures" binding:"required"`
+}
+
+// The bytes that must be signed to trust a certificate authority
+func CertificateAuthorityData(login string, key ssh.PublicKey, expiresAt time.Time) []byte {
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	return []byte(fmt.Sprintf("signist certificate authority\ngithub_login: %s\nkey: %s\nexpires_at: %s\n", login, authorizedKey, expiresAt.UTC().Format(time.RFC3339)))
+}
+
+// The bytes that must be signed to stop trusting a certificate authority
+func CertificateAuthorityDeletionData(authorityID int) []byte {
+	return []byte(fmt.Sprintf("signist certificate authority deletion\nauthority_id: %d\n", authorityID))
+}
+
+func (ca *CertificateAuthority) ValidateGithubLogin() *Error {
+	var err *Error
+	ca.GithubID, ca.GithubKeys, err = lookupGithubLogin(*ca.GithubLogin)
+	return err
+}
+
+func (ca *CertificateAuthority) ValidateKey() *Error {
+	if _, ok := ca.Key.PublicKey.(*ssh.Certificate); ok {
+		return &Error{
+			Fields:  []string{"key"},
+			Code:    CodeInvalidInput,
+			Message: "A certificate authority must be a plain public key, not a certificate",
+		}
+	}
+	return nil
+}
+
+func (ca *CertificateAuthority) ValidateSignaturesLength() *Error {
+	if len(ca.Signatures) == 0 {
+		return &Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a certificate authority",
+		}
+	}
+	return nil
+}
+
+func (ca *CertificateAuthority) Validate() Errors {
+	var err *Error
+	errors := Errors{}
+
+	if required := requiredErrors(ca); len(required) > 0 {
+		return append(errors, required...)
+	}
+
+	if err = validateRequestExpiry(*ca.ExpiresAt); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = ca.ValidateGithubLogin(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = ca.ValidateKey(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = ca.ValidateSignaturesLength(); err != nil {
+		return append(errors, *err)
+	}
+
+	return verifySignaturesBy(ca.Signatures, *ca.GithubLogin, ca.GithubKeys, nil, CertificateAuthorityData(*ca.GithubLogin, ca.Key.PublicKey, *ca.ExpiresAt))
+}
+
+func (del *CertificateAuthorityDeletion) Validate() Errors {
+	errors := Errors{}
+
+	if required := requiredErrors(del); len(required) > 0 {
+		return append(errors, required...)
+	}
+
+	var err *Error
+	if del.GithubID, del.GithubKeys, err = lookupGithubLogin(*del.GithubLogin); err != nil {
+		return append(errors, *err)
+	}
+
+	if len(del.Signatures) == 0 {
+		return append(errors, Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a certificate authority deletion",
+		})
+	}
+
+	return verifySignaturesBy(del.Signatures, *del.GithubLogin, del.GithubKeys, nil, CertificateAuthorityDeletionData(*del.AuthorityID))
+}
+
+// A signed request must not have expired, nor expire further ahead than
+// MaxRequestValidity
+func validateRequestExpiry(expiresAt time.Time) *Error {
+	now := time.Now()
+	if expiresAt.Before(now) {
+		return &Error{
+			Fields:  []string{"expires_at"},
+			Code:    CodeRequestExpired,
+			Message: "The signed request has expired",
+		}
+	}
+
+	if expiresAt.After(now.Add(MaxRequestValidity)) {
+		return &Error{
+			Fields:  []string{"expires_at"},
+			Code:    CodeRequestExpired,
+			Message: fmt.Sprintf("Signed requests may expire at most %s ahead", MaxRequestValidity),
+		}
+	}
+	return nil
+}
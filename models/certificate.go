@@ -0,0 +1,83 @@
+package models
+
+import (
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/sshkeys"
+	"time"
+)
+
+// Looks up the certificate authorities an identity trusts to certify its
+// signing keys. When nil, no certificate is trusted.
+var CertificateAuthoritiesFor func(githubID int) ([]ssh.PublicKey, error)
+
+// Verify a signature made with the key of sig.Certificate, which must be a
+// user certificate naming login as a principal, valid at the given time and
+// signed by one of cas
+func (sig *Signature) VerifyCertificate(cas []ssh.PublicKey, login string, at time.Time, data []byte) *Error {
+	cert, ok := sig.Certificate.PublicKey.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return &Error{
+			Fields:  []string{"certificate"},
+			Code:    CodeCertificateInvalid,
+			Message: "Certificate must be an OpenSSH user certificate",
+		}
+	}
+
+	if len(cert.ValidPrincipals) == 0 {
+		return &Error{
+			Fields:  []string{"certificate"},
+			Code:    CodeCertificateInvalid,
+			Message: "Certificate must name the signing identity as a principal",
+		}
+	}
+
+	checker := ssh.CertChecker{
+		IsAuthority: func(key ssh.PublicKey) bool { return PublicKey{key}.In(cas) },
+		Clock:       func() time.Time { return at },
+	}
+	if err := checker.CheckCert(login, cert); err != nil {
+		return &Error{
+			Fields:  []string{"certificate"},
+			Code:    CodeCertificateInvalid,
+			Message: "Certificate is not trusted: " + err.Error(),
+		}
+	}
+
+	if err := sshkeys.Verify(cert.Key, data, &ssh.Signature{Format: *sig.Format, Blob: sig.RawBlob}); err != nil {
+		return &Error{
+			Fields:  []string{"certificate", "blob", "message.blob"},
+			Code:    CodeSignatureInvalid,
+			Message: "Certificate could not verify signature against message.blob",
+		}
+	}
+
+	sig.Key = &PublicKey{cert.Key}
+	return nil
+}
+
+// Look up the certificate authorities for githubID, if any of sigs were made
+// with a certificate
+func loadCertificateAuthorities(githubID int, sigs []*Signature) ([]ssh.PublicKey, *Error) {
+	if CertificateAuthoritiesFor == nil || !hasCertificates(sigs) {
+		return nil, nil
+	}
+
+	cas, err := CertificateAuthoritiesFor(githubID)
+	if err != nil {
+		return nil, &Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInternal,
+			Message: "Certificate authorities could not be loaded",
+		}
+	}
+	return cas, nil
+}
+
+func hasCertificates(sigs []*Signature) bool {
+	for _, sig := range sigs {
+		if sig != nil && sig.Certificate != nil {
+			return true
+		}
+	}
+	return false
+}
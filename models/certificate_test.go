@@ -0,0 +1,95 @@
+package models
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"testing"
+	"time"
+)
+
+func ecdsaSigner(t *testing.T) ssh.Signer {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	ca, otherCA := ecdsaSigner(t), ecdsaSigner(t)
+	user, other := ecdsaSigner(t), ecdsaSigner(t)
+	data := []byte("signist message v2\n\nhello")
+
+	issuedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	during := issuedAt.Add(12 * time.Hour)
+
+	certify := func(change func(cert *ssh.Certificate), authority ssh.Signer) *ssh.Certificate {
+		cert := &ssh.Certificate{
+			Key:             user.PublicKey(),
+			CertType:        ssh.UserCert,
+			KeyId:           "octocat@example.com",
+			ValidPrincipals: []string{"octocat"},
+			ValidAfter:      uint64(issuedAt.Unix()),
+			ValidBefore:     uint64(issuedAt.Add(24 * time.Hour).Unix()),
+		}
+		if change != nil {
+			change(cert)
+		}
+		if err := cert.SignCert(rand.Reader, authority); err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	tests := []struct {
+		name   string
+		cert   *ssh.Certificate
+		signer ssh.Signer
+		at     time.Time
+		code   string
+	}{
+		{"valid when signed, though expired now", certify(nil, ca), user, during, ""},
+		{"another principal", certify(func(c *ssh.Certificate) { c.ValidPrincipals = []string{"hubot"} }, ca), user, during, CodeCertificateInvalid},
+		{"no principals", certify(func(c *ssh.Certificate) { c.ValidPrincipals = nil }, ca), user, during, CodeCertificateInvalid},
+		{"before valid after", certify(nil, ca), user, issuedAt.Add(-time.Second), CodeCertificateInvalid},
+		{"at valid after", certify(nil, ca), user, issuedAt, ""},
+		{"after valid before", certify(nil, ca), user, issuedAt.Add(24*time.Hour + time.Second), CodeCertificateInvalid},
+		{"untrusted authority", certify(nil, otherCA), user, during, CodeCertificateInvalid},
+		{"host certificate", certify(func(c *ssh.Certificate) { c.CertType = ssh.HostCert }, ca), user, during, CodeCertificateInvalid},
+		{"unsupported critical option", certify(func(c *ssh.Certificate) {
+			c.CriticalOptions = map[string]string{"force-command": "/bin/true"}
+		}, ca), user, during, CodeCertificateInvalid},
+		{"signed by a key other than the certificate's", certify(nil, ca), other, during, CodeSignatureInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sshSig, err := test.signer.Sign(rand.Reader, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig := &Signature{Format: &sshSig.Format, RawBlob: sshSig.Blob, Certificate: &PublicKey{test.cert}}
+
+			verr := sig.VerifyCertificate([]ssh.PublicKey{ca.PublicKey()}, "octocat", test.at, data)
+			switch {
+			case test.code == "" && verr != nil:
+				t.Fatalf("error is %s, want none", verr.Message)
+			case test.code != "" && verr == nil:
+				t.Fatalf("no error, want %s", test.code)
+			case test.code != "" && verr.Code != test.code:
+				t.Fatalf("error is %s (%s), want %s", verr.Code, verr.Message, test.code)
+			}
+
+			if test.code == "" && !(PublicKey{user.PublicKey()}).In([]ssh.PublicKey{sig.Key.PublicKey}) {
+				t.Errorf("signature key is not the certified key")
+			}
+		})
+	}
+}
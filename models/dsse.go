@@ -0,0 +1,203 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/sshkeys"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Messages with this content type have a DSSE envelope as their blob. Each
+// envelope signature is an SSH signature in wire format over the envelope's
+// PAE encoding, and must verify with one of the identity's keys.
+const (
+	ContentTypeDSSE   = "application/vnd.dsse.envelope.v1+json"
+	PayloadTypeInToto = "application/vnd.in-toto+json"
+)
+
+var (
+	// The in-toto DigestSet key grammar, which includes mixed case names
+	// like gitCommit and dirHash and underscores like sha512_224
+	digestAlgorithmRegex = regexp.MustCompile(`\A[a-zA-Z\d][a-zA-Z\d_\-]{0,63}\z`)
+	digestRegex          = regexp.MustCompile(`\A[a-f\d]{1,256}\z`)
+)
+
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// An in-toto statement, with only the fields signist reads
+type Statement struct {
+	Type          string             `json:"_type"`
+	Subject       []StatementSubject `json:"subject"`
+	PredicateType string             `json:"predicateType"`
+}
+
+type StatementSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// One digest of an in-toto subject, indexed for lookup
+type Subject struct {
+	Name      string `json:"name" db:"name"`
+	Algorithm string `json:"algorithm" db:"algorithm"`
+	Digest    string `json:"digest" db:"digest"`
+}
+
+// The DSSE pre-authentication encoding of a payload, which is what envelope
+// signatures cover
+func PAE(payloadType string, payload []byte) []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString("DSSEv1 ")
+	buf.WriteString(strconv.Itoa(len(payloadType)) + " " + payloadType + " ")
+	buf.WriteString(strconv.Itoa(len(payload)) + " ")
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// Encode a signature over PAE as an envelope signature
+func NewEnvelopeSignature(sig *Signature) (EnvelopeSignature, error) {
+	blob, err := base64.StdEncoding.DecodeString(*sig.Blob)
+	if err != nil {
+		return EnvelopeSignature{}, err
+	}
+
+	wire := sshkeys.MarshalSignature(&ssh.Signature{Format: *sig.Format, Blob: blob})
+	return EnvelopeSignature{KeyID: Fingerprint(sig.Key.PublicKey), Sig: base64.StdEncoding.EncodeToString(wire)}, nil
+}
+
+// Check that a DSSE message's blob is an envelope whose signatures all
+// verify against the identity's keys, and record its in-toto subjects
+func (message *Message) ValidateEnvelope() Errors {
+	message.Subjects = nil
+	if message.ContentType == nil || *message.ContentType != ContentTypeDSSE {
+		return nil
+	}
+
+	envelope := Envelope{}
+	if err := json.Unmarshal(message.RawBlob, &envelope); err != nil {
+		return Errors{*envelopeError("Blob must be a DSSE envelope: " + err.Error())}
+	}
+	if len(envelope.PayloadType) == 0 {
+		return Errors{*envelopeError("Envelope must have a payloadType")}
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return Errors{*envelopeError("Envelope payload could not be decoded as base64")}
+	}
+	if len(envelope.Signatures) == 0 {
+		return Errors{*envelopeError("Envelope must have at least one signature")}
+	}
+
+	keys := message.GithubKeys
+	for _, sig := range message.Signatures {
+		if sig.Certificate != nil && sig.Key != nil {
+			keys = append(keys, sig.Key.PublicKey)
+		}
+	}
+
+	errors := Errors{}
+	data := PAE(envelope.PayloadType, payload)
+	for i, envSig := range envelope.Signatures {
+		if err := verifyEnvelopeSignature(envSig, keys, data); err != nil {
+			err.Message = fmt.Sprintf("Envelope signature %d: %s", i, err.Message)
+			errors = append(errors, *err)
+		}
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+
+	if envelope.PayloadType == PayloadTypeInToto {
+		subjects, err := parseSubjects(payload)
+		if err != nil {
+			return Errors{*err}
+		}
+		message.Subjects = subjects
+	}
+	return nil
+}
+
+func verifyEnvelopeSignature(envSig EnvelopeSignature, keys []ssh.PublicKey, data []byte) *Error {
+	wire, err := base64.StdEncoding.DecodeString(envSig.Sig)
+	if err != nil {
+		return envelopeError("sig could not be decoded as base64")
+	}
+	parsed, err := sshkeys.ParseSignature(wire)
+	if err != nil {
+		return envelopeError("sig must be an SSH signature in wire format")
+	}
+
+	sig := &Signature{Format: &parsed.Format, RawBlob: parsed.Blob}
+	if err := sig.Verify(keys, data); err != nil {
+		err.Fields = []string{"blob"}
+		err.Message = "No key of the signing identity verifies the signature over the envelope's PAE encoding"
+		return err
+	}
+	return Algorithms.Check(sig)
+}
+
+// The digests of the subjects of an in-toto statement
+func parseSubjects(payload []byte) ([]Subject, *Error) {
+	statement := Statement{}
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, envelopeError("Payload must be an in-toto statement: " + err.Error())
+	}
+	if len(statement.Subject) == 0 {
+		return nil, envelopeError("In-toto statement must have at least one subject")
+	}
+
+	subjects := []Subject{}
+	for _, s := range statement.Subject {
+		if len(s.Digest) == 0 {
+			return nil, envelopeError(fmt.Sprintf("Subject %q must have a digest", s.Name))
+		}
+		for algorithm, digest := range s.Digest {
+			digest = strings.ToLower(digest)
+			if !digestAlgorithmRegex.MatchString(algorithm) || !digestRegex.MatchString(digest) {
+				return nil, envelopeError(fmt.Sprintf("Subject %q has an invalid %s digest", s.Name, algorithm))
+			}
+			subjects = append(subjects, Subject{Name: s.Name, Algorithm: algorithm, Digest: digest})
+		}
+	}
+
+	// Digests come from a map, so order them to store them the same way each
+	// time
+	sort.Slice(subjects, func(i, j int) bool {
+		if subjects[i].Name != subjects[j].Name {
+			return subjects[i].Name < subjects[j].Name
+		}
+		if subjects[i].Algorithm != subjects[j].Algorithm {
+			return subjects[i].Algorithm < subjects[j].Algorithm
+		}
+		return subjects[i].Digest < subjects[j].Digest
+	})
+	return subjects, nil
+}
+
+// Check a digest algorithm and hex digest given to look subjects up by
+func ValidDigest(algorithm string, digest string) bool {
+	return digestAlgorithmRegex.MatchString(algorithm) && digestRegex.MatchString(digest)
+}
+
+func envelopeError(message string) *Error {
+	return &Error{
+		Fields:  []string{"blob"},
+		Code:    CodeEnvelopeInvalid,
+		Message: message,
+	}
+}
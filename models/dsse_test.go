@@ -0,0 +1,73 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		subjects []Subject
+		hasError bool
+	}{
+		{
+			name:     "sha256",
+			payload:  `{"subject": [{"name": "a", "digest": {"sha256": "AB12"}}]}`,
+			subjects: []Subject{{Name: "a", Algorithm: "sha256", Digest: "ab12"}},
+		},
+		{
+			name:    "mixed case and underscored algorithms",
+			payload: `{"subject": [{"name": "repo", "digest": {"sha512_224": "03", "gitTree": "02", "gitCommit": "01", "dirHash": "04"}}]}`,
+			subjects: []Subject{
+				{Name: "repo", Algorithm: "dirHash", Digest: "04"},
+				{Name: "repo", Algorithm: "gitCommit", Digest: "01"},
+				{Name: "repo", Algorithm: "gitTree", Digest: "02"},
+				{Name: "repo", Algorithm: "sha512_224", Digest: "03"},
+			},
+		},
+		{
+			name:    "sorted by name then algorithm",
+			payload: `{"subject": [{"name": "b", "digest": {"sha512": "02", "sha256": "01"}}, {"name": "a", "digest": {"sha256": "03"}}]}`,
+			subjects: []Subject{
+				{Name: "a", Algorithm: "sha256", Digest: "03"},
+				{Name: "b", Algorithm: "sha256", Digest: "01"},
+				{Name: "b", Algorithm: "sha512", Digest: "02"},
+			},
+		},
+		{
+			name:     "algorithm with a slash",
+			payload:  `{"subject": [{"name": "a", "digest": {"sha/256": "01"}}]}`,
+			hasError: true,
+		},
+		{
+			name:     "digest that is not hex",
+			payload:  `{"subject": [{"name": "a", "digest": {"gitCommit": "xyz"}}]}`,
+			hasError: true,
+		},
+		{
+			name:     "no subjects",
+			payload:  `{"subject": []}`,
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subjects, err := parseSubjects([]byte(tt.payload))
+			if tt.hasError {
+				if err == nil {
+					t.Fatalf("parsed %v", subjects)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Message)
+			}
+			if !reflect.DeepEqual(subjects, tt.subjects) {
+				t.Fatalf("got %v, want %v", subjects, tt.subjects)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+// Machine readable error codes. These are stable: clients may match on them,
+// so codes are only ever added.
+const (
+	// The request body was not JSON, or too large (413)
+	CodeUnsupportedContentType = "unsupported_content_type"
+	CodeInvalidJSON            = "invalid_json"
+	CodeBodyTooLarge           = "body_too_large"
+
+	// A field failed validation (422)
+	CodeValidationFailed   = "validation_failed"
+	CodeRequired           = "required"
+	CodeInvalidInput       = "invalid_input"
+	CodeIdentityNotFound   = "identity_not_found"
+	CodeSignatureInvalid   = "signature_invalid"
+	CodeKeyNotAuthorized   = "key_not_authorized"
+	CodeValidityPolicy     = "validity_policy_violation"
+	CodeCertificateInvalid = "certificate_invalid"
+	CodeFormatNotAllowed   = "signature_format_not_allowed"
+	CodeKeyTypeNotAllowed  = "key_type_not_allowed"
+	CodeKeyTooWeak         = "key_too_weak"
+	CodeEnvelopeInvalid    = "envelope_invalid"
+	CodeThresholdNotMet    = "threshold_not_met"
+	CodeRequestExpired     = "request_expired"
+
+	// The request was well formed but could not be carried out
+	CodeNotFound             = "not_found"                       // 404
+	CodeMessageNotFound      = "message_not_found"               // 404
+	CodeMethodNotAllowed     = "method_not_allowed"              // 405
+	CodeAlreadyRevoked       = "already_revoked"                 // 409
+	CodeWebhookNotFound      = "webhook_not_found"               // 404
+	CodeWebhookExists        = "webhook_exists"                  // 409
+	CodeRevocationMismatch   = "revocation_mismatch"             // 400
+	CodeInternal             = "internal_error"                  // 500
+	CodeTimestampUnavailable = "timestamp_unavailable"           // 503
+	CodeReadOnly             = "read_only"                       // 405
+	CodeQuotaExceeded        = "quota_exceeded"                  // 429
+	CodeAuthorityNotFound    = "certificate_authority_not_found" // 404
+	CodeAuthorityExists      = "certificate_authority_exists"    // 409
+)
+
+// A single problem with a request. SignatureIndex is set when the problem is
+// with one signature, and is that signature's position in the request.
+type Error struct {
+	Code           string   `json:"code"`
+	Message        string   `json:"message"`
+	Fields         []string `json:"fields,omitempty"`
+	SignatureIndex *int     `json:"signature_index,omitempty"`
+}
+
+func (err Error) Error() string {
+	return err.Code + ": " + err.Message
+}
+
+type Errors []Error
+
+// Attribute each error to the signature at index
+func indexErrors(errors Errors, index int) Errors {
+	for i := range errors {
+		errors[i].SignatureIndex = &index
+	}
+	return errors
+}
+
+// The body of every error response from the server:
+//
+//	{"error": {"code": "signature_invalid", "message": "...", "request_id": "...", "details": [...]}}
+//
+// Details lists each underlying problem when the error is a validation
+// failure.
+type ErrorEnvelope struct {
+	Error APIError `json:"error"`
+}
+
+type APIError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Details   Errors `json:"details,omitempty"`
+}
+
+func (err APIError) Error() string {
+	return err.Code + ": " + err.Message
+}
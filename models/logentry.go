@@ -0,0 +1,160 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"time"
+)
+
+// The kind of every signist log entry, and its only version. It is not a
+// Rekor type: the signatures are SSH signatures over a message's signed data,
+// not over an artifact digest, so they do not fit hashedrekord, and Rekor
+// clients cannot decode signist entry bodies.
+const (
+	LogEntryKind       = "signist"
+	LogEntryAPIVersion = "0.0.1"
+)
+
+// The body of a log entry, in the shape of a Rekor proposed entry. The spec
+// holds the message or revocation as it was stored when it was logged, so
+// its status fields are always false.
+type LogEntryBody struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Spec       LogEntrySpec `json:"spec"`
+}
+
+type LogEntrySpec struct {
+	Message    *Message    `json:"message,omitempty"`
+	Revocation *Revocation `json:"revocation,omitempty"`
+}
+
+// A log entry as the Rekor API serves it, keyed by its UUID: the hex leaf
+// hash of the body
+type LogEntry struct {
+	Body           string           `json:"body"`
+	IntegratedTime int64            `json:"integratedTime"`
+	LogID          string           `json:"logID"`
+	LogIndex       int              `json:"logIndex"`
+	Verification   *LogVerification `json:"verification,omitempty"`
+}
+
+// Decode the entry's base64 body
+func (entry *LogEntry) Spec() (LogEntrySpec, error) {
+	data, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil {
+		return LogEntrySpec{}, err
+	}
+
+	body := LogEntryBody{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return LogEntrySpec{}, err
+	}
+	return body.Spec, nil
+}
+
+type LogVerification struct {
+	InclusionProof       *InclusionProof `json:"inclusionProof,omitempty"`
+	SignedEntryTimestamp string          `json:"signedEntryTimestamp,omitempty"`
+}
+
+type InclusionProof struct {
+	LogIndex   int      `json:"logIndex"`
+	RootHash   string   `json:"rootHash"`
+	TreeSize   int      `json:"treeSize"`
+	Hashes     []string `json:"hashes"`
+	Checkpoint string   `json:"checkpoint,omitempty"`
+}
+
+// A log entry as GET /stream sends it. Body is the base64 body the entry's
+// UUID is the leaf hash of, and Spec is the same body decoded.
+type LogEvent struct {
+	UUID           string       `json:"uuid"`
+	LogIndex       int          `json:"logIndex"`
+	IntegratedTime int64        `json:"integratedTime"`
+	Body           string       `json:"body"`
+	Spec           LogEntrySpec `json:"spec"`
+}
+
+type LogInfo struct {
+	RootHash       string `json:"rootHash"`
+	TreeSize       int    `json:"treeSize"`
+	SignedTreeHead string `json:"signedTreeHead"`
+	TreeID         string `json:"treeID"`
+}
+
+// The most log entries GET /api/v1/log/entries returns at once
+const MaxLogEntryRange = 100
+
+// How far a mirror has followed its upstream's log, and the entries it
+// logged without being able to verify them. Their messages and revocations
+// are not served.
+type MirrorStatus struct {
+	Upstream    string             `json:"upstream"`
+	TreeSize    int                `json:"treeSize"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	Quarantined []QuarantinedEntry `json:"quarantined"`
+}
+
+type QuarantinedEntry struct {
+	LogIndex      int       `json:"logIndex" db:"log_index"`
+	UUID          string    `json:"uuid" db:"uuid"`
+	Reason        string    `json:"reason" db:"reason"`
+	QuarantinedAt time.Time `json:"quarantinedAt" db:"quarantined_at"`
+}
+
+type ConsistencyProof struct {
+	RootHash string   `json:"rootHash"`
+	Hashes   []string `json:"hashes"`
+}
+
+// A search of the log index. Only hash, "sha256:<hex>" of a message blob,
+// is supported.
+type SearchIndex struct {
+	Hash      string      `json:"hash,omitempty"`
+	PublicKey interface{} `json:"publicKey,omitempty"`
+	Email     string      `json:"email,omitempty"`
+}
+
+// Check a proposed entry, which must hold a message to create
+func (body *LogEntryBody) Validate() Errors {
+	if body.Kind != LogEntryKind || body.APIVersion != LogEntryAPIVersion {
+		return Errors{{
+			Fields:  []string{"kind", "apiVersion"},
+			Code:    CodeInvalidInput,
+			Message: "Only entries of kind " + LogEntryKind + " version " + LogEntryAPIVersion + " are supported",
+		}}
+	}
+
+	if body.Spec.Message == nil || body.Spec.Revocation != nil {
+		return Errors{{
+			Fields:  []string{"spec.message"},
+			Code:    CodeRequired,
+			Message: "Proposed entries must have a message, revocations are made with DELETE /{github_id}/{message_id}",
+		}}
+	}
+
+	return body.Spec.Message.Validate()
+}
+
+var searchHashRegex = regexp.MustCompile(`\A(sha256:)?[a-fA-F\d]{64}\z`)
+
+func (search *SearchIndex) Validate() Errors {
+	if search.PublicKey != nil || len(search.Email) > 0 {
+		return Errors{{
+			Fields:  []string{"publicKey", "email"},
+			Code:    CodeInvalidInput,
+			Message: "Only searching by hash is supported",
+		}}
+	}
+
+	if !searchHashRegex.MatchString(search.Hash) {
+		return Errors{{
+			Fields:  []string{"hash"},
+			Code:    CodeInvalidInput,
+			Message: "hash must be a SHA-256 digest, sha256:<hex>",
+		}}
+	}
+	return nil
+}
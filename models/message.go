@@ -1,62 +1,172 @@
 package models
 
 import (
+	"bytes"
 	"encoding/base64"
-	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/martini-contrib/binding"
+	"fmt"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
 	"github.com/andrewhamon/signist/github"
-	"net/http"
+	"mime"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 )
 
-var slugRegex = regexp.MustCompile(`\A[a-zA-Z\d\-\_]+\z`)
+// A title segment is a slug, optionally with single dots between runs of
+// slug characters for versions like v1.4.2
+var segmentRegex = regexp.MustCompile(`\A[a-zA-Z\d\-\_]+(\.[a-zA-Z\d\-\_]+)*\z`)
+
+const (
+	MaxTitleLength   = 255
+	MaxTitleSegments = 16
+)
+
+// Check that a title is made of slash separated segments, such as
+// api-server/v1.4.2. Empty, "." and ".." segments are never valid.
+func ValidTitle(title string) bool {
+	segments := strings.Split(title, "/")
+	if len(title) > MaxTitleLength || len(segments) > MaxTitleSegments {
+		return false
+	}
+
+	for _, segment := range segments {
+		if !segmentRegex.MatchString(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check that a prefix used to list titles is a valid title, optionally
+// followed by a slash
+func ValidTitlePrefix(prefix string) bool {
+	return ValidTitle(strings.TrimSuffix(prefix, "/"))
+}
+
+// Versions of the encoding SignedData covers. Messages stored before versions
+// existed are SignedDataLegacy, and new messages must be SignedDataV2.
+const (
+	SignedDataLegacy = 1
+	SignedDataV2     = 2
+)
 
 type Message struct {
 	ID          *int            `json:"id,omitempty"`
-	GithubLogin *string         `json:"github_login" binding:"required"`
+	Version     int             `json:"version" db:"version"`
+	GithubLogin *string         `json:"github_login" db:"github_login" binding:"required"`
 	GithubID    *int            `json:"-" db:"github_id"`
 	GithubKeys  []ssh.PublicKey `json:"-"`
+	TrustedCAs  []ssh.PublicKey `json:"-"`
 	Title       *string         `json:"title" binding:"required"`
 	Blob        *string         `json:"blob" binding:"required"`
 	RawBlob     []byte          `json:"-"`
+	ContentType *string         `json:"content_type,omitempty" db:"content_type"`
+	Metadata    Metadata        `json:"metadata,omitempty" db:"metadata"`
 	Signatures  []*Signature    `json:"signatures" binding:"required"`
+	NotBefore   *time.Time      `json:"not_before,omitempty" db:"not_before"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty" db:"expires_at"`
 	CreatedAt   *time.Time      `json:"created_at,omitempty" db:"created_at"`
+	Revoked     bool            `json:"revoked" db:"-"`
+	Revocation  *Revocation     `json:"revocation,omitempty" db:"-"`
+	Expired     bool            `json:"expired" db:"-"`
+	NotYetValid bool            `json:"not_yet_valid" db:"-"`
+
+	// The in-toto subjects of a DSSE message, set by the server
+	Subjects []Subject `json:"subjects,omitempty" db:"-"`
+
+	// A base64 DER RFC 3161 timestamp token over TimestampDigest, set by the
+	// server when it is configured with a timestamp authority
+	TimestampToken *string `json:"timestamp_token,omitempty" db:"timestamp_token"`
+}
+
+// The attributes covered by each signature in addition to the blob, one
+// "name: value" line each
+func (message *Message) signedAttributes() []string {
+	attrs := []string{}
+	if message.ContentType != nil {
+		attrs = append(attrs, "content_type: "+*message.ContentType)
+	}
+	for _, k := range message.Metadata.SortedKeys() {
+		attrs = append(attrs, "metadata."+k+": "+message.Metadata[k])
+	}
+	if message.NotBefore != nil {
+		attrs = append(attrs, "not_before: "+message.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if message.ExpiresAt != nil {
+		attrs = append(attrs, "expires_at: "+message.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return attrs
 }
 
-func (message *Message) ValidateGithubLogin() *binding.Error {
-	ghUser, err := github.UserFor(*message.GithubLogin)
-	message.GithubID = ghUser.ID
+// The bytes covered by each signature: a header naming the version, the
+// signed attributes and the raw blob. A legacy message without signed
+// attributes is signed over its raw blob alone.
+func (message *Message) SignedData() []byte {
+	attrs := message.signedAttributes()
+	if message.Version < SignedDataV2 && len(attrs) == 0 {
+		return message.RawBlob
+	}
+
+	buf := bytes.Buffer{}
+	if message.Version < SignedDataV2 {
+		buf.WriteString("signist message\n")
+	} else {
+		buf.WriteString(fmt.Sprintf("signist message v%d\n", message.Version))
+	}
+	for _, attr := range attrs {
+		buf.WriteString(attr + "\n")
+	}
+	buf.WriteString("\n")
+	buf.Write(message.RawBlob)
+	return buf.Bytes()
+}
+
+// Set Expired and NotYetValid as of now
+func (message *Message) CheckValidity(now time.Time) {
+	message.NotYetValid = message.NotBefore != nil && now.Before(*message.NotBefore)
+	message.Expired = message.ExpiresAt != nil && !now.Before(*message.ExpiresAt)
+}
+
+func (message *Message) ValidateGithubLogin() *Error {
+	var err *Error
+	message.GithubID, message.GithubKeys, err = lookupGithubLogin(*message.GithubLogin)
+	return err
+}
+
+// The github ID and keys of login, or an identity_not_found error when github
+// has no such user or organization
+func lookupGithubLogin(login string) (*int, []ssh.PublicKey, *Error) {
+	ghUser, err := github.UserFor(login)
 	if err != nil {
-		return &binding.Error{
-			FieldNames:     []string{"github_login"},
-			Classification: "DoesNotExistError",
-			Message:        "The specified github user could not be found",
+		return nil, nil, &Error{
+			Fields:  []string{"github_login"},
+			Code:    CodeIdentityNotFound,
+			Message: "The specified github user could not be found",
 		}
 	}
-	message.GithubKeys = github.GithubKeysFor(ghUser)
-	return nil
+	return ghUser.ID, github.GithubKeysFor(ghUser), nil
 }
 
-func (message *Message) ValidateTitle() *binding.Error {
-	if !slugRegex.MatchString(*message.Title) {
-		return &binding.Error{
-			FieldNames:     []string{"title"},
-			Classification: "InvalidInputError",
-			Message:        "Title may only conaint letters, digits, hyphens, and underscores",
+func (message *Message) ValidateTitle() *Error {
+	if !ValidTitle(*message.Title) {
+		return &Error{
+			Fields:  []string{"title"},
+			Code:    CodeInvalidInput,
+			Message: "Title must be up to 16 segments separated by slashes, each containing only letters, digits, hyphens, underscores, and dots between other characters",
 		}
 	} else {
 		return nil
 	}
 }
 
-func (message *Message) ValidateBlob() *binding.Error {
+func (message *Message) ValidateBlob() *Error {
 	blob, err := base64.StdEncoding.DecodeString(*message.Blob)
 	if err != nil {
-		return &binding.Error{
-			FieldNames:     []string{"message.blob"},
-			Classification: "InvalidInputError",
-			Message:        "Blob could not be decoded as base64",
+		return &Error{
+			Fields:  []string{"message.blob"},
+			Code:    CodeInvalidInput,
+			Message: "Blob could not be decoded as base64",
 		}
 	} else {
 		message.RawBlob = blob
@@ -64,38 +174,154 @@ func (message *Message) ValidateBlob() *binding.Error {
 	}
 }
 
-func (message *Message) ValidateSignaturesLength() *binding.Error {
+func (message *Message) ValidateContentType() *Error {
+	if message.ContentType == nil {
+		return nil
+	}
+
+	if _, _, err := mime.ParseMediaType(*message.ContentType); err != nil || len(*message.ContentType) > 128 || strings.ContainsAny(*message.ContentType, "\r\n") {
+		return &Error{
+			Fields:  []string{"content_type"},
+			Code:    CodeInvalidInput,
+			Message: "Content type must be a valid media type of at most 128 characters",
+		}
+	}
+
+	return nil
+}
+
+func (message *Message) ValidateMetadata() *Error {
+	if err := message.Metadata.Check(); err != nil {
+		return &Error{
+			Fields:  []string{"metadata"},
+			Code:    CodeInvalidInput,
+			Message: err.Error(),
+		}
+	}
+
+	return nil
+}
+
+func (message *Message) ValidateVersion() *Error {
+	if message.Version != SignedDataV2 {
+		return &Error{
+			Fields:  []string{"version"},
+			Code:    CodeInvalidInput,
+			Message: fmt.Sprintf("version must be %d", SignedDataV2),
+		}
+	}
+	return nil
+}
+
+func (message *Message) ValidateValidityWindow() *Error {
+	// Signed attributes only carry whole seconds
+	for _, t := range []*time.Time{message.NotBefore, message.ExpiresAt} {
+		if t != nil && t.Nanosecond() != 0 {
+			return &Error{
+				Fields:  []string{"not_before", "expires_at"},
+				Code:    CodeInvalidInput,
+				Message: "not_before and expires_at must be whole seconds",
+			}
+		}
+	}
+
+	if message.ExpiresAt == nil {
+		return nil
+	}
+
+	if message.NotBefore != nil && !message.ExpiresAt.After(*message.NotBefore) {
+		return &Error{
+			Fields:  []string{"not_before", "expires_at"},
+			Code:    CodeInvalidInput,
+			Message: "expires_at must be later than not_before",
+		}
+	}
+
+	return nil
+}
+
+// Check that a new message has not already expired and is valid for no
+// longer than max. A max of zero means there is no cap.
+func (message *Message) ValidateValidityPolicy(max time.Duration) *Error {
+	if message.ExpiresAt != nil && !message.ExpiresAt.After(time.Now()) {
+		return &Error{
+			Fields:  []string{"expires_at"},
+			Code:    CodeInvalidInput,
+			Message: "expires_at must be in the future",
+		}
+	}
+
+	if max == 0 {
+		return nil
+	}
+
+	if message.ExpiresAt == nil {
+		return &Error{
+			Fields:  []string{"expires_at"},
+			Code:    CodeValidityPolicy,
+			Message: "Messages for this identity must have an expires_at no more than " + max.String() + " after they become valid",
+		}
+	}
+
+	start := time.Now()
+	if message.NotBefore != nil && message.NotBefore.After(start) {
+		start = *message.NotBefore
+	}
+
+	if message.ExpiresAt.Sub(start) > max {
+		return &Error{
+			Fields:  []string{"not_before", "expires_at"},
+			Code:    CodeValidityPolicy,
+			Message: "Messages for this identity may be valid for at most " + max.String(),
+		}
+	}
+
+	return nil
+}
+
+func (message *Message) ValidateSignaturesLength() *Error {
 	if len(message.Signatures) == 0 {
-		return &binding.Error{
-			FieldNames:     []string{"signatures"},
-			Classification: "",
-			Message:        "There must be at least one signature for a message",
+		return &Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a message",
 		}
 	} else {
 		return nil
 	}
 }
 
-func (message *Message) ValidateSignatures() binding.Errors {
-	results := make(chan binding.Errors, len(message.Signatures))
+// Validate every signature concurrently, returning their errors in signature
+// order
+func (message *Message) ValidateSignatures() Errors {
+	results := make([]Errors, len(message.Signatures))
+	wg := sync.WaitGroup{}
 
-	for _, sig := range message.Signatures {
-		sig := sig
+	for i, sig := range message.Signatures {
+		i, sig := i, sig
 		sig.Message = message
+		wg.Add(1)
 		go func() {
-			results <- sig.Validate(binding.Errors{}, nil)
+			defer wg.Done()
+			results[i] = indexErrors(sig.Validate(), i)
 		}()
 	}
+	wg.Wait()
 
-	errors := binding.Errors{}
-	for i := 0; i < len(message.Signatures); i++ {
-		errors = append(errors, <-results...)
+	errors := Errors{}
+	for _, errs := range results {
+		errors = append(errors, errs...)
 	}
 	return errors
 }
 
-func (message *Message) Validate(errors binding.Errors, req *http.Request) binding.Errors {
-	var err *binding.Error
+func (message *Message) Validate() Errors {
+	var err *Error
+	errors := Errors{}
+
+	if required := requiredErrors(message); len(required) > 0 {
+		return append(errors, required...)
+	}
 
 	if err = message.ValidateGithubLogin(); err != nil {
 		return append(errors, *err)
@@ -105,14 +331,37 @@ func (message *Message) Validate(errors binding.Errors, req *http.Request) bindi
 		return append(errors, *err)
 	}
 
+	if err = message.ValidateVersion(); err != nil {
+		return append(errors, *err)
+	}
+
 	if err = message.ValidateBlob(); err != nil {
 		return append(errors, *err)
 	}
 
+	if err = message.ValidateContentType(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = message.ValidateMetadata(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = message.ValidateValidityWindow(); err != nil {
+		return append(errors, *err)
+	}
+
 	if err = message.ValidateSignaturesLength(); err != nil {
 		return append(errors, *err)
 	}
 
-	errors = append(errors, message.ValidateSignatures()...)
-	return errors
+	if message.TrustedCAs, err = loadCertificateAuthorities(*message.GithubID, message.Signatures); err != nil {
+		return append(errors, *err)
+	}
+
+	if errors = message.ValidateSignatures(); len(errors) > 0 {
+		return errors
+	}
+
+	return message.ValidateEnvelope()
 }
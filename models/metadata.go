@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"unicode"
+)
+
+const (
+	MaxMetadataEntries    = 32
+	MaxMetadataValueBytes = 256
+)
+
+var metadataKeyRegex = regexp.MustCompile(`\A[a-z\d][a-z\d\-\_\.]{0,63}\z`)
+
+// Signed key/value pairs describing a message, e.g. version or commit SHA.
+// Stored as JSONB.
+type Metadata map[string]string
+
+// Keys in a stable order, so that signed data is deterministic
+func (metadata Metadata) SortedKeys() []string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Check that metadata is small enough to store and can be represented one
+// entry per line in signed data
+func (metadata Metadata) Check() error {
+	if len(metadata) > MaxMetadataEntries {
+		return errors.New("Metadata may have at most 32 entries")
+	}
+
+	for k, v := range metadata {
+		if !metadataKeyRegex.MatchString(k) {
+			return errors.New("Metadata keys may only contain lowercase letters, digits, hyphens, underscores, and dots, and be at most 64 characters")
+		}
+
+		if len(v) > MaxMetadataValueBytes {
+			return errors.New("Metadata values may be at most 256 bytes")
+		}
+
+		for _, r := range v {
+			if unicode.IsControl(r) {
+				return errors.New("Metadata values may not contain control characters")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (metadata Metadata) Value() (driver.Value, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	return json.Marshal(metadata)
+}
+
+func (metadata *Metadata) Scan(src interface{}) error {
+	switch src.(type) {
+	case nil:
+		*metadata = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src.(string)), metadata)
+	case []byte:
+		return json.Unmarshal(src.([]byte), metadata)
+	default:
+		return errors.New("Can not convert that type to Metadata")
+	}
+}
@@ -1,9 +1,14 @@
 package models
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql/driver"
 	"encoding/base64"
 	"errors"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/sshkeys"
+	"strings"
 )
 
 type PublicKey struct {
@@ -24,7 +29,7 @@ func (key *PublicKey) UnmarshalJSON(b []byte) error {
 		return errors.New(`Expected to end with "`)
 	}
 
-	pubKey, _, _, _, err := ssh.ParseAuthorizedKey(b[2 : len(b)-1])
+	pubKey, _, _, _, err := sshkeys.ParseAuthorizedKey(b[1 : len(b)-1])
 	if err != nil {
 		return err
 	}
@@ -33,6 +38,10 @@ func (key *PublicKey) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+func (key PublicKey) Value() (driver.Value, error) {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key.PublicKey))), nil
+}
+
 func (key *PublicKey) Scan(src interface{}) error {
 	if src == nil {
 		return errors.New("PublicKey can not be nil")
@@ -43,9 +52,9 @@ func (key *PublicKey) Scan(src interface{}) error {
 
 	switch src.(type) {
 	case string:
-		out, _, _, _, err = ssh.ParseAuthorizedKey([]byte(src.(string)))
+		out, _, _, _, err = sshkeys.ParseAuthorizedKey([]byte(src.(string)))
 	case []byte:
-		out, _, _, _, err = ssh.ParseAuthorizedKey(src.([]byte))
+		out, _, _, _, err = sshkeys.ParseAuthorizedKey(src.([]byte))
 	default:
 		return errors.New("Can not convert that type to PublicKey")
 	}
@@ -53,3 +62,19 @@ func (key *PublicKey) Scan(src interface{}) error {
 	*key = PublicKey{out}
 	return err
 }
+
+// Check if keys contains this key
+func (key PublicKey) In(keys []ssh.PublicKey) bool {
+	for _, k := range keys {
+		if k.Type() == key.Type() && bytes.Equal(k.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// The SHA256 fingerprint of a key, as printed by ssh-keygen -l
+func Fingerprint(key ssh.PublicKey) string {
+	sum := sha256.Sum256(key.Marshal())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Return an error for each field tagged binding:"required" that is unset on
+// the struct obj points to
+func requiredErrors(obj interface{}) Errors {
+	errors := Errors{}
+	val := reflect.ValueOf(obj).Elem()
+	typ := val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !strings.Contains(field.Tag.Get("binding"), "required") {
+			continue
+		}
+
+		if val.Field(i).IsZero() {
+			name := field.Name
+			if j := field.Tag.Get("json"); j != "" {
+				name = strings.Split(j, ",")[0]
+			}
+			errors = append(errors, Error{Code: CodeRequired, Message: "Required", Fields: []string{name}})
+		}
+	}
+
+	return errors
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"time"
+)
+
+// Reasons a message may be revoked for
+var RevocationReasons = []string{
+	"unspecified",
+	"key_compromise",
+	"superseded",
+	"withdrawn",
+}
+
+// A Revocation marks a message as no longer trusted. It must be signed by the
+// identity that signed the message, and never removes the message itself.
+type Revocation struct {
+	ID          *int            `json:"id,omitempty"`
+	MessageID   *int            `json:"message_id" db:"message_id" binding:"required"`
+	GithubLogin *string         `json:"github_login" binding:"required"`
+	GithubID    *int            `json:"-" db:"github_id"`
+	GithubKeys  []ssh.PublicKey `json:"-"`
+	TrustedCAs  []ssh.PublicKey `json:"-"`
+	Reason      *string         `json:"reason" binding:"required"`
+	Signatures  []*Signature    `json:"signatures" binding:"required"`
+	CreatedAt   *time.Time      `json:"created_at,omitempty" db:"created_at"`
+
+	// The RevocationDigest of the message being revoked, so that the
+	// revocation can not be applied to whatever message has the same ID on
+	// another server or mirror
+	MessageDigest *string `json:"message_sha256" db:"message_sha256" binding:"required"`
+}
+
+// The bytes that must be signed to revoke a message
+func RevocationData(messageID int, messageDigest string, reason string) []byte {
+	return []byte(fmt.Sprintf("signist revocation\nmessage_id: %d\nmessage_sha256: %s\nreason: %s\n", messageID, messageDigest, reason))
+}
+
+// The hex SHA-256 of a message's title and signed data, which revocations
+// name the message by. RawBlob must be set.
+func (message *Message) RevocationDigest() string {
+	digest := sha256.Sum256(append([]byte(*message.Title+"\n"), message.SignedData()...))
+	return hex.EncodeToString(digest[:])
+}
+
+func (rev *Revocation) ValidateGithubLogin() *Error {
+	var err *Error
+	rev.GithubID, rev.GithubKeys, err = lookupGithubLogin(*rev.GithubLogin)
+	return err
+}
+
+func (rev *Revocation) ValidateReason() *Error {
+	for _, reason := range RevocationReasons {
+		if *rev.Reason == reason {
+			return nil
+		}
+	}
+
+	return &Error{
+		Fields:  []string{"reason"},
+		Code:    CodeInvalidInput,
+		Message: "Reason must be one of unspecified, key_compromise, superseded, or withdrawn",
+	}
+}
+
+func (rev *Revocation) ValidateSignaturesLength() *Error {
+	if len(rev.Signatures) == 0 {
+		return &Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a revocation",
+		}
+	} else {
+		return nil
+	}
+}
+
+func (rev *Revocation) ValidateMessageDigest() *Error {
+	if _, err := hex.DecodeString(*rev.MessageDigest); err != nil || len(*rev.MessageDigest) != 2*sha256.Size {
+		return &Error{
+			Fields:  []string{"message_sha256"},
+			Code:    CodeInvalidInput,
+			Message: "message_sha256 must be a hex SHA-256 digest",
+		}
+	}
+	return nil
+}
+
+func (rev *Revocation) ValidateSignatures() Errors {
+	return verifySignaturesBy(rev.Signatures, *rev.GithubLogin, rev.GithubKeys, rev.TrustedCAs, RevocationData(*rev.MessageID, *rev.MessageDigest, *rev.Reason))
+}
+
+// Check that the revocation is signed by at least min distinct keys, the
+// identity's threshold. The signatures must already have been verified.
+func (rev *Revocation) ValidateThreshold(min int) *Error {
+	if distinctKeys(rev.Signatures) >= min {
+		return nil
+	}
+
+	return &Error{
+		Fields:  []string{"signatures"},
+		Code:    CodeThresholdNotMet,
+		Message: fmt.Sprintf("Revocations for this identity must be signed by at least %d distinct keys", min),
+	}
+}
+
+// The number of different keys sigs were made with
+func distinctKeys(sigs []*Signature) int {
+	seen := map[string]bool{}
+	for _, sig := range sigs {
+		if sig.Key != nil && sig.Key.PublicKey != nil {
+			seen[Fingerprint(sig.Key.PublicKey)] = true
+		}
+	}
+	return len(seen)
+}
+
+func (rev *Revocation) Validate() Errors {
+	var err *Error
+	errors := Errors{}
+
+	if required := requiredErrors(rev); len(required) > 0 {
+		return append(errors, required...)
+	}
+
+	if err = rev.ValidateGithubLogin(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = rev.ValidateReason(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = rev.ValidateMessageDigest(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = rev.ValidateSignaturesLength(); err != nil {
+		return append(errors, *err)
+	}
+
+	if rev.TrustedCAs, err = loadCertificateAuthorities(*rev.GithubID, rev.Signatures); err != nil {
+		return append(errors, *err)
+	}
+
+	errors = append(errors, rev.ValidateSignatures()...)
+	return errors
+}
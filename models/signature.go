@@ -2,9 +2,8 @@ package models
 
 import (
 	"encoding/base64"
-	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/martini-contrib/binding"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
-	"net/http"
+	"github.com/andrewhamon/signist/sshkeys"
 	"time"
 )
 
@@ -17,15 +16,31 @@ type Signature struct {
 	RawBlob   []byte     `json:"-"`
 	Key       *PublicKey `json:"key"`
 	CreatedAt *time.Time `json:"created_at,omitempty" db:"created_at"`
+
+	// An OpenSSH certificate for Key, when the signing key is certified by a
+	// certificate authority rather than registered on github
+	Certificate *PublicKey `json:"certificate,omitempty" db:"certificate"`
 }
 
-func (sig *Signature) ValidateBlob() *binding.Error {
+func (sig *Signature) ValidatePresence() *Error {
+	if sig.Format == nil || sig.Blob == nil {
+		return &Error{
+			Fields:  []string{"signature.format", "signature.blob"},
+			Code:    CodeRequired,
+			Message: "Signatures must have a format and blob",
+		}
+	} else {
+		return nil
+	}
+}
+
+func (sig *Signature) ValidateBlob() *Error {
 	rawBlob, err := base64.StdEncoding.DecodeString(*sig.Blob)
 	if err != nil {
-		return &binding.Error{
-			FieldNames:     []string{"signature.blob"},
-			Classification: "InvalidInputError",
-			Message:        "Blob could not be decoded as base64",
+		return &Error{
+			Fields:  []string{"signature.blob"},
+			Code:    CodeInvalidInput,
+			Message: "Blob could not be decoded as base64",
 		}
 	} else {
 		sig.RawBlob = rawBlob
@@ -33,12 +48,20 @@ func (sig *Signature) ValidateBlob() *binding.Error {
 	}
 }
 
-func (sig *Signature) ValidateSignature() *binding.Error {
-	ghKeys := sig.Message.GithubKeys
+// Verify the signature over data against each of keys, recording the key
+// that matched
+func (sig *Signature) Verify(keys []ssh.PublicKey, data []byte) *Error {
+	if sig.Key != nil && sig.Key.PublicKey != nil && !sig.Key.In(keys) {
+		return &Error{
+			Fields:  []string{"key"},
+			Code:    CodeKeyNotAuthorized,
+			Message: "Key is not one of the signing identity's keys",
+		}
+	}
 
 	matchFound := false
-	for _, k := range ghKeys {
-		err := k.Verify(sig.Message.RawBlob, &ssh.Signature{Format: *sig.Format, Blob: sig.RawBlob})
+	for _, k := range keys {
+		err := sshkeys.Verify(k, data, &ssh.Signature{Format: *sig.Format, Blob: sig.RawBlob})
 		if err == nil {
 			sig.Key = &PublicKey{k}
 			matchFound = true
@@ -46,18 +69,30 @@ func (sig *Signature) ValidateSignature() *binding.Error {
 	}
 
 	if !matchFound {
-		return &binding.Error{
-			FieldNames:     []string{"key", "blob", "message.blob"},
-			Classification: "SignatureInvalidError",
-			Message:        "Key could not verify signature against message.blob",
+		return &Error{
+			Fields:  []string{"key", "blob", "message.blob"},
+			Code:    CodeSignatureInvalid,
+			Message: "Key could not verify signature against message.blob",
 		}
 	} else {
 		return nil
 	}
 }
 
-func (sig *Signature) Validate(errors binding.Errors, req *http.Request) binding.Errors {
-	var err *binding.Error
+func (sig *Signature) ValidateSignature() *Error {
+	if sig.Certificate != nil {
+		return sig.VerifyCertificate(sig.Message.TrustedCAs, *sig.Message.GithubLogin, time.Now(), sig.Message.SignedData())
+	}
+	return sig.Verify(sig.Message.GithubKeys, sig.Message.SignedData())
+}
+
+func (sig *Signature) Validate() Errors {
+	var err *Error
+	errors := Errors{}
+
+	if err = sig.ValidatePresence(); err != nil {
+		return append(errors, *err)
+	}
 
 	if err = sig.ValidateBlob(); err != nil {
 		return append(errors, *err)
@@ -67,5 +102,44 @@ func (sig *Signature) Validate(errors binding.Errors, req *http.Request) binding
 		return append(errors, *err)
 	}
 
+	if err = Algorithms.Check(sig); err != nil {
+		return append(errors, *err)
+	}
+
+	return errors
+}
+
+// Verify signatures over data by login, each made with one of keys or with a
+// key certified by one of cas
+func verifySignaturesBy(sigs []*Signature, login string, keys []ssh.PublicKey, cas []ssh.PublicKey, data []byte) Errors {
+	errors := Errors{}
+
+	for i, sig := range sigs {
+		if err := sig.ValidatePresence(); err != nil {
+			errors = append(errors, indexErrors(Errors{*err}, i)...)
+			continue
+		}
+
+		if err := sig.ValidateBlob(); err != nil {
+			errors = append(errors, indexErrors(Errors{*err}, i)...)
+			continue
+		}
+
+		var err *Error
+		if sig.Certificate != nil {
+			err = sig.VerifyCertificate(cas, login, time.Now(), data)
+		} else {
+			err = sig.Verify(keys, data)
+		}
+
+		if err == nil {
+			err = Algorithms.Check(sig)
+		}
+
+		if err != nil {
+			errors = append(errors, indexErrors(Errors{*err}, i)...)
+		}
+	}
+
 	return errors
 }
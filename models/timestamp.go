@@ -0,0 +1,38 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// The bytes a message's timestamp token covers: the digest of its signed
+// data and each of its signatures, so that a verified token proves the
+// signatures existed when it was issued. Signatures are sorted, as they may
+// be returned in any order.
+//
+//	signist timestamp
+//	message: <hex sha256 of SignedData>
+//	signature: <format> <base64 blob>
+func (message *Message) TimestampData() []byte {
+	digest := sha256.Sum256(message.SignedData())
+	sigs := []string{}
+	for _, sig := range message.Signatures {
+		if sig.Format != nil && sig.Blob != nil {
+			sigs = append(sigs, "signature: "+*sig.Format+" "+*sig.Blob+"\n")
+		}
+	}
+	sort.Strings(sigs)
+
+	data := "signist timestamp\nmessage: " + hex.EncodeToString(digest[:]) + "\n"
+	for _, sig := range sigs {
+		data += sig
+	}
+	return []byte(data)
+}
+
+// The SHA-256 digest a message's timestamp token is over
+func (message *Message) TimestampDigest() []byte {
+	digest := sha256.Sum256(message.TimestampData())
+	return digest[:]
+}
@@ -0,0 +1,356 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Events delivered to webhooks
+const (
+	EventMessageCreated = "message.created"
+	EventMessageRevoked = "message.revoked"
+)
+
+// States of a webhook delivery. Pending deliveries are retried with
+// exponential backoff until they are delivered or run out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	DeliveryCancelled = "cancelled"
+)
+
+const MaxWebhookURLLength = 2048
+
+// A Webhook delivers events for an identity's messages whose titles start
+// with TitlePrefix to URL, as JSON signed with HMAC-SHA256. Registering one
+// must be signed by the identity, in a request that expires.
+type Webhook struct {
+	ID          *int            `json:"id,omitempty"`
+	GithubLogin *string         `json:"github_login" db:"github_login" binding:"required"`
+	GithubID    *int            `json:"-" db:"github_id"`
+	GithubKeys  []ssh.PublicKey `json:"-"`
+	TrustedCAs  []ssh.PublicKey `json:"-"`
+	URL         *string         `json:"url" db:"url" binding:"required"`
+	TitlePrefix string          `json:"title_prefix" db:"title_prefix"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty" db:"-" binding:"required"`
+	Signatures  []*Signature    `json:"signatures,omitempty" db:"-" binding:"required"`
+	CreatedAt   *time.Time      `json:"created_at,omitempty" db:"created_at"`
+
+	// The key deliveries are signed with, generated by the server and only
+	// returned when the webhook is registered
+	Secret *string `json:"secret,omitempty" db:"secret"`
+}
+
+// A request to remove a webhook, signed by the identity that registered it
+// in a request that expires
+type WebhookDeletion struct {
+	WebhookID   *int            `json:"webhook_id" binding:"required"`
+	GithubLogin *string         `json:"github_login" binding:"required"`
+	GithubID    *int            `json:"-"`
+	GithubKeys  []ssh.PublicKey `json:"-"`
+	TrustedCAs  []ssh.PublicKey `json:"-"`
+	ExpiresAt   *time.Time      `json:"expires_at" binding:"required"`
+	Signatures  []*Signature    `json:"signatures" binding:"required"`
+}
+
+// A request for an identity's webhooks, signed by the identity since receiver
+// URLs often embed credentials
+type WebhookListRequest struct {
+	GithubLogin *string         `json:"github_login" binding:"required"`
+	GithubID    *int            `json:"-"`
+	GithubKeys  []ssh.PublicKey `json:"-"`
+	TrustedCAs  []ssh.PublicKey `json:"-"`
+	ExpiresAt   *time.Time      `json:"expires_at" binding:"required"`
+	Signatures  []*Signature    `json:"signatures" binding:"required"`
+}
+
+// A request for a webhook's recent deliveries, signed by the identity that
+// registered it since they include what its receiver responded
+type WebhookDeliveriesRequest struct {
+	WebhookID   *int            `json:"webhook_id" binding:"required"`
+	GithubLogin *string         `json:"github_login" binding:"required"`
+	GithubID    *int            `json:"-"`
+	GithubKeys  []ssh.PublicKey `json:"-"`
+	TrustedCAs  []ssh.PublicKey `json:"-"`
+	ExpiresAt   *time.Time      `json:"expires_at" binding:"required"`
+	Signatures  []*Signature    `json:"signatures" binding:"required"`
+}
+
+// One event queued for a webhook, and how delivering it has gone so far
+type WebhookDelivery struct {
+	ID             *int            `json:"id"`
+	WebhookID      *int            `json:"webhook_id" db:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty" db:"response_status"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty" db:"last_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      *time.Time      `json:"created_at" db:"created_at"`
+}
+
+// The body of a delivery. Created messages are sent as stored, and revoked
+// messages as their revocation.
+type WebhookPayload struct {
+	Event      string      `json:"event"`
+	Message    *Message    `json:"message,omitempty"`
+	Revocation *Revocation `json:"revocation,omitempty"`
+}
+
+// The bytes that must be signed to register a webhook
+func WebhookData(login string, url string, titlePrefix string, expiresAt time.Time) []byte {
+	return []byte(fmt.Sprintf("signist webhook\ngithub_login: %s\nurl: %s\ntitle_prefix: %s\nexpires_at: %s\n", login, url, titlePrefix, expiresAt.UTC().Format(time.RFC3339)))
+}
+
+// The bytes that must be signed to remove a webhook
+func WebhookDeletionData(login string, webhookID int, expiresAt time.Time) []byte {
+	return []byte(fmt.Sprintf("signist webhook deletion\ngithub_login: %s\nwebhook_id: %d\nexpires_at: %s\n", login, webhookID, expiresAt.UTC().Format(time.RFC3339)))
+}
+
+// The bytes that must be signed to list an identity's webhooks
+func WebhookListData(login string, expiresAt time.Time) []byte {
+	return []byte(fmt.Sprintf("signist webhook list\ngithub_login: %s\nexpires_at: %s\n", login, expiresAt.UTC().Format(time.RFC3339)))
+}
+
+// The bytes that must be signed to list a webhook's deliveries
+func WebhookDeliveriesData(webhookID int, expiresAt time.Time) []byte {
+	return []byte(fmt.Sprintf("signist webhook deliveries\nwebhook_id: %d\nexpires_at: %s\n", webhookID, expiresAt.UTC().Format(time.RFC3339)))
+}
+
+// Networks webhooks may not be delivered to, besides the loopback,
+// link-local, private, unspecified and multicast addresses net.IP reports:
+// "this network", shared address space, which some clouds serve metadata
+// from, IETF protocol assignments and benchmarking
+var reservedNetworks = mustParseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15")
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// Whether webhooks may be delivered to ip. Internal addresses, including
+// cloud metadata services at 169.254.169.254 and fd00:ec2::254, are refused
+// so that a webhook cannot make the server reach its own network.
+func PublicAddress(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range reservedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether the webhook wants events for a message with title
+func (hook *Webhook) Matches(title string) bool {
+	return strings.HasPrefix(title, hook.TitlePrefix)
+}
+
+func (hook *Webhook) ValidateGithubLogin() *Error {
+	var err *Error
+	hook.GithubID, hook.GithubKeys, err = lookupGithubLogin(*hook.GithubLogin)
+	return err
+}
+
+func (hook *Webhook) ValidateURL() *Error {
+	u, err := url.Parse(*hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 || len(*hook.URL) > MaxWebhookURLLength || strings.ContainsAny(*hook.URL, " \r\n") {
+		return &Error{
+			Fields:  []string{"url"},
+			Code:    CodeInvalidInput,
+			Message: "URL must be an absolute http or https URL of at most 2048 characters",
+		}
+	}
+
+	// Deliveries check the addresses they connect to as well, since the
+	// host may resolve differently later
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return &Error{
+			Fields:  []string{"url"},
+			Code:    CodeInvalidInput,
+			Message: "URL's host could not be resolved",
+		}
+	}
+	for _, ip := range ips {
+		if !PublicAddress(ip) {
+			return &Error{
+				Fields:  []string{"url"},
+				Code:    CodeInvalidInput,
+				Message: "URL must not resolve to a loopback, link-local, private or otherwise internal address",
+			}
+		}
+	}
+	return nil
+}
+
+func (hook *Webhook) ValidateTitlePrefix() *Error {
+	if len(hook.TitlePrefix) > 0 && !ValidTitlePrefix(hook.TitlePrefix) {
+		return &Error{
+			Fields:  []string{"title_prefix"},
+			Code:    CodeInvalidInput,
+			Message: "Title prefix must be a valid title, optionally followed by a slash",
+		}
+	}
+	return nil
+}
+
+func (hook *Webhook) ValidateSignaturesLength() *Error {
+	if len(hook.Signatures) == 0 {
+		return &Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a webhook",
+		}
+	}
+	return nil
+}
+
+func (hook *Webhook) Validate() Errors {
+	var err *Error
+	errors := Errors{}
+
+	if required := requiredErrors(hook); len(required) > 0 {
+		return append(errors, required...)
+	}
+
+	if err = validateRequestExpiry(*hook.ExpiresAt); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = hook.ValidateGithubLogin(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = hook.ValidateURL(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = hook.ValidateTitlePrefix(); err != nil {
+		return append(errors, *err)
+	}
+
+	if err = hook.ValidateSignaturesLength(); err != nil {
+		return append(errors, *err)
+	}
+
+	if hook.TrustedCAs, err = loadCertificateAuthorities(*hook.GithubID, hook.Signatures); err != nil {
+		return append(errors, *err)
+	}
+
+	return verifySignaturesBy(hook.Signatures, *hook.GithubLogin, hook.GithubKeys, hook.TrustedCAs, WebhookData(*hook.GithubLogin, *hook.URL, hook.TitlePrefix, *hook.ExpiresAt))
+}
+
+func (del *WebhookDeletion) Validate() Errors {
+	errors := Errors{}
+
+	if required := requiredErrors(del); len(required) > 0 {
+		return append(errors, required...)
+	}
+
+	err := validateRequestExpiry(*del.ExpiresAt)
+	if err != nil {
+		return append(errors, *err)
+	}
+
+	if del.GithubID, del.GithubKeys, err = lookupGithubLogin(*del.GithubLogin); err != nil {
+		return append(errors, *err)
+	}
+
+	if len(del.Signatures) == 0 {
+		return append(errors, Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a webhook deletion",
+		})
+	}
+
+	if del.TrustedCAs, err = loadCertificateAuthorities(*del.GithubID, del.Signatures); err != nil {
+		return append(errors, *err)
+	}
+
+	return verifySignaturesBy(del.Signatures, *del.GithubLogin, del.GithubKeys, del.TrustedCAs, WebhookDeletionData(*del.GithubLogin, *del.WebhookID, *del.ExpiresAt))
+}
+
+func (lr *WebhookListRequest) Validate() Errors {
+	errors := Errors{}
+
+	if required := requiredErrors(lr); len(required) > 0 {
+		return append(errors, required...)
+	}
+
+	err := validateRequestExpiry(*lr.ExpiresAt)
+	if err != nil {
+		return append(errors, *err)
+	}
+
+	if lr.GithubID, lr.GithubKeys, err = lookupGithubLogin(*lr.GithubLogin); err != nil {
+		return append(errors, *err)
+	}
+
+	if len(lr.Signatures) == 0 {
+		return append(errors, Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a webhook list request",
+		})
+	}
+
+	if lr.TrustedCAs, err = loadCertificateAuthorities(*lr.GithubID, lr.Signatures); err != nil {
+		return append(errors, *err)
+	}
+
+	return verifySignaturesBy(lr.Signatures, *lr.GithubLogin, lr.GithubKeys, lr.TrustedCAs, WebhookListData(*lr.GithubLogin, *lr.ExpiresAt))
+}
+
+func (dr *WebhookDeliveriesRequest) Validate() Errors {
+	errors := Errors{}
+
+	if required := requiredErrors(dr); len(required) > 0 {
+		return append(errors, required...)
+	}
+
+	if err := validateRequestExpiry(*dr.ExpiresAt); err != nil {
+		return append(errors, *err)
+	}
+
+	var err *Error
+	if dr.GithubID, dr.GithubKeys, err = lookupGithubLogin(*dr.GithubLogin); err != nil {
+		return append(errors, *err)
+	}
+
+	if len(dr.Signatures) == 0 {
+		return append(errors, Error{
+			Fields:  []string{"signatures"},
+			Code:    CodeInvalidInput,
+			Message: "There must be at least one signature for a webhook deliveries request",
+		})
+	}
+
+	if dr.TrustedCAs, err = loadCertificateAuthorities(*dr.GithubID, dr.Signatures); err != nil {
+		return append(errors, *err)
+	}
+
+	return verifySignaturesBy(dr.Signatures, *dr.GithubLogin, dr.GithubKeys, dr.TrustedCAs, WebhookDeliveriesData(*dr.WebhookID, *dr.ExpiresAt))
+}
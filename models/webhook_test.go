@@ -0,0 +1,65 @@
+package models
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"::", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, test := range tests {
+		if public := PublicAddress(net.ParseIP(test.ip)); public != test.public {
+			t.Errorf("PublicAddress(%s) is %v, want %v", test.ip, public, test.public)
+		}
+	}
+}
+
+func TestWebhookRequestsExpire(t *testing.T) {
+	login := "octocat"
+	url := "https://example.com/hook"
+	id := 1
+
+	for _, expiresAt := range []time.Time{time.Now().Add(-time.Minute), time.Now().Add(MaxRequestValidity + time.Minute)} {
+		hook := Webhook{GithubLogin: &login, URL: &url, ExpiresAt: &expiresAt, Signatures: []*Signature{{}}}
+		if errs := hook.Validate(); len(errs) != 1 || errs[0].Code != CodeRequestExpired {
+			t.Errorf("webhook expiring at %s: errors are %v, want %s", expiresAt, errs, CodeRequestExpired)
+		}
+
+		lr := WebhookListRequest{GithubLogin: &login, ExpiresAt: &expiresAt, Signatures: []*Signature{{}}}
+		if errs := lr.Validate(); len(errs) != 1 || errs[0].Code != CodeRequestExpired {
+			t.Errorf("list request expiring at %s: errors are %v, want %s", expiresAt, errs, CodeRequestExpired)
+		}
+
+		del := WebhookDeletion{WebhookID: &id, GithubLogin: &login, ExpiresAt: &expiresAt, Signatures: []*Signature{{}}}
+		if errs := del.Validate(); len(errs) != 1 || errs[0].Code != CodeRequestExpired {
+			t.Errorf("deletion expiring at %s: errors are %v, want %s", expiresAt, errs, CodeRequestExpired)
+		}
+
+		dr := WebhookDeliveriesRequest{WebhookID: &id, GithubLogin: &login, ExpiresAt: &expiresAt, Signatures: []*Signature{{}}}
+		if errs := dr.Validate(); len(errs) != 1 || errs[0].Code != CodeRequestExpired {
+			t.Errorf("deliveries request expiring at %s: errors are %v, want %s", expiresAt, errs, CodeRequestExpired)
+		}
+	}
+}
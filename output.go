@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/client"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/utils"
+	"github.com/andrewhamon/signist/verify"
+	"log"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Exit codes, also listed in the help text
+const (
+	exitError            = 1
+	exitVerifyFailed     = 2
+	exitNoMatchingKeys   = 3
+	exitIdentityNotFound = 4
+	exitServerRejected   = 5
+	exitNetworkError     = 6
+)
+
+const exitCodesHelp = `Exit codes:
+  0  success
+  1  invalid usage or any other error
+  2  the message failed verification
+  3  no key is in both the SSH agent and github
+  4  the github user or organization does not exist
+  5  the signist server rejected the request
+  6  the signist server or github could not be reached`
+
+// The result of sign, verify or revoke, printed with --output json. Fields
+// are only ever added to this document.
+type report struct {
+	Command     string                         `json:"command"`
+	OK          bool                           `json:"ok"`
+	MessageID   *int                           `json:"message_id,omitempty"`
+	URL         string                         `json:"url,omitempty"`
+	Login       string                         `json:"login,omitempty"`
+	Title       string                         `json:"title,omitempty"`
+	DryRun      bool                           `json:"dry_run,omitempty"`
+	Signers     []signerReport                 `json:"signers,omitempty"`
+	Keys        []keyReport                    `json:"keys,omitempty"`
+	Webhooks    []*models.Webhook              `json:"webhooks,omitempty"`
+	Deliveries  []*models.WebhookDelivery      `json:"deliveries,omitempty"`
+	Pins        []pinReport                    `json:"pins,omitempty"`
+	Authorities []*models.CertificateAuthority `json:"certificate_authorities,omitempty"`
+	Error       *errorReport                   `json:"error,omitempty"`
+
+	// When a verified timestamp token says the signatures existed
+	TimestampedAt *time.Time `json:"timestamped_at,omitempty"`
+}
+
+type signerReport struct {
+	Index       int    `json:"index"`
+	Type        string `json:"type,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type errorReport struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	ExitCode  int            `json:"exit_code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Details   []models.Error `json:"details,omitempty"`
+}
+
+// --output, or the profile's output format
+func outputFormat() string {
+	if len(*outputFlag) > 0 {
+		return *outputFlag
+	}
+	if len(settings.Output) > 0 {
+		return settings.Output
+	}
+	return "text"
+}
+
+// Record the message in the report
+func (r *report) setMessage(message *models.Message) {
+	r.MessageID = message.ID
+	if message.ID != nil {
+		r.URL = apiClient().MessageURL(*message.ID)
+	}
+	if message.GithubLogin != nil {
+		r.Login = *message.GithubLogin
+	}
+	if message.Title != nil {
+		r.Title = *message.Title
+	}
+}
+
+// Record the keys that made signatures
+func (r *report) addSignatures(sigs []*models.Signature) {
+	for i, sig := range sigs {
+		if sig.Key != nil {
+			r.Signers = append(r.Signers, signerReport{Index: i, Type: sig.Key.Type(), Fingerprint: models.Fingerprint(sig.Key.PublicKey)})
+		}
+	}
+}
+
+// Record the outcome of verifying each signature
+func (r *report) addResults(result verify.Result) {
+	for _, sig := range result.Signatures {
+		if sig.Err != nil {
+			r.Signers = append(r.Signers, signerReport{Index: sig.Index, Error: sig.Err.Message})
+		} else {
+			r.Signers = append(r.Signers, signerReport{Index: sig.Index, Type: sig.Key.Type(), Fingerprint: sig.Fingerprint})
+		}
+	}
+	if !result.TimestampedAt.IsZero() {
+		at := result.TimestampedAt.UTC()
+		r.TimestampedAt = &at
+	}
+}
+
+// Print a successful report, or text in text mode
+func succeed(r *report, format string, args ...interface{}) {
+	r.OK = true
+
+	switch outputFormat() {
+	case "json":
+		printReport(r)
+	case "text":
+		fmt.Printf(format, args...)
+	}
+}
+
+// Print a failed report, or the message to standard error in text mode, then
+// exit with exitCode
+func fail(r *report, exitCode int, code string, format string, args ...interface{}) {
+	if r.Error == nil {
+		r.Error = &errorReport{}
+	}
+	r.Error.Code = code
+	r.Error.Message = fmt.Sprintf(format, args...)
+	r.Error.ExitCode = exitCode
+
+	switch outputFormat() {
+	case "json":
+		printReport(r)
+	case "text":
+		log.Println(r.Error.Message)
+		if len(r.Error.Details) > 1 {
+			printErrors(r.Error.Details)
+		}
+		if len(r.Error.RequestID) > 0 {
+			log.Printf("Request ID: %s\n", r.Error.RequestID)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// Fail with the exit code that fits err
+func failWith(r *report, err error) {
+	var resErr *client.ResponseError
+	var urlErr *url.Error
+
+	switch {
+	case errors.As(err, &resErr):
+		r.Error = &errorReport{RequestID: resErr.RequestID, Details: resErr.Details}
+		exitCode := exitServerRejected
+		if resErr.Code == models.CodeIdentityNotFound {
+			exitCode = exitIdentityNotFound
+		}
+		fail(r, exitCode, resErr.Code, "Server rejected the request: %s (%s)", resErr.Message, resErr.Code)
+	case err == utils.ErrIdentityNotFound || github.IsNotFound(err):
+		fail(r, exitIdentityNotFound, models.CodeIdentityNotFound, "No github user or organization named %q", r.Login)
+	case err == utils.ErrNoMatchingKeys:
+		fail(r, exitNoMatchingKeys, "no_matching_keys", "None of the keys in the SSH agent can sign for %q. Run `signist keys %s` to see why.", r.Login, r.Login)
+	case errors.As(err, &urlErr):
+		fail(r, exitNetworkError, "network_error", "Error talking to server: %s", err.Error())
+	default:
+		fail(r, exitError, "error", "%s", err.Error())
+	}
+}
+
+func printReport(r *report) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(string(data))
+}
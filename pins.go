@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/verify"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// A pinned key, listed by pins list
+type pinReport struct {
+	Login       string `json:"login"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Where pinned keys are kept, $SIGNIST_KNOWN_SIGNERS or
+// ~/.config/signist/known_signers by default
+func knownSignersPath(r *report) string {
+	if path := os.Getenv("SIGNIST_KNOWN_SIGNERS"); len(path) > 0 {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fail(r, exitError, "error", "Error finding home directory: %s", err.Error())
+	}
+	return filepath.Join(home, ".config", "signist", "known_signers")
+}
+
+func knownSigners(r *report) *verify.KnownSigners {
+	ks, err := verify.LoadKnownSigners(knownSignersPath(r))
+	if err != nil {
+		fail(r, exitError, "error", "Error reading known signers: %s", err.Error())
+	}
+	return ks
+}
+
+func warnPinsChanged(login string, added []ssh.PublicKey, removed []ssh.PublicKey) {
+	log.Printf("Warning: the keys for %q have changed since they were pinned\n", login)
+	for _, k := range added {
+		log.Printf("  + %s (not trusted until accepted)\n", models.Fingerprint(k))
+	}
+	for _, k := range removed {
+		log.Printf("  - %s\n", models.Fingerprint(k))
+	}
+}
+
+func listPins(name string) {
+	r := &report{Command: "pins list", Login: name}
+	ks := knownSigners(r)
+
+	for _, login := range ks.Logins() {
+		if len(name) > 0 && login != name {
+			continue
+		}
+
+		keys, _ := ks.Pinned(login)
+		for _, k := range keys {
+			r.Pins = append(r.Pins, pinReport{Login: login, Type: k.Type(), Fingerprint: models.Fingerprint(k)})
+		}
+	}
+
+	if outputFormat() == "text" {
+		for _, pin := range r.Pins {
+			fmt.Printf("%s %s %s\n", pin.Login, pin.Type, pin.Fingerprint)
+		}
+	}
+	succeed(r, "")
+}
+
+func acceptPins(name string) {
+	r := &report{Command: "pins accept", Login: name}
+
+	keys, err := verify.GithubKeys{}.Keys(context.Background(), name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	ks := knownSigners(r)
+	if err := ks.Pin(name, keys); err != nil {
+		fail(r, exitError, "no_keys", "Error pinning keys for %q: %s", name, err.Error())
+	}
+	if err := ks.Save(); err != nil {
+		fail(r, exitError, "error", "Error saving known signers: %s", err.Error())
+	}
+
+	for _, k := range keys {
+		r.Pins = append(r.Pins, pinReport{Login: name, Type: k.Type(), Fingerprint: models.Fingerprint(k)})
+	}
+	succeed(r, "Pinned %d keys for %s\n", len(keys), name)
+}
+
+func removePins(name string) {
+	r := &report{Command: "pins remove", Login: name}
+
+	ks := knownSigners(r)
+	if !ks.Remove(name) {
+		fail(r, exitError, "not_pinned", "No keys are pinned for %q", name)
+	}
+
+	if err := ks.Save(); err != nil {
+		fail(r, exitError, "error", "Error saving known signers: %s", err.Error())
+	}
+
+	succeed(r, "Removed pinned keys for %s\n", name)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/andrewhamon/signist/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (s *server) postCertificateAuthority(w http.ResponseWriter, req *http.Request) {
+	ca := models.CertificateAuthority{}
+	if !bind(w, req, &ca) {
+		return
+	}
+
+	if pathParam(req, "github_id") != strconv.Itoa(*ca.GithubID) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Certificate authority does not belong to the requested identity", nil)
+		return
+	}
+
+	err := s.db.QueryRowx(`INSERT INTO certificate_authorities (github_id, github_login, key, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (github_id, key) DO NOTHING RETURNING id, created_at`, ca.GithubID, ca.GithubLogin, ca.Key, time.Now()).StructScan(&ca)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusConflict, models.CodeAuthorityExists, "This certificate authority is already trusted", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	ca.ExpiresAt = nil
+	ca.Signatures = nil
+	writeJSON(w, http.StatusOK, ca)
+}
+
+func (s *server) getCertificateAuthorities(w http.ResponseWriter, req *http.Request) {
+	githubID, err := strconv.Atoi(pathParam(req, "github_id"))
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "github_id must be a number", nil)
+		return
+	}
+
+	cas := []*models.CertificateAuthority{}
+	err = s.db.Select(&cas, "SELECT id, github_id, github_login, key, created_at FROM certificate_authorities WHERE github_id = $1 ORDER BY id", githubID)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cas)
+}
+
+func (s *server) deleteCertificateAuthority(w http.ResponseWriter, req *http.Request) {
+	del := models.CertificateAuthorityDeletion{}
+	if !bind(w, req, &del) {
+		return
+	}
+
+	if pathParam(req, "authority_id") != strconv.Itoa(*del.AuthorityID) || pathParam(req, "github_id") != strconv.Itoa(*del.GithubID) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Deletion does not match the requested certificate authority", nil)
+		return
+	}
+
+	ca := models.CertificateAuthority{}
+	err := s.db.QueryRowx(`DELETE FROM certificate_authorities WHERE id = $1 AND github_id = $2 RETURNING id, github_id, github_login, key, created_at`, del.AuthorityID, del.GithubID).StructScan(&ca)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusNotFound, models.CodeAuthorityNotFound, "Certificate authority not found", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ca)
+}
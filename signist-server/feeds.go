@@ -0,0 +1,420 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/models"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The most recent messages included in a feed
+const feedLength = 50
+
+const (
+	atomSuffix     = ".atom"
+	jsonFeedSuffix = ".json"
+)
+
+// A feed of an identity's messages, built once and rendered as Atom or JSON
+// Feed
+type feed struct {
+	title   string
+	selfURL string
+	homeURL string
+	login   string
+	updated time.Time
+	entries []feedEntry
+}
+
+type feedEntry struct {
+	message   *models.Message
+	url       string
+	blobURL   string
+	published time.Time
+	updated   time.Time
+	signers   []feedSigner
+}
+
+type feedSigner struct {
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// GET /users/:login.atom and /users/:login.json
+func (s *server) getUserFeed(w http.ResponseWriter, req *http.Request) {
+	login, format := feedFormat(pathParam(req, "feed"))
+	if len(format) == 0 {
+		writeError(w, req, http.StatusNotFound, models.CodeNotFound, "Not found", nil)
+		return
+	}
+
+	s.writeFeed(w, req, login, "", format)
+}
+
+// GET /users/:login/messages/*title, where the title ends in .atom or .json.
+// The feed has the messages with the title, and those titled under it.
+func (s *server) getTitleFeed(w http.ResponseWriter, req *http.Request) {
+	title, format := feedFormat(pathParam(req, "title"))
+	if len(format) == 0 {
+		writeError(w, req, http.StatusNotFound, models.CodeNotFound, "Not found", nil)
+		return
+	}
+	if !models.ValidTitle(title) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Title is not valid", nil)
+		return
+	}
+
+	s.writeFeed(w, req, pathParam(req, "login"), title, format)
+}
+
+// Split a feed name into what it is for and its format, or no format
+func feedFormat(name string) (string, string) {
+	for _, suffix := range []string{atomSuffix, jsonFeedSuffix} {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return strings.TrimSuffix(name, suffix), suffix
+		}
+	}
+	return name, ""
+}
+
+func (s *server) writeFeed(w http.ResponseWriter, req *http.Request, login string, title string, format string) {
+	user, err := github.UserFor(login)
+	if err != nil {
+		writeError(w, req, http.StatusNotFound, models.CodeIdentityNotFound, "The specified github user could not be found", nil)
+		return
+	}
+
+	messages := []*models.Message{}
+	if len(title) == 0 {
+		err = s.db.Select(&messages, "SELECT * FROM messages WHERE github_id = $1 ORDER BY id DESC LIMIT $2", user.ID, feedLength)
+	} else {
+		err = s.db.Select(&messages, "SELECT * FROM messages WHERE github_id = $1 AND (title = $2 OR left(title, char_length($2) + 1) = $2 || '/') ORDER BY id DESC LIMIT $3", user.ID, title, feedLength)
+	}
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	now := time.Now()
+	for _, m := range messages {
+		if err := loadMessageDetails(s.db, m, now); err != nil {
+			writeInternalError(w, req, err)
+			return
+		}
+	}
+
+	f := buildFeed(publicURL(req), login, title, format, messages, now)
+
+	// The feed changes when a message is signed, revoked, expires or becomes
+	// valid. An empty feed has nothing to date it by.
+	if !f.updated.IsZero() {
+		lastModified := f.updated.UTC().Truncate(time.Second)
+		if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	} else {
+		f.updated = now
+	}
+
+	if format == atomSuffix {
+		writeAtom(w, f)
+	} else {
+		writeJSONFeed(w, f)
+	}
+}
+
+func buildFeed(base string, login string, title string, format string, messages []*models.Message, now time.Time) feed {
+	f := feed{login: login}
+
+	home := url.Values{}
+	if len(title) == 0 {
+		f.title = "Messages signed by " + login
+		f.selfURL = base + "/users/" + url.PathEscape(login) + format
+	} else {
+		f.title = title + " signed by " + login
+		f.selfURL = base + "/users/" + url.PathEscape(login) + "/messages/" + title + format
+		home.Set("prefix", title)
+	}
+	f.homeURL = base + "/users/" + url.PathEscape(login) + "/messages"
+	if len(home) > 0 {
+		f.homeURL += "?" + home.Encode()
+	}
+
+	for _, m := range messages {
+		e := feedEntry{
+			message:   m,
+			url:       base + "/messages/" + strconv.Itoa(*m.ID),
+			blobURL:   base + "/messages/" + strconv.Itoa(*m.ID) + "/blob",
+			published: *m.CreatedAt,
+			updated:   *m.CreatedAt,
+		}
+		if m.Revocation != nil && m.Revocation.CreatedAt != nil {
+			e.updated = *m.Revocation.CreatedAt
+		}
+		// Its status changes when it expires or becomes valid, once that
+		// has passed
+		for _, t := range []*time.Time{m.NotBefore, m.ExpiresAt} {
+			if t != nil && !now.Before(*t) && t.After(e.updated) {
+				e.updated = *t
+			}
+		}
+		for _, sig := range m.Signatures {
+			if sig.Key != nil && sig.Key.PublicKey != nil {
+				e.signers = append(e.signers, feedSigner{Type: sig.Key.Type(), Fingerprint: models.Fingerprint(sig.Key.PublicKey)})
+			}
+		}
+
+		if e.updated.After(f.updated) {
+			f.updated = e.updated
+		}
+		f.entries = append(f.entries, e)
+	}
+
+	return f
+}
+
+// SIGNIST_PUBLIC_URL, or the URL the request was made to
+func publicURL(req *http.Request) string {
+	if base := os.Getenv("SIGNIST_PUBLIC_URL"); len(base) > 0 {
+		return strings.TrimSuffix(base, "/")
+	}
+
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
+
+// A plain text description of an entry: its status and signers
+func (e feedEntry) summary() string {
+	lines := []string{fmt.Sprintf("%s signed by %s with %d keys", *e.message.Title, *e.message.GithubLogin, len(e.signers))}
+	for _, signer := range e.signers {
+		lines = append(lines, signer.Type+" "+signer.Fingerprint)
+	}
+	if e.message.Revocation != nil {
+		lines = append(lines, "Revoked: "+*e.message.Revocation.Reason)
+	}
+	if e.message.Expired {
+		lines = append(lines, "Expired at "+e.message.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e feedEntry) title() string {
+	if e.message.Revoked {
+		return *e.message.Title + " (revoked)"
+	}
+	return *e.message.Title
+}
+
+func (e feedEntry) contentType() string {
+	if e.message.ContentType != nil {
+		return *e.message.ContentType
+	}
+	return "application/octet-stream"
+}
+
+func (e feedEntry) blobSize() int {
+	return base64.StdEncoding.DecodedLen(len(*e.message.Blob)) - strings.Count(*e.message.Blob, "=")
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int    `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func writeAtom(w http.ResponseWriter, f feed) {
+	out := atomFeed{
+		ID:      f.selfURL,
+		Title:   f.title,
+		Updated: f.updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.selfURL},
+			{Rel: "alternate", Type: "application/json", Href: f.homeURL},
+		},
+		Author: atomPerson{Name: f.login, URI: "https://github.com/" + url.PathEscape(f.login)},
+	}
+
+	for _, e := range f.entries {
+		out.Entries = append(out.Entries, atomEntry{
+			ID:        e.url,
+			Title:     e.title(),
+			Published: e.published.UTC().Format(time.RFC3339),
+			Updated:   e.updated.UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Rel: "alternate", Type: "application/json", Href: e.url, Title: "Message and signatures"},
+				{Rel: "enclosure", Type: e.contentType(), Href: e.blobURL, Title: "Signed data", Length: e.blobSize()},
+			},
+			Content: atomContent{Type: "text", Body: e.summary()},
+		})
+	}
+
+	body, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Authors     []jsonAuthor   `json:"authors"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentText   string               `json:"content_text"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+	Signist       jsonFeedSignist      `json:"_signist"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Title    string `json:"title"`
+	Size     int    `json:"size_in_bytes"`
+}
+
+// The JSON Feed extension carrying what a client needs to verify an item
+type jsonFeedSignist struct {
+	MessageID int          `json:"message_id"`
+	Signers   []feedSigner `json:"signers"`
+	Revoked   bool         `json:"revoked"`
+	Expired   bool         `json:"expired"`
+}
+
+func writeJSONFeed(w http.ResponseWriter, f feed) {
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.title,
+		HomePageURL: f.homeURL,
+		FeedURL:     f.selfURL,
+		Authors:     []jsonAuthor{{Name: f.login, URL: "https://github.com/" + url.PathEscape(f.login)}},
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, e := range f.entries {
+		signers := e.signers
+		if signers == nil {
+			signers = []feedSigner{}
+		}
+		out.Items = append(out.Items, jsonFeedItem{
+			ID:            e.url,
+			URL:           e.url,
+			Title:         e.title(),
+			ContentText:   e.summary(),
+			DatePublished: e.published.UTC().Format(time.RFC3339),
+			DateModified:  e.updated.UTC().Format(time.RFC3339),
+			Attachments:   []jsonFeedAttachment{{URL: e.blobURL, MimeType: e.contentType(), Title: "Signed data", Size: e.blobSize()}},
+			Signist:       jsonFeedSignist{MessageID: *e.message.ID, Signers: signers, Revoked: e.message.Revoked, Expired: e.message.Expired},
+		})
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	w.Write(body)
+}
+
+// GET /messages/:id/blob, the signed data itself
+func (s *server) getBlob(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(pathParam(req, "id"))
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "id must be a number", nil)
+		return
+	}
+
+	message := &models.Message{}
+	err = s.db.Get(message, "SELECT * FROM messages WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusNotFound, models.CodeMessageNotFound, "Message not found", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(*message.Blob)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	// Blobs are whatever their signers uploaded, so they must not be able to
+	// run script in the API's origin
+	contentType := "application/octet-stream"
+	if message.ContentType != nil {
+		contentType = *message.ContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Last-Modified", message.CreatedAt.UTC().Format(http.TimeFormat))
+	w.Write(blob)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/andrewhamon/signist/models"
+	"testing"
+	"time"
+)
+
+func TestBuildFeedUpdated(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := now.Add(-24 * time.Hour)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name      string
+		notBefore *time.Time
+		expiresAt *time.Time
+		revokedAt *time.Time
+		updated   time.Time
+	}{
+		{name: "signed", updated: createdAt},
+		{name: "revoked", revokedAt: at(-time.Hour), updated: now.Add(-time.Hour)},
+		{name: "expired", expiresAt: at(-time.Minute), updated: now.Add(-time.Minute)},
+		{name: "expires later", expiresAt: at(time.Minute), updated: createdAt},
+		{name: "became valid", notBefore: at(-time.Minute), expiresAt: at(time.Hour), updated: now.Add(-time.Minute)},
+		{name: "not yet valid", notBefore: at(time.Minute), updated: createdAt},
+		{name: "revoked after expiring", expiresAt: at(-time.Hour), revokedAt: at(-time.Minute), updated: now.Add(-time.Minute)},
+		{name: "expired after revocation", revokedAt: at(-time.Hour), expiresAt: at(-time.Minute), updated: now.Add(-time.Minute)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, title, login, blob := 1, "release", "octocat", ""
+			m := &models.Message{ID: &id, Title: &title, GithubLogin: &login, Blob: &blob, CreatedAt: &createdAt, NotBefore: test.notBefore, ExpiresAt: test.expiresAt}
+			if test.revokedAt != nil {
+				m.Revocation = &models.Revocation{CreatedAt: test.revokedAt}
+			}
+
+			f := buildFeed("https://signist.example.com", login, "", atomSuffix, []*models.Message{m}, now)
+			if !f.updated.Equal(test.updated) {
+				t.Errorf("feed updated at %s, want %s", f.updated, test.updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/jmoiron/sqlx"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/utils"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *server) getOpenAPI(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(openAPI)
+}
+
+func (s *server) getMessages(w http.ResponseWriter, req *http.Request) {
+	if _, err := strconv.Atoi(pathParam(req, "github_id")); err != nil {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "github_id must be a number", nil)
+		return
+	}
+
+	listMessages(s.db, pathParam(req, "github_id"), w, req)
+}
+
+func (s *server) getMessage(w http.ResponseWriter, req *http.Request) {
+	message := &models.Message{}
+	id, err := strconv.Atoi(pathParam(req, "id"))
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "id must be a number", nil)
+		return
+	}
+
+	err = s.db.Get(message, "SELECT * FROM messages WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusNotFound, models.CodeMessageNotFound, "Message not found", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if err := loadMessageDetails(s.db, message, time.Now()); err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, message)
+}
+
+// Serve a DSSE message's envelope exactly as it was uploaded
+func (s *server) getEnvelope(w http.ResponseWriter, req *http.Request) {
+	message := &models.Message{}
+	id, err := strconv.Atoi(pathParam(req, "id"))
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "id must be a number", nil)
+		return
+	}
+
+	err = s.db.Get(message, "SELECT * FROM messages WHERE id = $1", id)
+	if err == sql.ErrNoRows || (err == nil && (message.ContentType == nil || *message.ContentType != models.ContentTypeDSSE)) {
+		writeError(w, req, http.StatusNotFound, models.CodeMessageNotFound, "No DSSE message with this id", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	envelope, err := base64.StdEncoding.DecodeString(*message.Blob)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", models.ContentTypeDSSE)
+	w.Write(envelope)
+}
+
+// List the DSSE messages with an in-toto subject with this digest, from any
+// identity
+func (s *server) getSubjectMessages(w http.ResponseWriter, req *http.Request) {
+	algorithm, digest := pathParam(req, "algorithm"), strings.ToLower(pathParam(req, "digest"))
+	if !models.ValidDigest(algorithm, digest) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "algorithm must be a digest name such as sha256 and digest a hex string", nil)
+		return
+	}
+
+	messages := []*models.Message{}
+	err := s.db.Select(&messages, "SELECT * FROM messages WHERE id IN (SELECT message_id FROM message_subjects WHERE algorithm = $1 AND digest = $2) ORDER BY id", algorithm, digest)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	now := time.Now()
+	for _, m := range messages {
+		if err := loadMessageDetails(s.db, m, now); err != nil {
+			writeInternalError(w, req, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+func (s *server) getUserMessages(w http.ResponseWriter, req *http.Request) {
+	user, err := github.UserFor(pathParam(req, "login"))
+	if err != nil {
+		writeError(w, req, http.StatusNotFound, models.CodeIdentityNotFound, "The specified github user could not be found", nil)
+		return
+	}
+
+	listMessages(s.db, strconv.Itoa(*user.ID), w, req)
+}
+
+func (s *server) postMessage(w http.ResponseWriter, req *http.Request) {
+	message := models.Message{}
+	if !bind(w, req, &message) {
+		return
+	}
+
+	if s.storeMessage(w, req, &message) {
+		writeJSON(w, http.StatusOK, message)
+	}
+}
+
+// Store a validated message with its signatures and subjects, and append it
+// to the log. On failure an error response has already been written and
+// false is returned.
+func (s *server) storeMessage(w http.ResponseWriter, req *http.Request, message *models.Message) bool {
+	max, err := maxValidity(s.db, *message.GithubID, s.defaultMax)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return false
+	}
+
+	if err := message.ValidateValidityPolicy(max); err != nil {
+		writeValidationErrors(w, req, models.Errors{*err})
+		return false
+	}
+
+	if s.dailyQuota > 0 {
+		var count int
+		err := s.db.Get(&count, "SELECT COUNT(*) FROM messages WHERE github_id = $1 AND created_at > now() - interval '1 day'", message.GithubID)
+		if err != nil {
+			writeInternalError(w, req, err)
+			return false
+		}
+		if count >= s.dailyQuota {
+			writeError(w, req, http.StatusTooManyRequests, models.CodeQuotaExceeded, fmt.Sprintf("This identity may store at most %d messages a day", s.dailyQuota), nil)
+			return false
+		}
+	}
+
+	// Only tokens the server requested are stored
+	message.TimestampToken = nil
+	if s.tsa != nil {
+		if err := s.tsa.stamp(req.Context(), message); err != nil {
+			log.Printf("%s could not timestamp message: %s\n", req.Header.Get("X-Request-Id"), err.Error())
+			writeError(w, req, http.StatusServiceUnavailable, models.CodeTimestampUnavailable, "The timestamp authority could not be reached, try again later", nil)
+			return false
+		}
+	}
+
+	tx := s.db.MustBegin()
+	err = tx.QueryRowx(`INSERT INTO messages (version, github_id, github_login, title, blob, content_type, metadata, not_before, expires_at, timestamp_token, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created_at`, message.Version, message.GithubID, message.GithubLogin, message.Title, message.Blob, message.ContentType, message.Metadata, message.NotBefore, message.ExpiresAt, message.TimestampToken, time.Now()).StructScan(message)
+
+	if err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return false
+	}
+
+	for _, sig := range message.Signatures {
+		err := tx.QueryRowx(`INSERT INTO signatures (message_id, format, blob, key, certificate, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, message_id, created_at`, message.ID, sig.Format, sig.Blob, utils.PubKeyToString(*sig.Key), sig.Certificate, time.Now()).StructScan(sig)
+
+		if err != nil {
+			tx.Rollback()
+			writeInternalError(w, req, err)
+			return false
+		}
+
+	}
+
+	for _, subject := range message.Subjects {
+		_, err := tx.Exec(`INSERT INTO message_subjects (message_id, name, algorithm, digest) VALUES ($1, $2, $3, $4)`, message.ID, subject.Name, subject.Algorithm, subject.Digest)
+
+		if err != nil {
+			tx.Rollback()
+			writeInternalError(w, req, err)
+			return false
+		}
+	}
+
+	if err := logMessage(tx, message); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return false
+	}
+
+	if err := enqueueWebhooks(tx, *message.GithubID, *message.Title, models.WebhookPayload{Event: models.EventMessageCreated, Message: message}); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return false
+	}
+
+	return true
+}
+
+func (s *server) deleteMessage(w http.ResponseWriter, req *http.Request) {
+	rev := models.Revocation{}
+	if !bind(w, req, &rev) {
+		return
+	}
+
+	if pathParam(req, "message_id") != strconv.Itoa(*rev.MessageID) || pathParam(req, "github_id") != strconv.Itoa(*rev.GithubID) {
+		writeError(w, req, http.StatusBadRequest, models.CodeRevocationMismatch, "Revocation does not match the requested message", nil)
+		return
+	}
+
+	message := &models.Message{}
+	err := s.db.Get(message, "SELECT * FROM messages WHERE id = $1 AND github_id = $2", rev.MessageID, rev.GithubID)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusNotFound, models.CodeMessageNotFound, "Message not found", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if err := message.ValidateBlob(); err != nil {
+		writeInternalError(w, req, errors.New(err.Message))
+		return
+	}
+
+	if *rev.MessageDigest != message.RevocationDigest() {
+		writeError(w, req, http.StatusBadRequest, models.CodeRevocationMismatch, "Revocation was signed for a different message", nil)
+		return
+	}
+
+	threshold, err := revocationThreshold(s.db, *rev.GithubID, s.defaultThreshold)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if err := rev.ValidateThreshold(threshold); err != nil {
+		writeValidationErrors(w, req, models.Errors{*err})
+		return
+	}
+
+	title := *message.Title
+	tx := s.db.MustBegin()
+	err = tx.QueryRowx(`INSERT INTO revocations (message_id, message_sha256, reason, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (message_id) DO NOTHING RETURNING id, created_at`, rev.MessageID, rev.MessageDigest, rev.Reason, time.Now()).StructScan(&rev)
+
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		writeError(w, req, http.StatusConflict, models.CodeAlreadyRevoked, "Message has already been revoked", nil)
+		return
+	} else if err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return
+	}
+
+	for _, sig := range rev.Signatures {
+		err := tx.QueryRowx(`INSERT INTO revocation_signatures (revocation_id, format, blob, key, certificate, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`, rev.ID, sig.Format, sig.Blob, utils.PubKeyToString(*sig.Key), sig.Certificate, time.Now()).StructScan(sig)
+
+		if err != nil {
+			tx.Rollback()
+			writeInternalError(w, req, err)
+			return
+		}
+	}
+
+	if err := logRevocation(tx, &rev); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if err := enqueueWebhooks(tx, *rev.GithubID, title, models.WebhookPayload{Event: models.EventMessageRevoked, Revocation: &rev}); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rev)
+}
+
+// Render an identity's messages, with their signatures and status
+func listMessages(db *sqlx.DB, githubID string, w http.ResponseWriter, req *http.Request) {
+	messages := []*models.Message{}
+
+	query, args, err := messagesQuery(githubID, req.URL.Query())
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, err.Error(), nil)
+		return
+	}
+
+	err = db.Select(&messages, query, args...)
+
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	now := time.Now()
+	for _, m := range messages {
+		if err := loadMessageDetails(db, m, now); err != nil {
+			writeInternalError(w, req, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// Attach a message's signatures and revocation, and set its status as of now
+func loadMessageDetails(db *sqlx.DB, message *models.Message, now time.Time) error {
+	message.CheckValidity(now)
+	message.Signatures = []*models.Signature{}
+	err := db.Select(&message.Signatures, "SELECT * FROM signatures WHERE message_id = $1 ORDER BY id", message.ID)
+	if err != nil {
+		return err
+	}
+
+	if message.ContentType != nil && *message.ContentType == models.ContentTypeDSSE {
+		if err := db.Select(&message.Subjects, `SELECT name, algorithm, digest FROM message_subjects WHERE message_id = $1 ORDER BY name COLLATE "C", algorithm COLLATE "C", digest`, message.ID); err != nil {
+			return err
+		}
+	}
+
+	return loadRevocation(db, message)
+}
+
+// Build the query listing an identity's messages, filtered by title prefix,
+// content_type, and any metadata.<key> query parameters
+func messagesQuery(githubID string, filters url.Values) (string, []interface{}, error) {
+	query := "SELECT * FROM messages WHERE github_id = $1"
+	args := []interface{}{githubID}
+
+	if prefix := filters.Get("prefix"); len(prefix) > 0 {
+		if !models.ValidTitlePrefix(prefix) {
+			return "", nil, errors.New("Prefix must be a valid title, optionally followed by a slash")
+		}
+		args = append(args, prefix)
+		query += " AND left(title, char_length($" + strconv.Itoa(len(args)) + ")) = $" + strconv.Itoa(len(args))
+	}
+
+	if contentType := filters.Get("content_type"); len(contentType) > 0 {
+		args = append(args, contentType)
+		query += " AND content_type = $" + strconv.Itoa(len(args))
+	}
+
+	metadata := models.Metadata{}
+	for k, v := range filters {
+		if strings.HasPrefix(k, "metadata.") {
+			metadata[strings.TrimPrefix(k, "metadata.")] = v[0]
+		}
+	}
+
+	if len(metadata) > 0 {
+		if err := metadata.Check(); err != nil {
+			return "", nil, err
+		}
+		args = append(args, metadata)
+		query += " AND metadata @> $" + strconv.Itoa(len(args))
+	}
+
+	return query, args, nil
+}
+
+// The longest a message signed by githubID may be valid for, or zero if there
+// is no cap
+func maxValidity(db *sqlx.DB, githubID int, defaultMax time.Duration) (time.Duration, error) {
+	var seconds int
+	err := db.Get(&seconds, "SELECT max_validity_seconds FROM validity_policies WHERE github_id = $1", githubID)
+	if err == sql.ErrNoRows {
+		return defaultMax, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// The number of distinct keys a revocation by githubID must be signed by
+func revocationThreshold(db *sqlx.DB, githubID int, defaultThreshold int) (int, error) {
+	var threshold int
+	err := db.Get(&threshold, "SELECT min_signatures FROM revocation_policies WHERE github_id = $1", githubID)
+	if err == sql.ErrNoRows {
+		return defaultThreshold, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return threshold, nil
+}
+
+// The certificate authorities trusted to certify githubID's keys
+func certificateAuthorities(db *sqlx.DB, githubID int) ([]ssh.PublicKey, error) {
+	rows := []models.PublicKey{}
+	if err := db.Select(&rows, "SELECT key FROM certificate_authorities WHERE github_id = $1", githubID); err != nil {
+		return nil, err
+	}
+
+	cas := make([]ssh.PublicKey, len(rows))
+	for i, row := range rows {
+		cas[i] = row.PublicKey
+	}
+	return cas, nil
+}
+
+// Attach the revocation for a message, if there is one
+func loadRevocation(db *sqlx.DB, message *models.Message) error {
+	rev := models.Revocation{}
+	err := db.Get(&rev, "SELECT id, message_id, message_sha256, reason, created_at FROM revocations WHERE message_id = $1", message.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	rev.Signatures = []*models.Signature{}
+	err = db.Select(&rev.Signatures, "SELECT id, format, blob, key, certificate, created_at FROM revocation_signatures WHERE revocation_id = $1", rev.ID)
+	if err != nil {
+		return err
+	}
+
+	message.Revoked = true
+	message.Revocation = &rev
+	return nil
+}
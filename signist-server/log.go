@@ -0,0 +1,641 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/jmoiron/sqlx"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/translog"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every message and revocation is appended to a Merkle tree log, served
+// through a subset of the Rekor API under /api/v1 so transparency log
+// clients can look entries up and check inclusion and consistency proofs.
+// Entry bodies are of kind signist, which Rekor clients such as rekor-cli and
+// cosign cannot decode, and hashedrekord uploads are rejected.
+
+// Rekor identifies its trees by number. signist has a single tree.
+const logTreeID = "1"
+
+type transparencyLog struct {
+	key    *ecdsa.PrivateKey
+	id     string
+	name   string
+	origin string
+}
+
+// A row of log_entries. Body is the JSON of a models.LogEntryBody.
+type logRow struct {
+	LogIndex       int       `db:"log_index"`
+	UUID           string    `db:"uuid"`
+	Body           string    `db:"body"`
+	IntegratedTime time.Time `db:"integrated_time"`
+}
+
+// The log signing key from the PEM file at SIGNIST_LOG_KEY, and its name in
+// checkpoints from SIGNIST_LOG_NAME. The key is required: a temporary key is
+// only generated, for development, when SIGNIST_LOG_KEY_EPHEMERAL is true,
+// since checkpoints and entry timestamps from earlier runs could no longer
+// be verified.
+func loadTransparencyLog() *transparencyLog {
+	name := os.Getenv("SIGNIST_LOG_NAME")
+	if len(name) == 0 {
+		name = "signist"
+	} else if strings.ContainsAny(name, " \t\n") {
+		log.Fatalf("SIGNIST_LOG_NAME %q must not contain whitespace\n", name)
+	}
+
+	var key *ecdsa.PrivateKey
+	if path := os.Getenv("SIGNIST_LOG_KEY"); len(path) > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Could not read SIGNIST_LOG_KEY: %s\n", err.Error())
+		}
+		if key, err = parseLogKey(data); err != nil {
+			log.Fatalf("Could not parse SIGNIST_LOG_KEY: %s\n", err.Error())
+		}
+	} else if ephemeral, _ := strconv.ParseBool(os.Getenv("SIGNIST_LOG_KEY_EPHEMERAL")); ephemeral {
+		log.Println("SIGNIST_LOG_KEY_EPHEMERAL is set, signing the log with a temporary key")
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			log.Fatalln(err)
+		}
+	} else {
+		log.Fatalln("SIGNIST_LOG_KEY must be set to the PEM file of the log's signing key. Set SIGNIST_LOG_KEY_EPHEMERAL=true to use a temporary key in development.")
+	}
+
+	id, err := translog.LogID(&key.PublicKey)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return &transparencyLog{key: key, id: id, name: name, origin: name + " - " + logTreeID}
+}
+
+func parseLogKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("log key must be an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// Append a stored message to the log
+func logMessage(tx *sqlx.Tx, message *models.Message) error {
+	logged := *message
+	logged.Revoked, logged.Revocation, logged.Expired, logged.NotYetValid = false, nil, false, false
+
+	blobHash, err := blobSHA256(message)
+	if err != nil {
+		return err
+	}
+
+	return appendLogEntry(tx, models.LogEntrySpec{Message: &logged}, message.ID, nil, &blobHash)
+}
+
+// The hex SHA-256 of a message's blob, which the log index is searched by
+func blobSHA256(message *models.Message) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(*message.Blob)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(blob)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Append a stored revocation to the log
+func logRevocation(tx *sqlx.Tx, rev *models.Revocation) error {
+	return appendLogEntry(tx, models.LogEntrySpec{Revocation: rev}, nil, rev.ID, nil)
+}
+
+// Give the entry the next log index. The table is locked until the
+// transaction ends so that indexes are assigned in commit order with no gaps.
+func appendLogEntry(tx *sqlx.Tx, spec models.LogEntrySpec, messageID *int, revocationID *int, blobHash *string) error {
+	body, err := json.Marshal(models.LogEntryBody{APIVersion: models.LogEntryAPIVersion, Kind: models.LogEntryKind, Spec: spec})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("LOCK TABLE log_entries IN EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	var index int
+	if err := tx.Get(&index, "SELECT COALESCE(MAX(log_index) + 1, 0) FROM log_entries"); err != nil {
+		return err
+	}
+
+	row := logRow{LogIndex: index, UUID: hex.EncodeToString(translog.LeafHash(body)), Body: string(body), IntegratedTime: time.Now()}
+	return insertLogEntry(tx, row, messageID, revocationID, blobHash)
+}
+
+// Insert a log entry and the tree nodes it completes, and announce it to the
+// streams
+func insertLogEntry(tx *sqlx.Tx, row logRow, messageID *int, revocationID *int, blobHash *string) error {
+	_, err := tx.Exec(`INSERT INTO log_entries (log_index, uuid, message_id, revocation_id, blob_sha256, body, integrated_time) VALUES ($1, $2, $3, $4, $5, $6, $7)`, row.LogIndex, row.UUID, messageID, revocationID, blobHash, row.Body, row.IntegratedTime)
+	if err != nil {
+		return err
+	}
+
+	if err := appendLogNodes(tx, row); err != nil {
+		return err
+	}
+
+	// Postgres delivers the notification to every server's stream when the
+	// transaction commits, and drops it if it rolls back
+	_, err = tx.Exec("SELECT pg_notify($1, $2)", logChannel, strconv.Itoa(row.LogIndex))
+	return err
+}
+
+// The log's tree, read from the perfect subtree hashes in log_tree_nodes
+// rather than rebuilt from every leaf. Nodes are cached for the life of the
+// tree, which is one request or transaction.
+type logTree struct {
+	q     sqlx.Queryer
+	size  int
+	nodes map[[2]int][]byte
+}
+
+// The tree of every entry q can see
+func loadLogTree(q sqlx.Queryer) (*logTree, error) {
+	tree := &logTree{q: q, nodes: map[[2]int][]byte{}}
+	if err := sqlx.Get(q, &tree.size, "SELECT COALESCE(MAX(log_index) + 1, 0) FROM log_entries"); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func (tree *logTree) Node(level int, index int) ([]byte, error) {
+	if hash, ok := tree.nodes[[2]int{level, index}]; ok {
+		return hash, nil
+	}
+
+	var hexHash string
+	err := sqlx.Get(tree.q, &hexHash, "SELECT hash FROM log_tree_nodes WHERE level = $1 AND node_index = $2", level, index)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("log tree node %d/%d is missing, run signist-server backfill", level, index)
+	} else if err != nil {
+		return nil, err
+	}
+
+	hash, err := hex.DecodeString(hexHash)
+	if err != nil {
+		return nil, err
+	}
+	tree.nodes[[2]int{level, index}] = hash
+	return hash, nil
+}
+
+func (tree *logTree) root() ([]byte, error) {
+	return translog.RootHashFrom(tree, tree.size)
+}
+
+// Store the nodes the entry in row completes. Entries are appended in index
+// order, so the nodes of every earlier entry are already stored.
+func appendLogNodes(tx *sqlx.Tx, row logRow) error {
+	leaf, err := hex.DecodeString(row.UUID)
+	if err != nil {
+		return err
+	}
+
+	tree := &logTree{q: tx, size: row.LogIndex, nodes: map[[2]int][]byte{}}
+	nodes, err := translog.AppendNodes(tree, row.LogIndex, leaf)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if _, err := tx.Exec("INSERT INTO log_tree_nodes (level, node_index, hash) VALUES ($1, $2, $3)", node.Level, node.Index, hex.EncodeToString(node.Hash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Held while backfilling, so that two backfills never log the same message
+const backfillLockID = 0x7369676e697374
+
+// Run `signist-server backfill`: store the tree nodes of entries logged
+// before nodes were stored, then log the messages and revocations stored
+// before the log existed. Run it once when upgrading, before starting the
+// servers.
+func runBackfill() {
+	db, err := sqlx.Connect("postgres", databaseString())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer db.Close()
+
+	if err := backfillLog(db); err != nil {
+		log.Fatalf("Could not backfill the log: %s\n", err.Error())
+	}
+}
+
+// Fail unless every entry has its tree nodes stored and, unless the server
+// is a mirror, every message and revocation has been logged
+func checkBackfilled(db *sqlx.DB, mirror bool) error {
+	var entries, leaves int
+	if err := db.Get(&entries, "SELECT COALESCE(MAX(log_index) + 1, 0) FROM log_entries"); err != nil {
+		return err
+	}
+	if err := db.Get(&leaves, "SELECT COALESCE(MAX(node_index) + 1, 0) FROM log_tree_nodes WHERE level = 0"); err != nil {
+		return err
+	}
+	if entries != leaves {
+		return fmt.Errorf("%d log entries have no tree nodes, run signist-server backfill", entries-leaves)
+	}
+
+	if mirror {
+		return nil
+	}
+
+	var unlogged bool
+	err := db.Get(&unlogged, "SELECT EXISTS (SELECT 1 FROM messages WHERE id NOT IN (SELECT message_id FROM log_entries WHERE message_id IS NOT NULL)) OR EXISTS (SELECT 1 FROM revocations WHERE id NOT IN (SELECT revocation_id FROM log_entries WHERE revocation_id IS NOT NULL))")
+	if err != nil {
+		return err
+	}
+	if unlogged {
+		return errors.New("some messages or revocations are not in the log, run signist-server backfill")
+	}
+	return nil
+}
+
+// Backfill in one transaction under an advisory lock, in the order entries
+// and messages were made
+func backfillLog(db *sqlx.DB) error {
+	tx := db.MustBegin()
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", backfillLockID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	nodes, err := backfillLogNodes(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	messages := []*models.Message{}
+	err = tx.Select(&messages, "SELECT * FROM messages WHERE id NOT IN (SELECT message_id FROM log_entries WHERE message_id IS NOT NULL) ORDER BY id")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, message := range messages {
+		message.Signatures = []*models.Signature{}
+		if err := tx.Select(&message.Signatures, "SELECT * FROM signatures WHERE message_id = $1 ORDER BY id", message.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Select(&message.Subjects, `SELECT name, algorithm, digest FROM message_subjects WHERE message_id = $1 ORDER BY name COLLATE "C", algorithm COLLATE "C", digest`, message.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := logMessage(tx, message); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	revs := []*models.Revocation{}
+	err = tx.Select(&revs, "SELECT id, message_id, message_sha256, reason, created_at FROM revocations WHERE id NOT IN (SELECT revocation_id FROM log_entries WHERE revocation_id IS NOT NULL) ORDER BY id")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, rev := range revs {
+		rev.GithubLogin = new(string)
+		if err := tx.Get(rev.GithubLogin, "SELECT github_login FROM messages WHERE id = $1", rev.MessageID); err != nil {
+			tx.Rollback()
+			return err
+		}
+		rev.Signatures = []*models.Signature{}
+		if err := tx.Select(&rev.Signatures, "SELECT id, format, blob, key, certificate, created_at FROM revocation_signatures WHERE revocation_id = $1 ORDER BY id", rev.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := logRevocation(tx, rev); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Stored tree nodes for %d log entries, and added %d messages and %d revocations to the log\n", nodes, len(messages), len(revs))
+	return nil
+}
+
+// Store the tree nodes of the entries logged before nodes were, returning
+// how many entries there were
+func backfillLogNodes(tx *sqlx.Tx) (int, error) {
+	rows := []logRow{}
+	err := tx.Select(&rows, "SELECT log_index, uuid, body, integrated_time FROM log_entries WHERE log_index > (SELECT COALESCE(MAX(node_index), -1) FROM log_tree_nodes WHERE level = 0) ORDER BY log_index")
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		if err := appendLogNodes(tx, row); err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+// A signed checkpoint for the tree of size entries with root
+func (tl *transparencyLog) checkpoint(size int, root []byte) (string, error) {
+	return translog.Checkpoint{Origin: tl.origin, Size: size, RootHash: root}.Sign(tl.name, tl.key)
+}
+
+// The entry in a row, with its signed entry timestamp and its inclusion
+// proof in tree
+func (tl *transparencyLog) entry(row logRow, tree *logTree) (map[string]models.LogEntry, error) {
+	body := base64.StdEncoding.EncodeToString([]byte(row.Body))
+	integratedTime := row.IntegratedTime.Unix()
+
+	set, err := translog.SignEntryTimestamp(tl.key, body, integratedTime, tl.id, row.LogIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := translog.InclusionProofFrom(tree, row.LogIndex, tree.size)
+	if err != nil {
+		return nil, err
+	}
+	root, err := tree.root()
+	if err != nil {
+		return nil, err
+	}
+	note, err := tl.checkpoint(tree.size, root)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.LogEntry{
+		Body:           body,
+		IntegratedTime: integratedTime,
+		LogID:          tl.id,
+		LogIndex:       row.LogIndex,
+		Verification: &models.LogVerification{
+			SignedEntryTimestamp: set,
+			InclusionProof: &models.InclusionProof{
+				LogIndex:   row.LogIndex,
+				RootHash:   hex.EncodeToString(root),
+				TreeSize:   tree.size,
+				Hashes:     hexHashes(proof),
+				Checkpoint: note,
+			},
+		},
+	}
+	return map[string]models.LogEntry{row.UUID: entry}, nil
+}
+
+func hexHashes(hashes [][]byte) []string {
+	out := make([]string, len(hashes))
+	for i, h := range hashes {
+		out[i] = hex.EncodeToString(h)
+	}
+	return out
+}
+
+// Write the log entry in the row with this condition
+func (s *server) writeLogEntry(w http.ResponseWriter, req *http.Request, status int, where string, arg interface{}) {
+	row := logRow{}
+	err := s.db.Get(&row, "SELECT log_index, uuid, body, integrated_time FROM log_entries WHERE "+where+" = $1", arg)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusNotFound, models.CodeNotFound, "Log entry not found", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	tree, err := loadLogTree(s.db)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	entry, err := s.log.entry(row, tree)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", "/api/v1/log/entries/"+row.UUID)
+	}
+	writeJSON(w, status, entry)
+}
+
+func (s *server) postLogEntry(w http.ResponseWriter, req *http.Request) {
+	body := models.LogEntryBody{}
+	if !bind(w, req, &body) {
+		return
+	}
+
+	if s.storeMessage(w, req, body.Spec.Message) {
+		s.writeLogEntry(w, req, http.StatusCreated, "message_id", *body.Spec.Message.ID)
+	}
+}
+
+func (s *server) getLogEntry(w http.ResponseWriter, req *http.Request) {
+	uuid := strings.ToLower(pathParam(req, "uuid"))
+
+	// Rekor UUIDs may be prefixed with a 16 character tree ID
+	if len(uuid) == 80 {
+		uuid = uuid[16:]
+	}
+	if _, err := hex.DecodeString(uuid); err != nil || len(uuid) != 64 {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Entry UUID must be 64 hex characters", nil)
+		return
+	}
+
+	s.writeLogEntry(w, req, http.StatusOK, "uuid", uuid)
+}
+
+// The entry at logIndex, or the entries from start up to end
+func (s *server) getLogEntries(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if len(query.Get("start")) > 0 {
+		s.getLogEntryRange(w, req)
+		return
+	}
+
+	index, err := strconv.Atoi(query.Get("logIndex"))
+	if err != nil || index < 0 {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "logIndex must be a non-negative number", nil)
+		return
+	}
+
+	s.writeLogEntry(w, req, http.StatusOK, "log_index", index)
+}
+
+// The entries from start up to end, in order, each with its inclusion proof
+// in the current tree. Entries past the end of the log are left out.
+func (s *server) getLogEntryRange(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	start, startErr := strconv.Atoi(query.Get("start"))
+	end, endErr := strconv.Atoi(query.Get("end"))
+	if startErr != nil || endErr != nil || start < 0 || end <= start || end-start > models.MaxLogEntryRange {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, fmt.Sprintf("start and end must be log indexes with start < end <= start + %d", models.MaxLogEntryRange), nil)
+		return
+	}
+
+	rows := []logRow{}
+	if err := s.db.Select(&rows, "SELECT log_index, uuid, body, integrated_time FROM log_entries WHERE log_index >= $1 AND log_index < $2 ORDER BY log_index", start, end); err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	tree, err := loadLogTree(s.db)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	entries := make([]map[string]models.LogEntry, 0, len(rows))
+	for _, row := range rows {
+		entry, err := s.log.entry(row, tree)
+		if err != nil {
+			writeInternalError(w, req, err)
+			return
+		}
+		entries = append(entries, entry)
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// List the UUIDs of the entries for messages whose blob has the hash
+func (s *server) searchLogIndex(w http.ResponseWriter, req *http.Request) {
+	search := models.SearchIndex{}
+	if !bind(w, req, &search) {
+		return
+	}
+
+	uuids := []string{}
+	hash := strings.ToLower(strings.TrimPrefix(search.Hash, "sha256:"))
+	if err := s.db.Select(&uuids, "SELECT uuid FROM log_entries WHERE blob_sha256 = $1 ORDER BY log_index", hash); err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, uuids)
+}
+
+func (s *server) getLogInfo(w http.ResponseWriter, req *http.Request) {
+	tree, err := loadLogTree(s.db)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	root, err := tree.root()
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	note, err := s.log.checkpoint(tree.size, root)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.LogInfo{
+		RootHash:       hex.EncodeToString(root),
+		TreeSize:       tree.size,
+		SignedTreeHead: note,
+		TreeID:         logTreeID,
+	})
+}
+
+// Prove that the tree of firstSize entries is a prefix of the tree of
+// lastSize entries
+func (s *server) getLogProof(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	firstSize, lastSize := 1, -1
+	var err error
+	if str := query.Get("firstSize"); len(str) > 0 {
+		if firstSize, err = strconv.Atoi(str); err != nil {
+			firstSize = -1
+		}
+	}
+	if lastSize, err = strconv.Atoi(query.Get("lastSize")); err != nil {
+		lastSize = -1
+	}
+
+	tree, err := loadLogTree(s.db)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if firstSize < 0 || lastSize < firstSize || lastSize > tree.size {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "firstSize and lastSize must be sizes the log has had, with firstSize no more than lastSize", nil)
+		return
+	}
+
+	proof, err := translog.ConsistencyProofFrom(tree, firstSize, lastSize)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	root, err := translog.RootHashFrom(tree, lastSize)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ConsistencyProof{
+		RootHash: hex.EncodeToString(root),
+		Hashes:   hexHashes(proof),
+	})
+}
+
+func (s *server) getLogPublicKey(w http.ResponseWriter, req *http.Request) {
+	der, err := x509.MarshalPKIXPublicKey(&s.log.key.PublicKey)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
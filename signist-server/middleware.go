@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/andrewhamon/signist/models"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+)
+
+var requestIDRegex = regexp.MustCompile(`\A[a-zA-Z\d\-\_]{1,64}\z`)
+
+// Records the status written by a handler so it can be logged
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Allow http.ResponseController to reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Use the caller's X-Request-Id if it is sane, or generate one, and echo it
+// in the response
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get("X-Request-Id")
+		if !requestIDRegex.MatchString(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+			req.Header.Set("X-Request-Id", id)
+		}
+
+		w.Header().Set("X-Request-Id", id)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Log each request once it has been handled
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, req)
+
+		log.Printf("%s %s %s %d %s\n", req.Header.Get("X-Request-Id"), req.Method, req.URL.Path, rec.status, time.Since(start))
+	})
+}
+
+// Turn a panicking handler into a 500 instead of dropping the connection
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				log.Printf("%s panic: %v\n%s", req.Header.Get("X-Request-Id"), err, debug.Stack())
+				writeError(w, req, http.StatusInternalServerError, models.CodeInternal, "Internal server error", nil)
+			}
+		}()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Reject bodies larger than limit outright when the length is known, and
+// stop reading at limit when it is not
+func withBodyLimit(next http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ContentLength > limit {
+			writeError(w, req, http.StatusRequestEntityTooLarge, models.CodeBodyTooLarge, "Body can not be greater than 1MB", nil)
+			return
+		}
+
+		req.Body = http.MaxBytesReader(w, req.Body, limit)
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,693 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	gogithub "github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/google/go-github/github"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/jmoiron/sqlx"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/client"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/timestamp"
+	"github.com/andrewhamon/signist/translog"
+	"github.com/andrewhamon/signist/utils"
+	"github.com/andrewhamon/signist/verify"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// A mirror follows the log of an upstream signist server, re-verifying every
+// message and revocation before storing it, and serves a read-only replica of
+// the API. Entries keep their upstream IDs and log indexes, so the replica's
+// log has the same root hashes as the upstream's. Entries that do not verify,
+// such as messages signed with keys since removed from GitHub, are logged
+// but quarantined rather than served.
+
+// Entries fetched and checked against the upstream checkpoint at a time
+const mirrorBatch = models.MaxLogEntryRange
+
+type mirror struct {
+	upstream *client.Client
+	url      string
+
+	// The key upstream checkpoints must be signed with, from --upstream-key.
+	// It is pinned in mirror_state when mirroring begins.
+	key *ecdsa.PublicKey
+
+	// Keys trusted in addition to each login's GitHub keys and the keys the
+	// mirror has already accepted for it
+	signers verify.AllowedSigners
+
+	// Timestamp authority roots upstream tokens must verify against. Without
+	// them, messages with tokens are not mirrored.
+	tsaRoots *x509.CertPool
+
+	interval time.Duration
+}
+
+// The row of mirror_state
+type mirrorState struct {
+	Upstream   string    `db:"upstream"`
+	PublicKey  string    `db:"public_key"`
+	TreeSize   int       `db:"tree_size"`
+	RootHash   string    `db:"root_hash"`
+	Checkpoint *string   `db:"checkpoint"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// An upstream log entry that has been fetched and checked against its UUID
+type mirroredEntry struct {
+	row  logRow
+	spec models.LogEntrySpec
+}
+
+func parseMirrorFlags(args []string) *mirror {
+	flags := flag.NewFlagSet("mirror", flag.ExitOnError)
+	upstream := flags.String("upstream", "", "URL of the signist server to mirror")
+	keyPath := flags.String("upstream-key", "", "PEM file of the upstream log's public key")
+	signersPath := flags.String("allowed-signers", "", "allowed signers file of keys to trust in addition to each login's GitHub keys")
+	tsaCert := flags.String("tsa-cert", "", "PEM file of timestamp authority roots the upstream's timestamp tokens must verify against, required to mirror timestamped messages")
+	interval := flags.Duration("interval", 30*time.Second, "how often to check the upstream for new entries")
+	flags.Parse(args)
+
+	if len(*upstream) == 0 {
+		log.Fatalln("mirror requires --upstream")
+	}
+
+	// The key is never fetched from the upstream itself, which could serve
+	// any key it liked
+	if len(*keyPath) == 0 {
+		log.Fatalln("mirror requires --upstream-key")
+	}
+
+	m := &mirror{url: *upstream, interval: *interval}
+	var err error
+	if m.upstream, err = client.New(*upstream); err != nil {
+		log.Fatalln(err)
+	}
+
+	data, err := ioutil.ReadFile(*keyPath)
+	if err != nil {
+		log.Fatalf("Could not read --upstream-key: %s\n", err.Error())
+	}
+	if m.key, err = client.ParseLogPublicKey(data); err != nil {
+		log.Fatalf("Could not parse --upstream-key: %s\n", err.Error())
+	}
+
+	if len(*signersPath) > 0 {
+		if m.signers, err = verify.LoadAllowedSigners(*signersPath); err != nil {
+			log.Fatalf("Could not load --allowed-signers: %s\n", err.Error())
+		}
+	}
+
+	if len(*tsaCert) > 0 {
+		data, err := ioutil.ReadFile(*tsaCert)
+		if err != nil {
+			log.Fatalf("Could not read --tsa-cert: %s\n", err.Error())
+		}
+		if m.tsaRoots, err = timestamp.ParseRoots(data); err != nil {
+			log.Fatalf("Could not parse --tsa-cert: %s\n", err.Error())
+		}
+	}
+
+	return m
+}
+
+// Load the mirror's state, or start mirroring into an empty database
+func (m *mirror) init(db *sqlx.DB) error {
+	states := []mirrorState{}
+	if err := db.Select(&states, "SELECT * FROM mirror_state"); err != nil {
+		return err
+	}
+
+	if len(states) > 0 {
+		state := states[0]
+		if state.Upstream != m.url {
+			return fmt.Errorf("the database already mirrors %s", state.Upstream)
+		}
+
+		pinned, err := client.ParseLogPublicKey([]byte(state.PublicKey))
+		if err != nil {
+			return err
+		}
+		if !m.key.Equal(pinned) {
+			return errors.New("--upstream-key is not the key pinned when mirroring began")
+		}
+		return nil
+	}
+
+	var entries int
+	if err := db.Get(&entries, "SELECT count(*) FROM log_entries"); err != nil {
+		return err
+	}
+	if entries > 0 {
+		return errors.New("a mirror must start with an empty database")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(m.key)
+	if err != nil {
+		return err
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	_, err = db.Exec("INSERT INTO mirror_state (upstream, public_key, tree_size, root_hash, updated_at) VALUES ($1, $2, 0, $3, $4)", m.url, string(key), hex.EncodeToString(translog.RootHash(nil)), time.Now())
+	return err
+}
+
+// Follow the upstream until ctx is done. Checkpoints inconsistent with what
+// has been mirrored, and entries that cannot be checked yet, are logged and
+// refused again on every attempt.
+func (m *mirror) follow(ctx context.Context, db *sqlx.DB) {
+	log.Printf("Mirroring %s every %s\n", m.url, m.interval)
+
+	for {
+		if err := m.sync(ctx, db); err != nil && ctx.Err() == nil {
+			log.Printf("Mirror of %s: %s\n", m.url, err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.interval):
+		}
+	}
+}
+
+// Catch up with the upstream's latest checkpoint
+func (m *mirror) sync(ctx context.Context, db *sqlx.DB) error {
+	state := mirrorState{}
+	if err := db.Get(&state, "SELECT * FROM mirror_state WHERE upstream = $1", m.url); err != nil {
+		return err
+	}
+	root, err := hex.DecodeString(state.RootHash)
+	if err != nil {
+		return err
+	}
+
+	info, err := m.upstream.LogInfo(ctx)
+	if err != nil {
+		return err
+	}
+	cp, err := translog.ParseCheckpoint(info.SignedTreeHead, m.key)
+	if err != nil {
+		return fmt.Errorf("upstream checkpoint: %w", err)
+	}
+
+	// The upstream must never drop or rewrite what has been mirrored
+	if cp.Size < state.TreeSize {
+		return fmt.Errorf("upstream log shrank from %d to %d entries", state.TreeSize, cp.Size)
+	}
+	if err := m.checkConsistency(ctx, state.TreeSize, root, cp); err != nil {
+		return err
+	}
+	if cp.Size == state.TreeSize {
+		return nil
+	}
+
+	tree, err := loadLogTree(db)
+	if err != nil {
+		return err
+	}
+	if tree.size != state.TreeSize {
+		return fmt.Errorf("the database has %d log entries, but %d have been mirrored", tree.size, state.TreeSize)
+	}
+
+	lookups := newGithubLookups()
+	for size := tree.size; size < cp.Size; {
+		end := size + mirrorBatch
+		if end > cp.Size {
+			end = cp.Size
+		}
+
+		entries, err := m.fetch(ctx, size, end)
+		if err != nil {
+			return err
+		}
+		batch := &translog.Overlay{Base: tree, Size: size}
+		for _, entry := range entries {
+			leaf, _ := hex.DecodeString(entry.row.UUID)
+			if _, err := batch.Append(leaf); err != nil {
+				return err
+			}
+		}
+
+		// The fetched entries, after those already mirrored, must make a
+		// prefix of the signed upstream log
+		batchRoot, err := translog.RootHashFrom(batch, end)
+		if err != nil {
+			return err
+		}
+		if err := m.checkConsistency(ctx, end, batchRoot, cp); err != nil {
+			return fmt.Errorf("entries %d to %d: %w", size, end-1, err)
+		}
+
+		if err := m.store(ctx, db, lookups, entries, end, batchRoot, info.SignedTreeHead); err != nil {
+			return err
+		}
+
+		size = end
+	}
+
+	log.Printf("Mirrored %s up to %d entries\n", m.url, cp.Size)
+	return nil
+}
+
+// Check that the tree of size entries with root is a prefix of the upstream
+// checkpoint
+func (m *mirror) checkConsistency(ctx context.Context, size int, root []byte, cp translog.Checkpoint) error {
+	hashes := [][]byte{}
+	if size > 0 && size < cp.Size {
+		proof, err := m.upstream.ConsistencyProof(ctx, size, cp.Size)
+		if err != nil {
+			return err
+		}
+		for _, h := range proof.Hashes {
+			hash, err := hex.DecodeString(h)
+			if err != nil {
+				return fmt.Errorf("invalid consistency proof hash %q", h)
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+
+	if err := translog.VerifyConsistency(size, cp.Size, root, cp.RootHash, hashes); err != nil {
+		return fmt.Errorf("upstream checkpoint of %d entries is not consistent with %d mirrored entries: %w", cp.Size, size, err)
+	}
+	return nil
+}
+
+// Fetch the upstream entries from start up to end in one request, checking
+// each one's body hashes to its UUID
+func (m *mirror) fetch(ctx context.Context, start int, end int) ([]mirroredEntry, error) {
+	uuids, entries, err := m.upstream.LogEntries(ctx, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("entries %d to %d: %w", start, end-1, err)
+	}
+	if len(entries) != end-start {
+		return nil, fmt.Errorf("upstream returned %d entries from %d, expected %d", len(entries), start, end-start)
+	}
+
+	mirrored := make([]mirroredEntry, 0, len(entries))
+	for i, entry := range entries {
+		e, err := parseMirroredEntry(start+i, uuids[i], entry)
+		if err != nil {
+			return nil, err
+		}
+		mirrored = append(mirrored, e)
+	}
+	return mirrored, nil
+}
+
+// Check that an upstream entry is at index, that its body hashes to uuid and
+// that it holds exactly one message or revocation
+func parseMirroredEntry(index int, uuid string, entry *models.LogEntry) (mirroredEntry, error) {
+	body, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil || entry.LogIndex != index || hex.EncodeToString(translog.LeafHash(body)) != uuid {
+		return mirroredEntry{}, fmt.Errorf("entry %d does not match its UUID or index", index)
+	}
+
+	parsed := models.LogEntryBody{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return mirroredEntry{}, fmt.Errorf("entry %d: %w", index, err)
+	}
+	if parsed.Kind != models.LogEntryKind || parsed.APIVersion != models.LogEntryAPIVersion || (parsed.Spec.Message == nil) == (parsed.Spec.Revocation == nil) {
+		return mirroredEntry{}, fmt.Errorf("entry %d must be a %s %s message or revocation", index, models.LogEntryKind, models.LogEntryAPIVersion)
+	}
+
+	row := logRow{LogIndex: index, UUID: uuid, Body: string(body), IntegratedTime: time.Unix(entry.IntegratedTime, 0)}
+	return mirroredEntry{row: row, spec: parsed.Spec}, nil
+}
+
+// Verify a batch of entries and store them, with the new mirrored tree size
+// and root, in one transaction. Entries that do not verify are logged and
+// quarantined.
+func (m *mirror) store(ctx context.Context, db *sqlx.DB, lookups *githubLookups, entries []mirroredEntry, size int, root []byte, checkpoint string) error {
+	// Messages revoked later in the same batch are not in the database yet
+	batch := map[int]*models.Message{}
+
+	tx := db.MustBegin()
+	for _, e := range entries {
+		var err error
+		if e.spec.Message != nil {
+			if err = m.storeMessage(ctx, db, tx, lookups, e); err == nil {
+				batch[*e.spec.Message.ID] = e.spec.Message
+			}
+		} else {
+			err = m.storeRevocation(ctx, db, tx, lookups, e, batch)
+		}
+
+		var unverifiedErr *unverifiedError
+		if errors.As(err, &unverifiedErr) {
+			err = m.quarantine(tx, e, unverifiedErr)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("entry %d: %w", e.row.LogIndex, err)
+		}
+	}
+
+	_, err := tx.Exec("UPDATE mirror_state SET tree_size = $1, root_hash = $2, checkpoint = $3, updated_at = $4 WHERE upstream = $5", size, hex.EncodeToString(root), checkpoint, time.Now(), m.url)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Log an entry that did not verify without storing its message or
+// revocation, recording why
+func (m *mirror) quarantine(tx *sqlx.Tx, e mirroredEntry, reason error) error {
+	log.Printf("Mirror of %s: quarantined entry %d: %s\n", m.url, e.row.LogIndex, reason.Error())
+
+	if err := insertLogEntry(tx, e.row, nil, nil, nil); err != nil {
+		return err
+	}
+	_, err := tx.Exec("INSERT INTO mirror_quarantine (log_index, uuid, reason, quarantined_at) VALUES ($1, $2, $3, $4)", e.row.LogIndex, e.row.UUID, reason.Error(), time.Now())
+	return err
+}
+
+func (m *mirror) storeMessage(ctx context.Context, db *sqlx.DB, tx *sqlx.Tx, lookups *githubLookups, e mirroredEntry) error {
+	message := e.spec.Message
+	if message.ID == nil || message.GithubLogin == nil || message.Title == nil || message.Blob == nil || message.CreatedAt == nil {
+		return unverified(errors.New("message is missing its id, github_login, title, blob or created_at"))
+	}
+
+	keys, err := m.verifyMessage(ctx, db, lookups, message, false)
+	if err != nil {
+		return err
+	}
+
+	// Subjects are derived again rather than taken from the upstream
+	message.GithubKeys = keys
+	if errs := message.ValidateEnvelope(); len(errs) > 0 {
+		return unverified(errs[0])
+	}
+
+	_, err = tx.Exec(`INSERT INTO messages (id, version, github_id, github_login, title, blob, content_type, metadata, not_before, expires_at, timestamp_token, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`, message.ID, message.Version, message.GithubID, message.GithubLogin, message.Title, message.Blob, message.ContentType, message.Metadata, message.NotBefore, message.ExpiresAt, message.TimestampToken, message.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, sig := range message.Signatures {
+		if sig.ID == nil || sig.Key == nil || sig.CreatedAt == nil {
+			return errors.New("signature is missing its id, key or created_at")
+		}
+		_, err := tx.Exec(`INSERT INTO signatures (id, message_id, format, blob, key, certificate, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`, sig.ID, message.ID, sig.Format, sig.Blob, utils.PubKeyToString(*sig.Key), sig.Certificate, sig.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, subject := range message.Subjects {
+		_, err := tx.Exec(`INSERT INTO message_subjects (message_id, name, algorithm, digest) VALUES ($1, $2, $3, $4)`, message.ID, subject.Name, subject.Algorithm, subject.Digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	blobHash, err := blobSHA256(message)
+	if err != nil {
+		return err
+	}
+	return insertLogEntry(tx, e.row, message.ID, nil, &blobHash)
+}
+
+func (m *mirror) storeRevocation(ctx context.Context, db *sqlx.DB, tx *sqlx.Tx, lookups *githubLookups, e mirroredEntry, batch map[int]*models.Message) error {
+	rev := e.spec.Revocation
+	if rev.ID == nil || rev.MessageID == nil || rev.GithubLogin == nil || rev.Reason == nil || rev.CreatedAt == nil {
+		return unverified(errors.New("revocation is missing its id, message_id, github_login, reason or created_at"))
+	}
+
+	message, ok := batch[*rev.MessageID]
+	if !ok {
+		message = &models.Message{}
+		err := db.Get(message, "SELECT * FROM messages WHERE id = $1", rev.MessageID)
+		if err == sql.ErrNoRows {
+			return unverified(fmt.Errorf("revocation of message %d, which the mirror has not stored", *rev.MessageID))
+		} else if err != nil {
+			return err
+		}
+		if err := db.Select(&message.Signatures, "SELECT * FROM signatures WHERE message_id = $1 ORDER BY id", message.ID); err != nil {
+			return err
+		}
+	}
+	if *message.GithubLogin != *rev.GithubLogin {
+		return unverified(fmt.Errorf("revocation by %s of a message signed by %s", *rev.GithubLogin, *message.GithubLogin))
+	}
+
+	revoked := *message
+	revoked.Revoked, revoked.Revocation = true, rev
+	if _, err := m.verifyMessage(ctx, db, lookups, &revoked, true); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`INSERT INTO revocations (id, message_id, message_sha256, reason, created_at) VALUES ($1, $2, $3, $4, $5)`, rev.ID, rev.MessageID, rev.MessageDigest, rev.Reason, rev.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, sig := range rev.Signatures {
+		if sig.ID == nil || sig.Key == nil || sig.CreatedAt == nil {
+			return errors.New("signature is missing its id, key or created_at")
+		}
+		_, err := tx.Exec(`INSERT INTO revocation_signatures (id, revocation_id, format, blob, key, certificate, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`, sig.ID, rev.ID, sig.Format, sig.Blob, utils.PubKeyToString(*sig.Key), sig.Certificate, sig.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return insertLogEntry(tx, e.row, nil, rev.ID, nil)
+}
+
+// Verify a message's signatures, or its revocation's, against the keys the
+// mirror trusts for its signer, returning those keys. Failures that checking
+// again would not change are unverifiedErrors.
+func (m *mirror) verifyMessage(ctx context.Context, db *sqlx.DB, lookups *githubLookups, message *models.Message, revocation bool) ([]ssh.PublicKey, error) {
+	login := *message.GithubLogin
+	user, err := lookups.user(login)
+	if github.IsNotFound(err) {
+		return nil, unverified(fmt.Errorf("github user or organization %s no longer exists", login))
+	} else if err != nil {
+		return nil, err
+	}
+	message.GithubID = user.ID
+
+	githubKeys, err := lookups.keys(login)
+	if err != nil {
+		return nil, err
+	}
+
+	trust := mirrorKeys{db: db, githubID: *user.ID, github: githubKeys, signers: m.signers}
+	keys, err := trust.Keys(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	cas, err := trust.CertificateAuthorities(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	source := verify.WithCAs{KeySource: verify.Snapshot{login: keys}, CAs: verify.Snapshot{login: cas}}
+	if err := m.check(ctx, message, source, revocation); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Check a message, or its revocation, against source
+func (m *mirror) check(ctx context.Context, message *models.Message, source verify.KeySource, revocation bool) error {
+	// History is mirrored as it was, expired, revoked or not yet valid
+	policy := verify.Policy{AllowExpired: true, AllowRevoked: true}
+
+	// Tokens are never stored unchecked. Messages from before the upstream
+	// timestamped have none, and are still mirrored.
+	if !revocation && message.TimestampToken != nil {
+		if m.tsaRoots == nil {
+			return errors.New("message has a timestamp token, which cannot be checked without --tsa-cert")
+		}
+		policy.TimestampRoots = m.tsaRoots
+	}
+
+	result, err := verify.Verify(ctx, message, source, policy)
+	if revocation {
+		if !result.RevocationVerified {
+			return unverified(errors.New("revocation signatures did not verify"))
+		}
+	} else if err != nil && err != verify.ErrNotYetValid {
+		return unverified(signatureProblem(result, err))
+	}
+	return nil
+}
+
+// The first signature's problem when err is about signatures, which says
+// which key failed, or else err
+func signatureProblem(result verify.Result, err error) error {
+	if err != verify.ErrSignatureInvalid {
+		return err
+	}
+	for _, sig := range result.Signatures {
+		if sig.Err != nil {
+			return fmt.Errorf("%w: signature %d: %s", err, sig.Index, sig.Err.Message)
+		}
+	}
+	return err
+}
+
+// Why an upstream entry could not be verified. The entry is quarantined,
+// since fetching and checking it again would fail the same way.
+type unverifiedError struct {
+	err error
+}
+
+func unverified(err error) error {
+	return &unverifiedError{err: err}
+}
+
+func (err *unverifiedError) Error() string {
+	return err.err.Error()
+}
+
+func (err *unverifiedError) Unwrap() error {
+	return err.err
+}
+
+// The GitHub users and keys looked up during one sync, so that each login
+// is fetched once however many of its entries there are. Failed lookups are
+// not kept.
+type githubLookups struct {
+	userFor func(login string) (*gogithub.User, error)
+	keysFor func(user *gogithub.User) ([]ssh.PublicKey, error)
+
+	users      map[string]*gogithub.User
+	githubKeys map[string][]ssh.PublicKey
+}
+
+func newGithubLookups() *githubLookups {
+	return &githubLookups{userFor: github.UserFor, keysFor: github.KeysFor, users: map[string]*gogithub.User{}, githubKeys: map[string][]ssh.PublicKey{}}
+}
+
+func (lookups *githubLookups) user(login string) (*gogithub.User, error) {
+	if user, ok := lookups.users[login]; ok {
+		return user, nil
+	}
+
+	user, err := lookups.userFor(login)
+	if err != nil {
+		return nil, err
+	}
+	lookups.users[login] = user
+	return user, nil
+}
+
+// The keys GitHub lists for login
+func (lookups *githubLookups) keys(login string) ([]ssh.PublicKey, error) {
+	if keys, ok := lookups.githubKeys[login]; ok {
+		return keys, nil
+	}
+
+	user, err := lookups.user(login)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := lookups.keysFor(user)
+	if err != nil {
+		return nil, err
+	}
+	lookups.githubKeys[login] = keys
+	return keys, nil
+}
+
+// The keys trusted for a login: its GitHub keys, the keys of signatures the
+// mirror has already accepted from it, and any allowed signers. Keys a login
+// has since removed from GitHub still verify what they signed earlier.
+type mirrorKeys struct {
+	db       *sqlx.DB
+	githubID int
+	github   []ssh.PublicKey
+	signers  verify.AllowedSigners
+}
+
+func (keys mirrorKeys) Keys(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	trusted := append([]ssh.PublicKey{}, keys.github...)
+
+	rows := []models.PublicKey{}
+	err := keys.db.Select(&rows, "SELECT DISTINCT signatures.key FROM signatures JOIN messages ON messages.id = signatures.message_id WHERE messages.github_id = $1 AND signatures.certificate IS NULL", keys.githubID)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		trusted = append(trusted, row.PublicKey)
+	}
+
+	allowed, err := keys.signers.Keys(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	return append(trusted, allowed...), nil
+}
+
+func (keys mirrorKeys) CertificateAuthorities(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	cas, err := certificateAuthorities(keys.db, keys.githubID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := keys.signers.CertificateAuthorities(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	return append(cas, allowed...), nil
+}
+
+// How far the mirror has followed its upstream, and the entries it
+// quarantined
+func (s *server) getMirrorStatus(w http.ResponseWriter, req *http.Request) {
+	if s.mirror == nil {
+		writeError(w, req, http.StatusNotFound, models.CodeNotFound, "This server is not a mirror", nil)
+		return
+	}
+
+	state := mirrorState{}
+	if err := s.db.Get(&state, "SELECT * FROM mirror_state WHERE upstream = $1", s.mirror.url); err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	quarantined := []models.QuarantinedEntry{}
+	if err := s.db.Select(&quarantined, "SELECT log_index, uuid, reason, quarantined_at FROM mirror_quarantine ORDER BY log_index"); err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.MirrorStatus{
+		Upstream:    state.Upstream,
+		TreeSize:    state.TreeSize,
+		UpdatedAt:   state.UpdatedAt,
+		Quarantined: quarantined,
+	})
+}
+
+// Refuse writes on a mirror
+func (s *server) writable(handler http.HandlerFunc) http.HandlerFunc {
+	if s.mirror == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", "GET")
+		writeError(w, req, http.StatusMethodNotAllowed, models.CodeReadOnly, "This server is a read-only mirror of "+s.mirror.url, nil)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	gogithub "github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/google/go-github/github"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/timestamp"
+	"github.com/andrewhamon/signist/translog"
+	"github.com/andrewhamon/signist/verify"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMirroredEntry(t *testing.T) {
+	id, login, title, blob := 7, "octocat", "release", base64.StdEncoding.EncodeToString([]byte("v1"))
+	message := &models.Message{ID: &id, GithubLogin: &login, Title: &title, Blob: &blob}
+	revocation := &models.Revocation{MessageID: &id}
+
+	body := func(kind string, spec models.LogEntrySpec) []byte {
+		data, err := json.Marshal(models.LogEntryBody{APIVersion: models.LogEntryAPIVersion, Kind: kind, Spec: spec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	entry := func(index int, data []byte) *models.LogEntry {
+		return &models.LogEntry{Body: base64.StdEncoding.EncodeToString(data), LogIndex: index, IntegratedTime: 1700000000}
+	}
+	uuid := func(data []byte) string {
+		return hex.EncodeToString(translog.LeafHash(data))
+	}
+
+	good := body(models.LogEntryKind, models.LogEntrySpec{Message: message})
+	both := body(models.LogEntryKind, models.LogEntrySpec{Message: message, Revocation: revocation})
+	neither := body(models.LogEntryKind, models.LogEntrySpec{})
+	otherKind := body("hashedrekord", models.LogEntrySpec{Message: message})
+
+	tests := []struct {
+		name  string
+		uuid  string
+		entry *models.LogEntry
+		err   string
+	}{
+		{name: "message", uuid: uuid(good), entry: entry(3, good)},
+		{name: "different index", uuid: uuid(good), entry: entry(4, good), err: "does not match its UUID or index"},
+		{name: "different uuid", uuid: uuid(both), entry: entry(3, good), err: "does not match its UUID or index"},
+		{name: "not base64", uuid: uuid(good), entry: &models.LogEntry{Body: "!", LogIndex: 3}, err: "does not match its UUID or index"},
+		{name: "message and revocation", uuid: uuid(both), entry: entry(3, both), err: "must be a signist"},
+		{name: "neither", uuid: uuid(neither), entry: entry(3, neither), err: "must be a signist"},
+		{name: "another kind", uuid: uuid(otherKind), entry: entry(3, otherKind), err: "must be a signist"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, err := parseMirroredEntry(3, test.uuid, test.entry)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error is %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.row.LogIndex != 3 || e.row.UUID != test.uuid || e.row.Body != string(good) || !e.row.IntegratedTime.Equal(time.Unix(1700000000, 0)) {
+				t.Errorf("row is %+v", e.row)
+			}
+			if e.spec.Message == nil || *e.spec.Message.ID != id {
+				t.Errorf("spec is %+v", e.spec)
+			}
+		})
+	}
+}
+
+func TestGithubLookupsFetchEachLoginOnce(t *testing.T) {
+	key := newTestSigner(t).PublicKey()
+	users, keys := map[string]int{}, map[string]int{}
+
+	lookups := newGithubLookups()
+	lookups.userFor = func(login string) (*gogithub.User, error) {
+		users[login]++
+		if login == "ghost" {
+			return nil, errors.New("not found")
+		}
+		id := len(login)
+		return &gogithub.User{Login: &login, ID: &id}, nil
+	}
+	lookups.keysFor = func(user *gogithub.User) ([]ssh.PublicKey, error) {
+		keys[*user.Login]++
+		return []ssh.PublicKey{key}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		for _, login := range []string{"octocat", "hubot"} {
+			if _, err := lookups.user(login); err != nil {
+				t.Fatal(err)
+			}
+			if found, err := lookups.keys(login); err != nil || len(found) != 1 {
+				t.Fatalf("keys for %s are %v, %v", login, found, err)
+			}
+		}
+		if _, err := lookups.keys("ghost"); err == nil {
+			t.Fatal("expected an error for a missing login")
+		}
+	}
+
+	for _, login := range []string{"octocat", "hubot"} {
+		if users[login] != 1 || keys[login] != 1 {
+			t.Errorf("%s was looked up %d times and its keys fetched %d times, want once each", login, users[login], keys[login])
+		}
+	}
+	if users["ghost"] != 3 {
+		t.Errorf("a failed lookup was retried %d times, want 3", users["ghost"])
+	}
+}
+
+func TestMirrorCheck(t *testing.T) {
+	signer := newTestSigner(t)
+	removed := newTestSigner(t)
+	login := "octocat"
+
+	authority, err := timestamp.NewAuthority()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsa := httptest.NewServer(authority)
+	defer tsa.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Root)
+
+	other, err := timestamp.NewAuthority()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other.Root)
+
+	stamped := func(t *testing.T, message *models.Message) *models.Message {
+		der, err := timestamp.Fetch(context.Background(), tsa.URL, message.TimestampDigest())
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := base64.StdEncoding.EncodeToString(der)
+		message.TimestampToken = &token
+		return message
+	}
+
+	tests := []struct {
+		name       string
+		message    func(t *testing.T) *models.Message
+		keys       []ssh.PublicKey
+		roots      *x509.CertPool
+		revocation bool
+
+		// Whether the check fails, and whether it quarantines the entry
+		fails      bool
+		unverified bool
+	}{
+		{
+			name:    "signed by a trusted key",
+			message: func(t *testing.T) *models.Message { return signedMessage(t, login, signer) },
+			keys:    []ssh.PublicKey{signer.PublicKey()},
+		},
+		{
+			name:       "signed by a key since removed from github",
+			message:    func(t *testing.T) *models.Message { return signedMessage(t, login, removed) },
+			keys:       []ssh.PublicKey{signer.PublicKey()},
+			fails:      true,
+			unverified: true,
+		},
+		{
+			name:    "timestamped",
+			message: func(t *testing.T) *models.Message { return stamped(t, signedMessage(t, login, signer)) },
+			keys:    []ssh.PublicKey{signer.PublicKey()},
+			roots:   roots,
+		},
+		{
+			name:    "not timestamped, with roots",
+			message: func(t *testing.T) *models.Message { return signedMessage(t, login, signer) },
+			keys:    []ssh.PublicKey{signer.PublicKey()},
+			roots:   roots,
+		},
+		{
+			name:    "timestamped, without roots",
+			message: func(t *testing.T) *models.Message { return stamped(t, signedMessage(t, login, signer)) },
+			keys:    []ssh.PublicKey{signer.PublicKey()},
+			fails:   true,
+		},
+		{
+			name:       "timestamped by an untrusted authority",
+			message:    func(t *testing.T) *models.Message { return stamped(t, signedMessage(t, login, signer)) },
+			keys:       []ssh.PublicKey{signer.PublicKey()},
+			roots:      otherRoots,
+			fails:      true,
+			unverified: true,
+		},
+		{
+			name: "revoked by a trusted key",
+			message: func(t *testing.T) *models.Message {
+				return revokedMessage(t, signedMessage(t, login, signer), signer)
+			},
+			keys:       []ssh.PublicKey{signer.PublicKey()},
+			revocation: true,
+		},
+		{
+			name: "revoked by a key since removed from github",
+			message: func(t *testing.T) *models.Message {
+				return revokedMessage(t, signedMessage(t, login, signer), removed)
+			},
+			keys:       []ssh.PublicKey{signer.PublicKey()},
+			revocation: true,
+			fails:      true,
+			unverified: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &mirror{tsaRoots: test.roots}
+			err := m.check(context.Background(), test.message(t), verify.Snapshot{login: test.keys}, test.revocation)
+
+			if (err != nil) != test.fails {
+				t.Fatalf("error is %v, want failure %v", err, test.fails)
+			}
+			var unverifiedErr *unverifiedError
+			if errors.As(err, &unverifiedErr) != test.unverified {
+				t.Errorf("error %v is quarantined %v, want %v", err, !test.unverified, test.unverified)
+			}
+		})
+	}
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func testSignature(t *testing.T, signer ssh.Signer, data []byte) *models.Signature {
+	sig, err := signer.Sign(rand.Reader, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := base64.StdEncoding.EncodeToString(sig.Blob)
+	return &models.Signature{Format: &sig.Format, Blob: &blob, Key: &models.PublicKey{PublicKey: signer.PublicKey()}}
+}
+
+func signedMessage(t *testing.T, login string, signer ssh.Signer) *models.Message {
+	id, title := 1, "release"
+	blob := base64.StdEncoding.EncodeToString([]byte("v1.0.0"))
+	createdAt := time.Now().Add(-time.Hour)
+	message := &models.Message{ID: &id, GithubLogin: &login, Title: &title, Blob: &blob, CreatedAt: &createdAt}
+
+	raw, _ := base64.StdEncoding.DecodeString(blob)
+	message.RawBlob = raw
+	message.Signatures = []*models.Signature{testSignature(t, signer, message.SignedData())}
+	return message
+}
+
+func revokedMessage(t *testing.T, message *models.Message, signer ssh.Signer) *models.Message {
+	reason, digest := "compromised", message.RevocationDigest()
+	createdAt := time.Now()
+	rev := &models.Revocation{MessageID: message.ID, GithubLogin: message.GithubLogin, Reason: &reason, MessageDigest: &digest, CreatedAt: &createdAt}
+	rev.Signatures = []*models.Signature{testSignature(t, signer, models.RevocationData(*message.ID, digest, reason))}
+
+	message.Revoked, message.Revocation = true, rev
+	return message
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/andrewhamon/signist/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type validator interface {
+	Validate() models.Errors
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Write the error envelope shared by every endpoint
+func writeError(w http.ResponseWriter, req *http.Request, status int, code string, message string, details models.Errors) {
+	writeJSON(w, status, models.ErrorEnvelope{Error: models.APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: req.Header.Get("X-Request-Id"),
+		Details:   details,
+	}})
+}
+
+// Log an unexpected error and write a 500 that does not leak its details
+func writeInternalError(w http.ResponseWriter, req *http.Request, err error) {
+	log.Printf("%s %s\n", req.Header.Get("X-Request-Id"), err.Error())
+	writeError(w, req, http.StatusInternalServerError, models.CodeInternal, "Internal server error", nil)
+}
+
+// Write a 422 for failed validations. A lone error is promoted to the top
+// level code so clients can match on it directly.
+func writeValidationErrors(w http.ResponseWriter, req *http.Request, errs models.Errors) {
+	code, message := models.CodeValidationFailed, "The request failed validation"
+	if len(errs) == 1 {
+		code, message = errs[0].Code, errs[0].Message
+	}
+	writeError(w, req, http.StatusUnprocessableEntity, code, message, errs)
+}
+
+// Decode a JSON request body into v and validate it. On failure an error
+// response has already been written and false is returned.
+func bind(w http.ResponseWriter, req *http.Request, v validator) bool {
+	if !strings.Contains(req.Header.Get("Content-Type"), "json") {
+		writeError(w, req, http.StatusUnsupportedMediaType, models.CodeUnsupportedContentType, "Content-Type must be application/json", nil)
+		return false
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, req, http.StatusRequestEntityTooLarge, models.CodeBodyTooLarge, "Body can not be greater than 1MB", nil)
+		} else {
+			writeError(w, req, http.StatusBadRequest, models.CodeInvalidJSON, err.Error(), nil)
+		}
+		return false
+	}
+
+	if errs := v.Validate(); len(errs) > 0 {
+		writeValidationErrors(w, req, errs)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"github.com/andrewhamon/signist/models"
+	"net/http"
+	"strings"
+)
+
+type paramsKey struct{}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.HandlerFunc
+}
+
+// A small method and path router. Pattern segments beginning with ":" match
+// any single path segment, and a final segment beginning with "*" matches the
+// rest of the path, one or more segments. Handlers read them back with
+// pathParam. Routes are tried in the order they were added.
+type router struct {
+	routes []route
+}
+
+func (rt *router) handle(method string, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{method: method, segments: splitPath(pattern), handler: handler})
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	segments := splitPath(req.URL.Path)
+	allowed := []string{}
+
+	for _, r := range rt.routes {
+		params, ok := r.match(segments)
+		if !ok {
+			continue
+		}
+
+		if r.method != req.Method {
+			allowed = append(allowed, r.method)
+			continue
+		}
+
+		r.handler(w, req.WithContext(context.WithValue(req.Context(), paramsKey{}, params)))
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, req, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Method not allowed", nil)
+		return
+	}
+
+	writeError(w, req, http.StatusNotFound, models.CodeNotFound, "Not found", nil)
+}
+
+func (r route) match(segments []string) (map[string]string, bool) {
+	rest := len(r.segments) > 0 && strings.HasPrefix(r.segments[len(r.segments)-1], "*")
+	if len(segments) < len(r.segments) || (!rest && len(segments) != len(r.segments)) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "*") {
+			params[segment[1:]] = strings.Join(segments[i:], "/")
+		} else if strings.HasPrefix(segment, ":") {
+			params[segment[1:]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Return the value of a ":name" or "*name" segment in the matched route
+func pathParam(req *http.Request, name string) string {
+	params, _ := req.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
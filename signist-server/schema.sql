@@ -0,0 +1,181 @@
+CREATE TABLE messages (
+  id serial PRIMARY KEY,
+  -- The SignedData encoding the signatures cover. Messages from before it
+  -- was versioned are 1.
+  version integer NOT NULL DEFAULT 1,
+  github_id integer NOT NULL,
+  github_login text,
+  title text NOT NULL,
+  blob text NOT NULL,
+  content_type text,
+  metadata jsonb,
+  not_before timestamp with time zone,
+  expires_at timestamp with time zone,
+  -- base64 DER RFC 3161 token, when the server has a timestamp authority
+  timestamp_token text,
+  created_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX messages_github_id_idx ON messages (github_id);
+CREATE INDEX messages_metadata_idx ON messages USING gin (metadata);
+
+CREATE TABLE signatures (
+  id serial PRIMARY KEY,
+  message_id integer NOT NULL REFERENCES messages (id),
+  format text NOT NULL,
+  blob text NOT NULL,
+  key text NOT NULL,
+  certificate text,
+  created_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX signatures_message_id_idx ON signatures (message_id);
+
+-- A message is revoked at most once, and is never deleted.
+CREATE TABLE revocations (
+  id serial PRIMARY KEY,
+  message_id integer NOT NULL UNIQUE REFERENCES messages (id),
+  -- The RevocationDigest of the message the signatures name it by. Null for
+  -- revocations made before revocations were bound to the message's content.
+  message_sha256 text,
+  reason text NOT NULL,
+  created_at timestamp with time zone NOT NULL
+);
+
+CREATE TABLE revocation_signatures (
+  id serial PRIMARY KEY,
+  revocation_id integer NOT NULL REFERENCES revocations (id),
+  format text NOT NULL,
+  blob text NOT NULL,
+  key text NOT NULL,
+  certificate text,
+  created_at timestamp with time zone NOT NULL
+);
+
+-- Caps how long messages signed by an identity may be valid for. Identities
+-- without a row fall back to SIGNIST_MAX_VALIDITY.
+CREATE TABLE validity_policies (
+  github_id integer PRIMARY KEY,
+  max_validity_seconds integer NOT NULL
+);
+
+-- How many distinct keys must sign an identity's revocations. Identities
+-- without a row fall back to SIGNIST_REVOCATION_THRESHOLD.
+CREATE TABLE revocation_policies (
+  github_id integer PRIMARY KEY,
+  min_signatures integer NOT NULL CHECK (min_signatures > 0)
+);
+
+-- OpenSSH certificate authorities an identity trusts to certify its signing
+-- keys, in authorized_keys format. Signatures from certified keys are accepted
+-- when the certificate names the identity's login as a principal. Managed
+-- through signed requests to /:github_id/certificate_authorities; rows added
+-- by hand before then have no login or creation time.
+CREATE TABLE certificate_authorities (
+  id serial PRIMARY KEY,
+  github_id integer NOT NULL,
+  github_login text,
+  key text NOT NULL,
+  created_at timestamp with time zone,
+  UNIQUE (github_id, key)
+);
+
+-- The subject digests of in-toto statements in DSSE messages, for looking up
+-- the attestations about an artifact.
+CREATE TABLE message_subjects (
+  id serial PRIMARY KEY,
+  message_id integer NOT NULL REFERENCES messages (id),
+  name text NOT NULL,
+  algorithm text NOT NULL,
+  digest text NOT NULL
+);
+
+CREATE INDEX message_subjects_digest_idx ON message_subjects (algorithm, digest);
+CREATE INDEX message_subjects_message_id_idx ON message_subjects (message_id);
+
+-- The append-only log of messages and revocations, served through the Rekor
+-- API. Each entry's uuid is the hex leaf hash of its body, and log_index is
+-- its position in the Merkle tree. Rows are never updated or deleted.
+CREATE TABLE log_entries (
+  log_index integer PRIMARY KEY,
+  uuid text NOT NULL UNIQUE,
+  message_id integer REFERENCES messages (id),
+  revocation_id integer REFERENCES revocations (id),
+  blob_sha256 text,
+  body text NOT NULL,
+  integrated_time timestamp with time zone NOT NULL
+);
+
+CREATE INDEX log_entries_blob_sha256_idx ON log_entries (blob_sha256);
+CREATE INDEX log_entries_message_id_idx ON log_entries (message_id);
+
+-- The hashes of the log's perfect subtrees, stored as entries are appended
+-- so that roots and proofs are made from O(log n) nodes rather than every
+-- leaf. The node at level l and node_index i covers the 2^l entries from
+-- i * 2^l; level 0 holds the leaf hashes, which are the entries' uuids.
+-- Entries logged before this table existed get their nodes from
+-- `signist-server backfill`.
+CREATE TABLE log_tree_nodes (
+  level integer NOT NULL,
+  node_index integer NOT NULL,
+  hash text NOT NULL,
+  PRIMARY KEY (level, node_index)
+);
+
+-- Subscriptions to the events of an identity's messages whose titles start
+-- with title_prefix. Removed webhooks are kept, with deleted_at set, so that
+-- their delivery logs remain.
+CREATE TABLE webhooks (
+  id serial PRIMARY KEY,
+  github_id integer NOT NULL,
+  github_login text NOT NULL,
+  url text NOT NULL,
+  title_prefix text NOT NULL,
+  secret text NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  deleted_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX webhooks_subscription_idx ON webhooks (github_id, url, title_prefix) WHERE deleted_at IS NULL;
+
+-- The delivery queue and log. Deliveries are queued in the transaction that
+-- commits their event, and retried with exponential backoff while pending.
+CREATE TABLE webhook_deliveries (
+  id serial PRIMARY KEY,
+  webhook_id integer NOT NULL REFERENCES webhooks (id),
+  event text NOT NULL,
+  payload text NOT NULL,
+  status text NOT NULL,
+  attempts integer NOT NULL,
+  response_status integer,
+  last_error text,
+  next_attempt_at timestamp with time zone NOT NULL,
+  last_attempt_at timestamp with time zone,
+  delivered_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+
+-- The upstream a mirror follows, the key its checkpoints must be signed with,
+-- and the size and root hash of the log mirrored so far. Each new upstream
+-- checkpoint must be consistent with them.
+CREATE TABLE mirror_state (
+  upstream text PRIMARY KEY,
+  public_key text NOT NULL,
+  tree_size integer NOT NULL,
+  root_hash text NOT NULL,
+  checkpoint text,
+  updated_at timestamp with time zone NOT NULL
+);
+
+-- Upstream entries a mirror logged without being able to verify, such as
+-- messages signed with keys since removed from GitHub, and why. Their
+-- messages and revocations are not stored.
+CREATE TABLE mirror_quarantine (
+  log_index integer PRIMARY KEY REFERENCES log_entries (log_index),
+  uuid text NOT NULL,
+  reason text NOT NULL,
+  quarantined_at timestamp with time zone NOT NULL
+);
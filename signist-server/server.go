@@ -1,24 +1,47 @@
 package main
 
 import (
-	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/go-martini/martini"
+	"context"
+	_ "embed"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/jmoiron/sqlx"
 	_ "github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/lib/pq"
-	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/martini-contrib/binding"
-	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/martini-contrib/render"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
 	"github.com/andrewhamon/signist/models"
-	"github.com/andrewhamon/signist/utils"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
 type Message models.Message
 type Signature models.Signature
 
-type jsonError struct {
-	Error string
+// Requests with larger bodies are rejected
+const maxBodyBytes = 1 << 20
+
+// How long in flight requests have to finish after SIGTERM
+const shutdownTimeout = 30 * time.Second
+
+// The OpenAPI 3 description of every route, served at /openapi.json
+//
+//go:embed openapi.json
+var openAPI []byte
+
+type server struct {
+	db               *sqlx.DB
+	defaultMax       time.Duration
+	defaultThreshold int
+	dailyQuota       int
+	log              *transparencyLog
+	tsa              *timestamper
+	stream           *logStream
+
+	// Set when the server is a read-only mirror of another
+	mirror *mirror
 }
 
 func databaseString() string {
@@ -30,76 +53,193 @@ func databaseString() string {
 	return "user=signist dbname=signist sslmode=disable"
 }
 
-func main() {
-	db, err := sqlx.Connect("postgres", databaseString())
+// Listen on HOST:PORT, or port 3000 on all interfaces by default
+func listenAddr() string {
+	port := os.Getenv("PORT")
+	if len(port) == 0 {
+		port = "3000"
+	}
+
+	return os.Getenv("HOST") + ":" + port
+}
+
+// The longest a message may be valid for when its identity has no policy of
+// its own. Zero means there is no cap.
+func defaultMaxValidity() time.Duration {
+	str := os.Getenv("SIGNIST_MAX_VALIDITY")
+	if len(str) == 0 {
+		return 0
+	}
+
+	max, err := time.ParseDuration(str)
 	if err != nil {
-		log.Fatalln(err)
+		log.Fatalf("Could not parse SIGNIST_MAX_VALIDITY %q: %s\n", str, err.Error())
+	}
+
+	return max
+}
+
+// The number of distinct keys a revocation must be signed by when its identity
+// has no policy of its own, from SIGNIST_REVOCATION_THRESHOLD. Defaults to one.
+func defaultRevocationThreshold() int {
+	str := os.Getenv("SIGNIST_REVOCATION_THRESHOLD")
+	if len(str) == 0 {
+		return 1
 	}
 
-	m := martini.Classic()
-	m.Use(render.Renderer())
+	threshold, err := strconv.Atoi(str)
+	if err != nil || threshold < 1 {
+		log.Fatalf("SIGNIST_REVOCATION_THRESHOLD must be a positive number, got %q\n", str)
+	}
 
-	m.Use(func(req *http.Request, r render.Render) {
-		if req.ContentLength > (1 << 20) {
-			r.JSON(http.StatusRequestEntityTooLarge, jsonError{Error: "Body can not be greater than 1MB"})
-		}
-	})
+	return threshold
+}
+
+// The most messages an identity may store per day, from SIGNIST_DAILY_QUOTA.
+// Zero means there is no quota.
+func dailyQuota() int {
+	str := os.Getenv("SIGNIST_DAILY_QUOTA")
+	if len(str) == 0 {
+		return 0
+	}
 
-	m.Get("/:github_id", func(params martini.Params, r render.Render) {
-		messages := []*models.Message{}
+	quota, err := strconv.Atoi(str)
+	if err != nil || quota < 0 {
+		log.Fatalf("SIGNIST_DAILY_QUOTA must be a non-negative number, got %q\n", str)
+	}
 
-		err := db.Select(&messages, "SELECT * FROM messages WHERE github_id = $1", params["github_id"])
+	return quota
+}
 
-		if err != nil {
-			log.Println(err)
-			r.JSON(http.StatusInternalServerError, err)
-			return
-		}
+// The default algorithm policy, with the signature formats, key types and
+// minimum RSA key size overridden by SIGNIST_SIGNATURE_FORMATS,
+// SIGNIST_KEY_TYPES and SIGNIST_MIN_RSA_BITS. Lists are comma separated.
+func algorithmPolicy() models.AlgorithmPolicy {
+	policy := models.DefaultAlgorithmPolicy
 
-		for _, m := range messages {
-			m.Signatures = []*models.Signature{}
-			err := db.Select(&m.Signatures, "SELECT * FROM signatures WHERE message_id = $1", m.ID)
-			if err != nil {
-				log.Println(err)
-				r.JSON(http.StatusInternalServerError, err)
-				return
-			}
-		}
-		r.JSON(http.StatusOK, messages)
-	})
-
-	m.Post("/", binding.Bind(models.Message{}), func(message models.Message, params martini.Params, r render.Render) {
-		tx := db.MustBegin()
-		err := tx.QueryRowx(`INSERT INTO messages (github_id, title, blob, created_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at`, message.GithubID, message.Title, message.Blob, time.Now()).StructScan(&message)
-
-		if err != nil {
-			tx.Rollback()
-			log.Println(err)
-			r.JSON(http.StatusBadRequest, err)
-			return
+	if str := os.Getenv("SIGNIST_SIGNATURE_FORMATS"); len(str) > 0 {
+		policy.SignatureFormats = strings.Split(str, ",")
+	}
+
+	if str := os.Getenv("SIGNIST_KEY_TYPES"); len(str) > 0 {
+		policy.KeyTypes = strings.Split(str, ",")
+	}
+
+	if str := os.Getenv("SIGNIST_MIN_RSA_BITS"); len(str) > 0 {
+		bits, err := strconv.Atoi(str)
+		if err != nil || bits < 0 {
+			log.Fatalf("Could not parse SIGNIST_MIN_RSA_BITS %q\n", str)
 		}
+		policy.MinRSABits = bits
+	}
+
+	return policy
+}
+
+func (s *server) routes() http.Handler {
+	rt := &router{}
+	rt.handle("GET", "/openapi.json", s.getOpenAPI)
+	rt.handle("GET", "/stream", s.getStream)
+	rt.handle("GET", "/messages/:id", s.getMessage)
+	rt.handle("GET", "/messages/:id/envelope", s.getEnvelope)
+	rt.handle("GET", "/messages/:id/blob", s.getBlob)
+	rt.handle("GET", "/subjects/:algorithm/:digest", s.getSubjectMessages)
+	rt.handle("GET", "/:github_id", s.getMessages)
+	rt.handle("GET", "/users/:login/messages", s.getUserMessages)
+	rt.handle("GET", "/users/:feed", s.getUserFeed)
+	rt.handle("GET", "/users/:login/messages/*title", s.getTitleFeed)
+	rt.handle("POST", "/", s.writable(s.postMessage))
+	rt.handle("DELETE", "/:github_id/:message_id", s.writable(s.deleteMessage))
+	rt.handle("POST", "/:github_id/webhooks", s.writable(s.postWebhook))
+	rt.handle("POST", "/:github_id/webhooks/list", s.postWebhookList)
+	rt.handle("DELETE", "/:github_id/webhooks/:webhook_id", s.writable(s.deleteWebhook))
+	rt.handle("POST", "/:github_id/webhooks/:webhook_id/deliveries", s.postWebhookDeliveries)
+	rt.handle("GET", "/:github_id/certificate_authorities", s.getCertificateAuthorities)
+	rt.handle("POST", "/:github_id/certificate_authorities", s.writable(s.postCertificateAuthority))
+	rt.handle("DELETE", "/:github_id/certificate_authorities/:authority_id", s.writable(s.deleteCertificateAuthority))
+
+	rt.handle("GET", "/api/v1/log", s.getLogInfo)
+	rt.handle("GET", "/api/v1/log/publicKey", s.getLogPublicKey)
+	rt.handle("GET", "/api/v1/log/proof", s.getLogProof)
+	rt.handle("POST", "/api/v1/log/entries", s.writable(s.postLogEntry))
+	rt.handle("GET", "/api/v1/log/entries", s.getLogEntries)
+	rt.handle("GET", "/api/v1/log/entries/:uuid", s.getLogEntry)
+	rt.handle("POST", "/api/v1/index/retrieve", s.searchLogIndex)
+	rt.handle("GET", "/api/v1/mirror", s.getMirrorStatus)
+
+	return withRequestID(withLogging(withRecovery(withBodyLimit(rt, maxBodyBytes))))
+}
 
-		for _, sig := range message.Signatures {
-			err := tx.QueryRowx(`INSERT INTO signatures (message_id, format, blob, key, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, message_id, created_at`, message.ID, sig.Format, sig.Blob, utils.PubKeyToString(*sig.Key), time.Now()).StructScan(sig)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "tsa" {
+		runTimestampAuthority(os.Args[2:])
+		return
+	}
 
-			if err != nil {
-				tx.Rollback()
-				log.Println(err)
-				r.JSON(http.StatusBadRequest, err)
-				return
-			}
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill()
+		return
+	}
 
+	var m *mirror
+	if len(os.Args) > 1 && os.Args[1] == "mirror" {
+		m = parseMirrorFlags(os.Args[2:])
+	}
+
+	db, err := sqlx.Connect("postgres", databaseString())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	s := &server{db: db, defaultMax: defaultMaxValidity(), defaultThreshold: defaultRevocationThreshold(), dailyQuota: dailyQuota(), log: loadTransparencyLog(), tsa: loadTimestamper(), mirror: m}
+	if err := checkBackfilled(db, m != nil); err != nil {
+		log.Fatalf("The log is incomplete: %s\n", err.Error())
+	}
+	if m != nil {
+		if err := m.init(db); err != nil {
+			log.Fatalf("Could not start mirroring %s: %s\n", m.url, err.Error())
 		}
+	}
+	models.Algorithms = algorithmPolicy()
+
+	models.CertificateAuthoritiesFor = func(githubID int) ([]ssh.PublicKey, error) {
+		return certificateAuthorities(db, githubID)
+	}
 
-		if err := tx.Commit(); err != nil {
-			tx.Rollback()
-			r.JSON(http.StatusBadRequest, err)
-			return
+	srv := &http.Server{
+		Addr:              listenAddr(),
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if m != nil {
+		go m.follow(ctx, db)
+	} else {
+		go deliverWebhooks(ctx, db)
+	}
+	s.stream = listenForLogEntries(ctx, databaseString())
+
+	go func() {
+		log.Printf("Listening on %s\n", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalln(err)
 		}
+	}()
 
-		r.JSON(http.StatusOK, message)
+	<-ctx.Done()
+	log.Println("Shutting down")
 
-	})
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	m.Run()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down: %s\n", err.Error())
+	}
+	db.Close()
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/lib/pq"
+	"github.com/andrewhamon/signist/models"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The channel log entries are announced on when they are committed. The
+// payload is the entry's log index.
+const logChannel = "signist_log"
+
+// Entries sent to a stream per query
+const streamBatch = 100
+
+// How often an idle stream is sent a comment, so proxies keep it open
+const streamKeepAlive = 30 * time.Second
+
+// How long a stream has to accept each write before it is dropped
+const streamWriteTimeout = 30 * time.Second
+
+// Wakes every open stream when another entry is committed to the log, by any
+// server sharing the database
+type logStream struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]bool
+	done        chan struct{}
+}
+
+// LISTEN for log entries until ctx is done, then end every stream
+func listenForLogEntries(ctx context.Context, dsn string) *logStream {
+	ls := &logStream{subscribers: map[chan struct{}]bool{}, done: make(chan struct{})}
+
+	listener := pq.NewListener(dsn, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Log stream listener: %s\n", err.Error())
+		}
+	})
+
+	go func() {
+		defer close(ls.done)
+		defer listener.Close()
+
+		if err := listener.Listen(logChannel); err != nil {
+			log.Printf("Could not listen for log entries: %s\n", err.Error())
+			return
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			// A nil notification means the connection was re-established and
+			// notifications may have been missed. Streams query for what they
+			// have not sent either way.
+			case <-listener.Notify:
+				ls.wake()
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+				ls.wake()
+			}
+		}
+	}()
+
+	return ls
+}
+
+func (ls *logStream) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	ls.mu.Lock()
+	ls.subscribers[ch] = true
+	ls.mu.Unlock()
+	return ch
+}
+
+func (ls *logStream) unsubscribe(ch chan struct{}) {
+	ls.mu.Lock()
+	delete(ls.subscribers, ch)
+	ls.mu.Unlock()
+}
+
+// Signal every subscriber without blocking. A subscriber that has not yet
+// handled the last signal already has one pending.
+func (ls *logStream) wake() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for ch := range ls.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// GET /stream sends log entries as server-sent events, each with its log
+// index as its id, starting after Last-Event-ID, at the from query parameter,
+// or with the next entry committed
+func (s *server) getStream(w http.ResponseWriter, req *http.Request) {
+	next := -1
+	if str := req.Header.Get("Last-Event-ID"); len(str) > 0 {
+		last, err := strconv.Atoi(str)
+		if err != nil || last < 0 {
+			writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Last-Event-ID must be a log index", nil)
+			return
+		}
+		next = last + 1
+	} else if str := req.URL.Query().Get("from"); len(str) > 0 {
+		from, err := strconv.Atoi(str)
+		if err != nil || from < 0 {
+			writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "from must be a non-negative number", nil)
+			return
+		}
+		next = from
+	}
+
+	// Subscribe before looking at the log, so that an entry committed in
+	// between still wakes the stream
+	wake := s.stream.subscribe()
+	defer s.stream.unsubscribe(wake)
+
+	if next < 0 {
+		if err := s.db.Get(&next, "SELECT COALESCE(MAX(log_index) + 1, 0) FROM log_entries"); err != nil {
+			writeInternalError(w, req, err)
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		rows := []logRow{}
+		err := s.db.Select(&rows, "SELECT log_index, uuid, body, integrated_time FROM log_entries WHERE log_index >= $1 ORDER BY log_index LIMIT $2", next, streamBatch)
+		if err != nil {
+			log.Printf("%s stream: %s\n", req.Header.Get("X-Request-Id"), err.Error())
+			return
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		for _, row := range rows {
+			if err := writeLogEvent(w, row); err != nil {
+				return
+			}
+			next = row.LogIndex + 1
+		}
+		if len(rows) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+		if len(rows) == streamBatch {
+			continue
+		}
+
+		select {
+		case <-wake:
+		case <-time.After(streamKeepAlive):
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-req.Context().Done():
+			return
+		case <-s.stream.done:
+			return
+		}
+	}
+}
+
+// Write a row as an event of type message or revocation
+func writeLogEvent(w http.ResponseWriter, row logRow) error {
+	event := models.LogEvent{
+		UUID:           row.UUID,
+		LogIndex:       row.LogIndex,
+		IntegratedTime: row.IntegratedTime.Unix(),
+		Body:           base64.StdEncoding.EncodeToString([]byte(row.Body)),
+	}
+
+	body := models.LogEntryBody{}
+	if err := json.Unmarshal([]byte(row.Body), &body); err != nil {
+		return err
+	}
+	event.Spec = body.Spec
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	kind := "message"
+	if body.Spec.Revocation != nil {
+		kind = "revocation"
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", row.LogIndex, kind, data)
+	return err
+}
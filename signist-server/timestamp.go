@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"flag"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/timestamp"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// How long a timestamp authority has to answer before a message is refused
+const timestampTimeout = 10 * time.Second
+
+// The RFC 3161 timestamp authority new messages are stamped by
+type timestamper struct {
+	url string
+
+	// Roots tokens are checked against before they are stored
+	roots *x509.CertPool
+}
+
+// The timestamp authority at SIGNIST_TSA_URL, trusting the PEM certificates
+// in SIGNIST_TSA_CERT, or nil if messages are not timestamped. Tokens are
+// never stored unchecked, so the certificates are required.
+func loadTimestamper() *timestamper {
+	url := os.Getenv("SIGNIST_TSA_URL")
+	if len(url) == 0 {
+		return nil
+	}
+
+	path := os.Getenv("SIGNIST_TSA_CERT")
+	if len(path) == 0 {
+		log.Fatalln("SIGNIST_TSA_CERT must name the timestamp authority's certificates when SIGNIST_TSA_URL is set")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Could not read SIGNIST_TSA_CERT: %s\n", err.Error())
+	}
+	roots, err := timestamp.ParseRoots(data)
+	if err != nil {
+		log.Fatalf("Could not parse SIGNIST_TSA_CERT: %s\n", err.Error())
+	}
+	return &timestamper{url: url, roots: roots}
+}
+
+// Request a token over the message's timestamp digest and set it on the
+// message
+func (t *timestamper) stamp(ctx context.Context, message *models.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, timestampTimeout)
+	defer cancel()
+
+	digest := message.TimestampDigest()
+	der, err := timestamp.Fetch(ctx, t.url, digest)
+	if err != nil {
+		return err
+	}
+
+	token, err := timestamp.Parse(der)
+	if err != nil {
+		return err
+	}
+	if _, err := token.Verify(digest, t.roots); err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(der)
+	message.TimestampToken = &encoded
+	return nil
+}
+
+// Run a stand-in timestamp authority with a fresh key, for development and
+// testing. Its root certificate is written where SIGNIST_TSA_CERT can load
+// it.
+func runTimestampAuthority(args []string) {
+	flags := flag.NewFlagSet("tsa", flag.ExitOnError)
+	addr := flags.String("listen", "127.0.0.1:3161", "address to listen on")
+	rootPath := flags.String("root", "tsa-root.pem", "file to write the root certificate to")
+	flags.Parse(args)
+
+	authority, err := timestamp.NewAuthority()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	root := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: authority.Root.Raw})
+	if err := ioutil.WriteFile(*rootPath, root, 0644); err != nil {
+		log.Fatalf("Could not write the root certificate: %s\n", err.Error())
+	}
+
+	log.Printf("Stand-in timestamp authority listening on %s, root certificate in %s\n", *addr, *rootPath)
+	srv := &http.Server{Addr: *addr, Handler: authority, ReadHeaderTimeout: 10 * time.Second}
+	log.Fatalln(srv.ListenAndServe())
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/github.com/jmoiron/sqlx"
+	"github.com/andrewhamon/signist/models"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// How often the delivery queue is checked for due deliveries
+	webhookPollInterval = 5 * time.Second
+
+	// Deliveries claimed at once, and how long a claim lasts before another
+	// server may retry the delivery
+	webhookBatchSize = 10
+	webhookLease     = 2 * time.Minute
+
+	// A delivery is tried this many times, waiting webhookBackoff and then
+	// twice as long as the last wait before each retry, up to
+	// webhookMaxBackoff
+	webhookMaxAttempts = 10
+	webhookBackoff     = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+
+	webhookTimeout = 10 * time.Second
+)
+
+// Webhook receivers are not followed through redirects, nor reached
+// through a proxy, and only public addresses are dialled. The address is
+// checked once the host is resolved, so a webhook registered with a public
+// address cannot later be pointed at an internal one.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: dialPublicOnly,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Refuse to connect to an address webhooks may not be delivered to
+func dialPublicOnly(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !models.PublicAddress(ip) {
+		return fmt.Errorf("refusing to deliver to internal address %s", host)
+	}
+	return nil
+}
+
+func (s *server) postWebhook(w http.ResponseWriter, req *http.Request) {
+	hook := models.Webhook{}
+	if !bind(w, req, &hook) {
+		return
+	}
+
+	if pathParam(req, "github_id") != strconv.Itoa(*hook.GithubID) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Webhook does not belong to the requested identity", nil)
+		return
+	}
+
+	secret, err := webhookSecret()
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+	hook.Secret = &secret
+
+	err = s.db.QueryRowx(`INSERT INTO webhooks (github_id, github_login, url, title_prefix, secret, created_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (github_id, url, title_prefix) WHERE deleted_at IS NULL DO NOTHING RETURNING id, created_at`, hook.GithubID, hook.GithubLogin, hook.URL, hook.TitlePrefix, hook.Secret, time.Now()).StructScan(&hook)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusConflict, models.CodeWebhookExists, "A webhook for this URL and title prefix is already registered", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// An identity's webhooks. Receiver URLs often embed credentials, so the
+// request must be signed by the identity.
+func (s *server) postWebhookList(w http.ResponseWriter, req *http.Request) {
+	lr := models.WebhookListRequest{}
+	if !bind(w, req, &lr) {
+		return
+	}
+
+	if pathParam(req, "github_id") != strconv.Itoa(*lr.GithubID) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Request does not match the requested github_id", nil)
+		return
+	}
+
+	hooks := []*models.Webhook{}
+	err := s.db.Select(&hooks, "SELECT id, github_id, github_login, url, title_prefix, created_at FROM webhooks WHERE github_id = $1 AND deleted_at IS NULL ORDER BY id", *lr.GithubID)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+func (s *server) deleteWebhook(w http.ResponseWriter, req *http.Request) {
+	del := models.WebhookDeletion{}
+	if !bind(w, req, &del) {
+		return
+	}
+
+	if pathParam(req, "webhook_id") != strconv.Itoa(*del.WebhookID) || pathParam(req, "github_id") != strconv.Itoa(*del.GithubID) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Deletion does not match the requested webhook", nil)
+		return
+	}
+
+	hook := models.Webhook{}
+	tx := s.db.MustBegin()
+	err := tx.QueryRowx(`UPDATE webhooks SET deleted_at = $1 WHERE id = $2 AND github_id = $3 AND deleted_at IS NULL RETURNING id, github_id, github_login, url, title_prefix, created_at`, time.Now(), del.WebhookID, del.GithubID).StructScan(&hook)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		writeError(w, req, http.StatusNotFound, models.CodeWebhookNotFound, "Webhook not found", nil)
+		return
+	} else if err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if _, err := tx.Exec(`UPDATE webhook_deliveries SET status = $1 WHERE webhook_id = $2 AND status = $3`, models.DeliveryCancelled, hook.ID, models.DeliveryPending); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		writeInternalError(w, req, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// The most recent deliveries to a webhook, newest first. They include what
+// the receiver responded, so the request must be signed by the webhook's
+// identity.
+func (s *server) postWebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+	dr := models.WebhookDeliveriesRequest{}
+	if !bind(w, req, &dr) {
+		return
+	}
+
+	if pathParam(req, "webhook_id") != strconv.Itoa(*dr.WebhookID) || pathParam(req, "github_id") != strconv.Itoa(*dr.GithubID) {
+		writeError(w, req, http.StatusBadRequest, models.CodeInvalidInput, "Request does not match the requested webhook", nil)
+		return
+	}
+
+	var id int
+	err := s.db.Get(&id, "SELECT id FROM webhooks WHERE id = $1 AND github_id = $2", *dr.WebhookID, *dr.GithubID)
+	if err == sql.ErrNoRows {
+		writeError(w, req, http.StatusNotFound, models.CodeWebhookNotFound, "Webhook not found", nil)
+		return
+	} else if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	deliveries := []*models.WebhookDelivery{}
+	err = s.db.Select(&deliveries, "SELECT id, webhook_id, event, payload, status, attempts, response_status, last_error, next_attempt_at, last_attempt_at, delivered_at, created_at FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT 100", id)
+	if err != nil {
+		writeInternalError(w, req, err)
+		return
+	}
+
+	for _, d := range deliveries {
+		if d.Status != models.DeliveryPending {
+			d.NextAttemptAt = nil
+		}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// A random hex key for signing deliveries
+func webhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Queue an event for every webhook of githubID whose prefix matches title,
+// in the transaction that records the event so that only committed events
+// are delivered
+func enqueueWebhooks(tx *sqlx.Tx, githubID int, title string, payload models.WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt_at, created_at) SELECT id, $1, $2, $3, 0, $4, $4 FROM webhooks WHERE github_id = $5 AND deleted_at IS NULL AND left($6, char_length(title_prefix)) = title_prefix`, payload.Event, string(body), models.DeliveryPending, now, githubID, title)
+	return err
+}
+
+// A claimed delivery, with where and how to send it
+type pendingDelivery struct {
+	ID       int    `db:"id"`
+	Event    string `db:"event"`
+	Payload  string `db:"payload"`
+	Attempts int    `db:"attempts"`
+	URL      string `db:"url"`
+	Secret   string `db:"secret"`
+}
+
+// Send due deliveries until ctx is done. Deliveries are claimed with a
+// lease, so any number of servers can share the queue, and a delivery whose
+// server stops before recording it is sent again: receivers may see an
+// event more than once.
+func deliverWebhooks(ctx context.Context, db *sqlx.DB) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := deliverBatch(ctx, db)
+			if err != nil {
+				log.Printf("Error delivering webhooks: %s\n", err.Error())
+			}
+			if err != nil || n < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Claim and send one batch of due deliveries, returning how many there were
+func deliverBatch(ctx context.Context, db *sqlx.DB) (int, error) {
+	now := time.Now()
+	deliveries := []pendingDelivery{}
+	err := db.Select(&deliveries, `UPDATE webhook_deliveries d SET next_attempt_at = $1 FROM webhooks w WHERE d.webhook_id = w.id AND w.deleted_at IS NULL AND d.id IN (SELECT id FROM webhook_deliveries WHERE status = $2 AND next_attempt_at <= $3 ORDER BY next_attempt_at LIMIT $4 FOR UPDATE SKIP LOCKED) RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret`, now.Add(webhookLease), models.DeliveryPending, now, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	wg := sync.WaitGroup{}
+	for _, d := range deliveries {
+		d := d
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := sendWebhook(ctx, d)
+			if ctx.Err() != nil {
+				// Shutting down, the delivery is retried when its lease ends
+				return
+			}
+			if err := recordDelivery(db, d, status, err); err != nil {
+				log.Printf("Error recording webhook delivery %d: %s\n", d.ID, err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// POST a delivery, returning the response status. Anything but a 2xx is an
+// error.
+func sendWebhook(ctx context.Context, d pendingDelivery) (int, error) {
+	req, err := http.NewRequest("POST", d.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "signist-webhooks")
+	req.Header.Set("X-Signist-Event", d.Event)
+	req.Header.Set("X-Signist-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Signist-Signature-256", "sha256="+webhookSignature(d.Secret, []byte(d.Payload)))
+
+	res, err := webhookClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// The hex HMAC-SHA256 of a delivery's body, sent in X-Signist-Signature-256
+// so that receivers can check it came from this server
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Record the outcome of an attempt, scheduling a retry if the delivery
+// failed and has attempts left. A delivery cancelled while it was being sent
+// stays cancelled.
+func recordDelivery(db *sqlx.DB, d pendingDelivery, responseStatus int, sendErr error) error {
+	now := time.Now()
+	attempts := d.Attempts + 1
+
+	var status *int
+	if responseStatus > 0 {
+		status = &responseStatus
+	}
+
+	if sendErr == nil {
+		_, err := db.Exec(`UPDATE webhook_deliveries SET status = $1, attempts = $2, response_status = $3, last_error = NULL, last_attempt_at = $4, delivered_at = $4 WHERE id = $5 AND status = $6`, models.DeliveryDelivered, attempts, status, now, d.ID, models.DeliveryPending)
+		return err
+	}
+
+	state := models.DeliveryPending
+	if attempts >= webhookMaxAttempts {
+		state = models.DeliveryFailed
+	}
+
+	_, err := db.Exec(`UPDATE webhook_deliveries SET status = $1, attempts = $2, response_status = $3, last_error = $4, last_attempt_at = $5, next_attempt_at = $6 WHERE id = $7 AND status = $8`, state, attempts, status, sendErr.Error(), now, now.Add(webhookRetryWait(attempts)), d.ID, models.DeliveryPending)
+	return err
+}
+
+// How long to wait before retrying a delivery that has failed attempts times
+func webhookRetryWait(attempts int) time.Duration {
+	wait := webhookBackoff
+	for i := 1; i < attempts && wait < webhookMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > webhookMaxBackoff {
+		wait = webhookMaxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,70 @@
+package sshkeys
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"io"
+)
+
+// Agent protocol message numbers, from PROTOCOL.agent
+const (
+	agentFailure      = 5
+	agentSignRequest  = 13
+	agentSignResponse = 14
+
+	maxAgentResponseBytes = 16 << 20
+)
+
+// Ask the agent on conn to sign data with key. Unlike agent.Client.Sign, this
+// accepts security key signatures, which carry flags and a counter after the
+// signature blob, and asks for rsa-sha2-512 signatures from RSA keys.
+func AgentSign(conn io.ReadWriter, key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	var flags uint32
+	if RSABits(key) > 0 {
+		flags = agentRSASHA512
+	}
+
+	req := ssh.Marshal(struct {
+		Type  byte
+		Key   []byte
+		Data  []byte
+		Flags uint32
+	}{agentSignRequest, key.Marshal(), data, flags})
+
+	msg := make([]byte, 4+len(req))
+	binary.BigEndian.PutUint32(msg, uint32(len(req)))
+	copy(msg[4:], req)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var length [4]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 || n > maxAgentResponseBytes {
+		return nil, errors.New("agent: invalid response length")
+	}
+
+	res := make([]byte, n)
+	if _, err := io.ReadFull(conn, res); err != nil {
+		return nil, err
+	}
+
+	switch res[0] {
+	case agentSignResponse:
+		var w struct {
+			Signature []byte
+		}
+		if err := ssh.Unmarshal(res[1:], &w); err != nil {
+			return nil, err
+		}
+		return ParseSignature(w.Signature)
+	case agentFailure:
+		return nil, errors.New("agent: failed to sign challenge")
+	}
+	return nil, errors.New("agent: unexpected response to sign request")
+}
@@ -0,0 +1,151 @@
+package sshkeys
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+)
+
+// Parse a key in wire format, like ssh.ParsePublicKey
+func ParsePublicKey(in []byte) (ssh.PublicKey, error) {
+	var w struct {
+		Type string
+		Rest []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(in, &w); err != nil {
+		return nil, err
+	}
+
+	switch w.Type {
+	case KeyAlgoSKED25519:
+		return parseSKEd25519(in)
+	case KeyAlgoSKECDSA256:
+		return parseSKECDSA(in)
+	}
+	return ssh.ParsePublicKey(in)
+}
+
+// Parse the first key in authorized_keys format, like ssh.ParseAuthorizedKey.
+// As there, the line is either "type key comment", or options followed by
+// that, where options may quote values containing commas and spaces.
+func ParseAuthorizedKey(in []byte) (out ssh.PublicKey, comment string, options []string, rest []byte, err error) {
+	line, rest := firstLine(in)
+
+	if out, comment, ok, err := parseSKKey(line); ok {
+		return out, comment, nil, rest, err
+	}
+
+	if options, keyPart, found := splitOptions(line); found {
+		if out, comment, ok, err := parseSKKey(keyPart); ok {
+			return out, comment, options, rest, err
+		}
+	}
+
+	return ssh.ParseAuthorizedKey(in)
+}
+
+// Parse "type key comment" when type is a security key type. ok is false
+// when the line does not start with one.
+func parseSKKey(line []byte) (out ssh.PublicKey, comment string, ok bool, err error) {
+	keyType, line := nextField(line)
+	if !IsSK(string(keyType)) {
+		return nil, "", false, nil
+	}
+
+	encoded, line := nextField(line)
+	if len(encoded) == 0 {
+		return nil, "", true, errors.New("ssh: missing key after " + string(keyType))
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, "", true, err
+	}
+
+	if out, err = ParsePublicKey(blob); err != nil {
+		return nil, "", true, err
+	}
+
+	if out.Type() != string(keyType) {
+		return nil, "", true, errors.New("ssh: key type does not match " + string(keyType))
+	}
+	return out, string(bytes.TrimSpace(line)), true, nil
+}
+
+// Split the options at the start of line from the key that follows them.
+// Options end at the first space or tab outside double quotes, and are
+// separated by commas outside double quotes.
+func splitOptions(line []byte) (options []string, keyPart []byte, found bool) {
+	inQuote := false
+	start := 0
+
+	for i, b := range line {
+		isEnd := !inQuote && (b == ' ' || b == '\t')
+		if (b == ',' && !inQuote) || isEnd {
+			if i-start > 0 {
+				options = append(options, string(line[start:i]))
+			}
+			start = i + 1
+		}
+		if isEnd {
+			return options, bytes.TrimLeft(line[i:], " \t"), true
+		}
+		if b == '"' && (i == 0 || line[i-1] != '\\') {
+			inQuote = !inQuote
+		}
+	}
+	return nil, nil, false
+}
+
+// The first space or tab separated field of line, and what follows it
+func nextField(line []byte) (field []byte, rest []byte) {
+	line = bytes.TrimLeft(line, " \t")
+	end := bytes.IndexAny(line, " \t")
+	if end == -1 {
+		return line, nil
+	}
+	return line[:end], line[end:]
+}
+
+// Parse a signature in wire format. Security key signatures keep their
+// flags and counter at the end of Blob.
+func ParseSignature(in []byte) (*ssh.Signature, error) {
+	var w struct {
+		Format string
+		Blob   []byte
+		Rest   []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(in, &w); err != nil {
+		return nil, err
+	}
+
+	if IsSK(w.Format) {
+		if len(w.Rest) != 5 {
+			return nil, errors.New("ssh: security key signature is missing its flags and counter")
+		}
+		w.Blob = append(w.Blob, w.Rest...)
+	} else if len(w.Rest) > 0 {
+		return nil, errors.New("ssh: trailing data after signature")
+	}
+
+	return &ssh.Signature{Format: w.Format, Blob: w.Blob}, nil
+}
+
+// The first line of in that is not blank or a comment, and what follows it
+func firstLine(in []byte) (line []byte, rest []byte) {
+	for len(in) > 0 {
+		end := bytes.IndexByte(in, '\n')
+		if end == -1 {
+			line, in = in, nil
+		} else {
+			line, in = in[:end], in[end+1:]
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			return line, in
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,120 @@
+package sshkeys
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	skEd25519Key64 = "AAAAGnNrLXNzaC1lZDI1NTE5QG9wZW5zc2guY29tAAAAIJjzc2a20RjCvN/0ibH6UpGuN9F9hDvD7x182bOesNhHAAAABHNzaDo="
+	ecdsaKey64     = "AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBFzYL0Z2T2Jq7cVEZqfEWRk0IPjNjCVFY86D85sjeJUuSqbIliV1I5s/O2fglUZuTNboufMIxjKMihgOmdIfh2w="
+)
+
+func TestParseAuthorizedKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		keyType  string
+		comment  string
+		options  []string
+		rest     string
+		hasError bool
+	}{
+		{
+			name:    "plain",
+			in:      "sk-ssh-ed25519@openssh.com " + skEd25519Key64 + " user@host",
+			keyType: KeyAlgoSKED25519,
+			comment: "user@host",
+		},
+		{
+			name:    "no comment",
+			in:      "sk-ssh-ed25519@openssh.com " + skEd25519Key64,
+			keyType: KeyAlgoSKED25519,
+		},
+		{
+			name:    "comment names a key type",
+			in:      "sk-ssh-ed25519@openssh.com " + skEd25519Key64 + " my sk-ecdsa-sha2-nistp256@openssh.com key",
+			keyType: KeyAlgoSKED25519,
+			comment: "my sk-ecdsa-sha2-nistp256@openssh.com key",
+		},
+		{
+			name:    "options",
+			in:      "no-pty,no-agent-forwarding sk-ssh-ed25519@openssh.com " + skEd25519Key64 + " user@host",
+			keyType: KeyAlgoSKED25519,
+			comment: "user@host",
+			options: []string{"no-pty", "no-agent-forwarding"},
+		},
+		{
+			name:    "quoted option with comma and space",
+			in:      `command="echo a, b",no-pty sk-ssh-ed25519@openssh.com ` + skEd25519Key64 + " user@host",
+			keyType: KeyAlgoSKED25519,
+			comment: "user@host",
+			options: []string{`command="echo a, b"`, "no-pty"},
+		},
+		{
+			name:    "quoted option names a key type",
+			in:      `command="echo sk-ssh-ed25519@openssh.com \"x\"" sk-ssh-ed25519@openssh.com ` + skEd25519Key64 + " user@host",
+			keyType: KeyAlgoSKED25519,
+			comment: "user@host",
+			options: []string{`command="echo sk-ssh-ed25519@openssh.com \"x\""`},
+		},
+		{
+			name:    "comment names a key type after a standard key",
+			in:      "ecdsa-sha2-nistp256 " + ecdsaKey64 + " sk-ssh-ed25519@openssh.com",
+			keyType: "ecdsa-sha2-nistp256",
+			comment: "sk-ssh-ed25519@openssh.com",
+		},
+		{
+			name:    "options before a standard key",
+			in:      `from="10.0.0.1,10.0.0.2" ecdsa-sha2-nistp256 ` + ecdsaKey64 + " user@host",
+			keyType: "ecdsa-sha2-nistp256",
+			comment: "user@host",
+			options: []string{`from="10.0.0.1,10.0.0.2"`},
+		},
+		{
+			name:    "skips blank lines and comments",
+			in:      "\n# sk-ssh-ed25519@openssh.com\nsk-ssh-ed25519@openssh.com " + skEd25519Key64 + " user@host\nnext",
+			keyType: KeyAlgoSKED25519,
+			comment: "user@host",
+			rest:    "next",
+		},
+		{
+			name:     "bad base64",
+			in:       "sk-ssh-ed25519@openssh.com !!!! user@host",
+			hasError: true,
+		},
+		{
+			name:     "type does not match key",
+			in:       "sk-ecdsa-sha2-nistp256@openssh.com " + skEd25519Key64,
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, comment, options, rest, err := ParseAuthorizedKey([]byte(tt.in))
+			if tt.hasError {
+				if err == nil {
+					t.Fatalf("parsed %s", key.Type())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if key.Type() != tt.keyType {
+				t.Errorf("type is %s, want %s", key.Type(), tt.keyType)
+			}
+			if comment != tt.comment {
+				t.Errorf("comment is %q, want %q", comment, tt.comment)
+			}
+			if !reflect.DeepEqual(options, tt.options) {
+				t.Errorf("options are %q, want %q", options, tt.options)
+			}
+			if string(rest) != tt.rest {
+				t.Errorf("rest is %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package sshkeys
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"math/big"
+)
+
+// Signature formats for RSA keys using SHA-2 instead of SHA-1, from RFC 8332
+const (
+	SigAlgoRSASHA256 = "rsa-sha2-256"
+	SigAlgoRSASHA512 = "rsa-sha2-512"
+)
+
+// Agent sign request flag asking for an rsa-sha2-512 signature
+const agentRSASHA512 = 4
+
+// Verify sig over data with key, like key.Verify, additionally accepting
+// rsa-sha2-256 and rsa-sha2-512 signatures from RSA keys
+func Verify(key ssh.PublicKey, data []byte, sig *ssh.Signature) error {
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+
+	var hash crypto.Hash
+	var digest []byte
+	switch sig.Format {
+	case SigAlgoRSASHA256:
+		sum := sha256.Sum256(data)
+		hash, digest = crypto.SHA256, sum[:]
+	case SigAlgoRSASHA512:
+		sum := sha512.Sum512(data)
+		hash, digest = crypto.SHA512, sum[:]
+	default:
+		return key.Verify(data, sig)
+	}
+
+	pub, ok := rsaKey(key)
+	if !ok {
+		return key.Verify(data, sig)
+	}
+	return rsa.VerifyPKCS1v15(pub, hash, digest, sig.Blob)
+}
+
+// The size of an RSA key's modulus in bits, or zero for other keys
+func RSABits(key ssh.PublicKey) int {
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+
+	pub, ok := rsaKey(key)
+	if !ok {
+		return 0
+	}
+	return pub.N.BitLen()
+}
+
+func rsaKey(key ssh.PublicKey) (*rsa.PublicKey, bool) {
+	if key.Type() != ssh.KeyAlgoRSA {
+		return nil, false
+	}
+
+	var w struct {
+		Type string
+		E    *big.Int
+		N    *big.Int
+	}
+	if err := ssh.Unmarshal(key.Marshal(), &w); err != nil || !w.E.IsInt64() {
+		return nil, false
+	}
+	return &rsa.PublicKey{N: w.N, E: int(w.E.Int64())}, true
+}
@@ -0,0 +1,68 @@
+package sshkeys
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"testing"
+)
+
+func TestVerifyRSASHA2(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("message")
+	sum256 := sha256.Sum256(data)
+	sum512 := sha512.Sum512(data)
+
+	sign := func(hash crypto.Hash, digest []byte) []byte {
+		blob, err := rsa.SignPKCS1v15(rand.Reader, priv, hash, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blob
+	}
+	blob256 := sign(crypto.SHA256, sum256[:])
+	blob512 := sign(crypto.SHA512, sum512[:])
+
+	tests := []struct {
+		name   string
+		format string
+		blob   []byte
+		data   []byte
+		ok     bool
+	}{
+		{"rsa-sha2-256", SigAlgoRSASHA256, blob256, data, true},
+		{"rsa-sha2-512", SigAlgoRSASHA512, blob512, data, true},
+		{"sha-256 signature labelled rsa-sha2-512", SigAlgoRSASHA512, blob256, data, false},
+		{"sha-512 signature labelled rsa-sha2-256", SigAlgoRSASHA256, blob512, data, false},
+		{"other data", SigAlgoRSASHA256, blob256, []byte("other"), false},
+		{"sha-256 signature labelled ssh-rsa", ssh.KeyAlgoRSA, blob256, data, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(key, tt.data, &ssh.Signature{Format: tt.format, Blob: tt.blob})
+			if tt.ok && err != nil {
+				t.Fatalf("did not verify: %s", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("verified")
+			}
+		})
+	}
+
+	if bits := RSABits(key); bits != 2048 {
+		t.Errorf("RSABits is %d, want 2048", bits)
+	}
+}
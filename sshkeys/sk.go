@@ -0,0 +1,187 @@
+// Package sshkeys adds what the vendored x/crypto/ssh lacks to its key
+// parsing and verification: the FIDO/U2F security key types
+// sk-ssh-ed25519@openssh.com and sk-ecdsa-sha2-nistp256@openssh.com, and
+// rsa-sha2-256 and rsa-sha2-512 signatures.
+package sshkeys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"math/big"
+)
+
+const (
+	KeyAlgoSKED25519  = "sk-ssh-ed25519@openssh.com"
+	KeyAlgoSKECDSA256 = "sk-ecdsa-sha2-nistp256@openssh.com"
+)
+
+// Set in a security key signature's flags when the user touched the key
+const FlagUserPresent = 0x01
+
+var ErrNoUserPresence = errors.New("security key signature was made without user presence")
+
+// Whether algo is a security key type
+func IsSK(algo string) bool {
+	return algo == KeyAlgoSKED25519 || algo == KeyAlgoSKECDSA256
+}
+
+type skEd25519Key struct {
+	key         ed25519.PublicKey
+	application string
+}
+
+type skECDSAKey struct {
+	key         *ecdsa.PublicKey
+	application string
+}
+
+func parseSKEd25519(in []byte) (ssh.PublicKey, error) {
+	var w struct {
+		Type        string
+		Key         []byte
+		Application string
+	}
+	if err := ssh.Unmarshal(in, &w); err != nil {
+		return nil, err
+	}
+
+	if len(w.Key) != ed25519.PublicKeySize {
+		return nil, errors.New("ssh: invalid size for sk-ssh-ed25519 public key")
+	}
+	return &skEd25519Key{key: ed25519.PublicKey(w.Key), application: w.Application}, nil
+}
+
+func parseSKECDSA(in []byte) (ssh.PublicKey, error) {
+	var w struct {
+		Type        string
+		Curve       string
+		Q           []byte
+		Application string
+	}
+	if err := ssh.Unmarshal(in, &w); err != nil {
+		return nil, err
+	}
+
+	if w.Curve != "nistp256" {
+		return nil, errors.New("ssh: unsupported curve for sk-ecdsa key: " + w.Curve)
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), w.Q)
+	if x == nil {
+		return nil, errors.New("ssh: invalid curve point in sk-ecdsa key")
+	}
+	return &skECDSAKey{key: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, application: w.Application}, nil
+}
+
+func (k *skEd25519Key) Type() string {
+	return KeyAlgoSKED25519
+}
+
+func (k *skEd25519Key) Marshal() []byte {
+	return ssh.Marshal(struct {
+		Type        string
+		Key         []byte
+		Application string
+	}{KeyAlgoSKED25519, []byte(k.key), k.application})
+}
+
+// Verify a signature whose blob is the ed25519 signature followed by the
+// flags byte and counter the security key signed along with data
+func (k *skEd25519Key) Verify(data []byte, sig *ssh.Signature) error {
+	if sig.Format != k.Type() {
+		return fmt.Errorf("ssh: signature type %s for key type %s", sig.Format, k.Type())
+	}
+
+	blob, flags, counter, err := splitSignature(sig.Blob)
+	if err != nil {
+		return err
+	}
+
+	if len(blob) != ed25519.SignatureSize {
+		return errors.New("ssh: invalid size for sk-ssh-ed25519 signature")
+	}
+
+	if !ed25519.Verify(k.key, signedData(k.application, flags, counter, data), blob) {
+		return errors.New("ssh: signature did not verify")
+	}
+	return checkFlags(flags)
+}
+
+func (k *skECDSAKey) Type() string {
+	return KeyAlgoSKECDSA256
+}
+
+func (k *skECDSAKey) Marshal() []byte {
+	return ssh.Marshal(struct {
+		Type        string
+		Curve       string
+		Q           []byte
+		Application string
+	}{KeyAlgoSKECDSA256, "nistp256", elliptic.Marshal(k.key.Curve, k.key.X, k.key.Y), k.application})
+}
+
+// Verify a signature whose blob is the ECDSA r and s followed by the flags
+// byte and counter the security key signed along with data
+func (k *skECDSAKey) Verify(data []byte, sig *ssh.Signature) error {
+	if sig.Format != k.Type() {
+		return fmt.Errorf("ssh: signature type %s for key type %s", sig.Format, k.Type())
+	}
+
+	blob, flags, counter, err := splitSignature(sig.Blob)
+	if err != nil {
+		return err
+	}
+
+	var ecSig struct {
+		R *big.Int
+		S *big.Int
+	}
+	if err := ssh.Unmarshal(blob, &ecSig); err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(signedData(k.application, flags, counter, data))
+	if !ecdsa.Verify(k.key, digest[:], ecSig.R, ecSig.S) {
+		return errors.New("ssh: signature did not verify")
+	}
+	return checkFlags(flags)
+}
+
+// Split a security key signature blob into the signature and the flags and
+// counter that follow it
+func splitSignature(b []byte) (blob []byte, flags byte, counter uint32, err error) {
+	if len(b) < 5 {
+		return nil, 0, 0, errors.New("ssh: security key signature is missing its flags and counter")
+	}
+
+	i := len(b) - 5
+	return b[:i], b[i], binary.BigEndian.Uint32(b[i+1:]), nil
+}
+
+// The data a security key actually signs: hashes of the application and
+// message around the flags and counter
+func signedData(application string, flags byte, counter uint32, data []byte) []byte {
+	appHash := sha256.Sum256([]byte(application))
+	dataHash := sha256.Sum256(data)
+
+	buf := bytes.Buffer{}
+	buf.Write(appHash[:])
+	buf.WriteByte(flags)
+	binary.Write(&buf, binary.BigEndian, counter)
+	buf.Write(dataHash[:])
+	return buf.Bytes()
+}
+
+func checkFlags(flags byte) error {
+	if flags&FlagUserPresent == 0 {
+		return ErrNoUserPresence
+	}
+	return nil
+}
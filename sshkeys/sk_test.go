@@ -0,0 +1,245 @@
+package sshkeys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"math/big"
+	"testing"
+)
+
+// Signatures recorded from real security keys, taken from the SKData test
+// vectors in golang.org/x/crypto/ssh/testdata. Data is an ssh userauth
+// request and the signatures are in wire format.
+var skVectors = []struct {
+	name      string
+	pubKey    string
+	data      string
+	signature string
+	flags     byte
+	counter   uint32
+}{
+	{
+		name:      "ecdsa",
+		pubKey:    "sk-ecdsa-sha2-nistp256@openssh.com AAAAInNrLWVjZHNhLXNoYTItbmlzdHAyNTZAb3BlbnNzaC5jb20AAAAIbmlzdHAyNTYAAABBBGRNqlFgED/pf4zXz8IzqA6CALNwYcwgd4MQDmIS1GOtn1SySFObiuyJaOlpqkV5FeEifhxfIC2ejKKtNyO4CysAAAAEc3NoOg== user@host",
+		data:      "00000020A4DE1F50DE0EF3F66DCD156C78F5C93B07EEE89D5B5A6531656E835FA1C87B323200000006736B696E6E650000000E7373682D636F6E6E656374696F6E000000097075626C69636B65790100000022736B2D65636473612D736861322D6E69737470323536406F70656E7373682E636F6D0000007F00000022736B2D65636473612D736861322D6E69737470323536406F70656E7373682E636F6D000000086E697374703235360000004104644DAA5160103FE97F8CD7CFC233A80E8200B37061CC207783100E6212D463AD9F54B248539B8AEC8968E969AA457915E1227E1C5F202D9E8CA2AD3723B80B2B000000047373683A",
+		signature: "0000007800000022736B2D65636473612D736861322D6E69737470323536406F70656E7373682E636F6D000000490000002016CC1A3070E180621CB206C2C6313D1CC5F094DB844A61D06001E243C608875F0000002100E4BD45D6B9DAA11489AEA8D76C222AA3FD6D50FBFFDA8049526D5D61F63B2C5601000000F9",
+		flags:     0x01,
+		counter:   0xF9,
+	},
+	{
+		name:      "ed25519",
+		pubKey:    "sk-ssh-ed25519@openssh.com AAAAGnNrLXNzaC1lZDI1NTE5QG9wZW5zc2guY29tAAAAIJjzc2a20RjCvN/0ibH6UpGuN9F9hDvD7x182bOesNhHAAAABHNzaDo= user@host",
+		data:      "000000204CFE6EA65CCB99B69348339165C7F38E359D95807A377EEE8E603C71DC3316FA3200000006736B696E6E650000000E7373682D636F6E6E656374696F6E000000097075626C69636B6579010000001A736B2D7373682D65643235353139406F70656E7373682E636F6D0000004A0000001A736B2D7373682D65643235353139406F70656E7373682E636F6D0000002098F37366B6D118C2BCDFF489B1FA5291AE37D17D843BC3EF1D7CD9B39EB0D847000000047373683A",
+		signature: "000000670000001A736B2D7373682D65643235353139406F70656E7373682E636F6D000000404BF5CA0CAA553099306518732317B3FE4BA6C75365BC0CB02019FBE65A1647016CBD7A682C26928DF234C378ADDBC5077B47F72381144840BF00FB2DA2FB6A0A010000009E",
+		flags:     0x01,
+		counter:   0x9E,
+	},
+}
+
+type skVector struct {
+	key  ssh.PublicKey
+	data []byte
+	sig  *ssh.Signature
+}
+
+func loadSKVector(t *testing.T, pubKey, data, signature string) skVector {
+	key, comment, _, _, err := ParseAuthorizedKey([]byte(pubKey))
+	if err != nil {
+		t.Fatalf("parsing key: %s", err)
+	}
+	if comment != "user@host" {
+		t.Fatalf("comment is %q", comment)
+	}
+
+	d, err := hex.DecodeString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := hex.DecodeString(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wire struct{ Signature []byte }
+	if err := ssh.Unmarshal(s, &wire); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := ParseSignature(wire.Signature)
+	if err != nil {
+		t.Fatalf("parsing signature: %s", err)
+	}
+	return skVector{key: key, data: d, sig: sig}
+}
+
+func TestSKRecordedSignatures(t *testing.T) {
+	for _, tt := range skVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			v := loadSKVector(t, tt.pubKey, tt.data, tt.signature)
+
+			if !IsSK(v.key.Type()) || v.sig.Format != v.key.Type() {
+				t.Fatalf("key type %s, signature format %s", v.key.Type(), v.sig.Format)
+			}
+
+			_, flags, counter, err := splitSignature(v.sig.Blob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flags != tt.flags || counter != tt.counter {
+				t.Errorf("flags %#x counter %#x, want %#x and %#x", flags, counter, tt.flags, tt.counter)
+			}
+
+			if err := v.key.Verify(v.data, v.sig); err != nil {
+				t.Fatalf("recorded signature did not verify: %s", err)
+			}
+
+			if err := Verify(v.key, v.data, v.sig); err != nil {
+				t.Fatalf("recorded signature did not verify through Verify: %s", err)
+			}
+		})
+	}
+}
+
+func TestSKRecordedSignaturesRejectTampering(t *testing.T) {
+	tamper := []struct {
+		name   string
+		modify func(v *skVector)
+	}{
+		{"data", func(v *skVector) { v.data[len(v.data)-1] ^= 1 }},
+		{"flags", func(v *skVector) { v.sig.Blob[len(v.sig.Blob)-5] ^= 0x04 }},
+		{"counter", func(v *skVector) { v.sig.Blob[len(v.sig.Blob)-1]++ }},
+		{"application", func(v *skVector) { v.key = withApplication(t, v.key, "ssh:other") }},
+		{"truncated", func(v *skVector) { v.sig.Blob = v.sig.Blob[:4] }},
+	}
+
+	for _, tt := range skVectors {
+		for _, tc := range tamper {
+			t.Run(tt.name+"/"+tc.name, func(t *testing.T) {
+				v := loadSKVector(t, tt.pubKey, tt.data, tt.signature)
+				tc.modify(&v)
+
+				if err := v.key.Verify(v.data, v.sig); err == nil {
+					t.Fatal("tampered signature verified")
+				}
+			})
+		}
+	}
+}
+
+// The application hash is the first thing the security key signs, so a key
+// registered for one application never verifies for another
+func TestSKApplicationHash(t *testing.T) {
+	data := []byte("message")
+	signed := signedData("ssh:", FlagUserPresent, 7, data)
+
+	appHash := sha256.Sum256([]byte("ssh:"))
+	dataHash := sha256.Sum256(data)
+
+	want := append(appHash[:], FlagUserPresent, 0, 0, 0, 7)
+	want = append(want, dataHash[:]...)
+	if !bytes.Equal(signed, want) {
+		t.Fatalf("signed data is %x, want %x", signed, want)
+	}
+
+	for _, tt := range skVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			v := loadSKVector(t, tt.pubKey, tt.data, tt.signature)
+
+			var application string
+			switch k := v.key.(type) {
+			case *skEd25519Key:
+				application = k.application
+			case *skECDSAKey:
+				application = k.application
+			}
+			if application != "ssh:" {
+				t.Errorf("application is %q, want ssh:", application)
+			}
+		})
+	}
+}
+
+// Keys generated here, since recorded signatures all have user presence set
+// and their flags cannot be changed without breaking the signature
+func TestSKUserPresenceRequired(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []struct {
+		name string
+		key  ssh.PublicKey
+		sign func(signed []byte) *ssh.Signature
+	}{
+		{
+			name: "ed25519",
+			key:  &skEd25519Key{key: edPub, application: "ssh:"},
+			sign: func(signed []byte) *ssh.Signature {
+				return &ssh.Signature{Format: KeyAlgoSKED25519, Blob: ed25519.Sign(edPriv, signed)}
+			},
+		},
+		{
+			name: "ecdsa",
+			key:  &skECDSAKey{key: &ecPriv.PublicKey, application: "ssh:"},
+			sign: func(signed []byte) *ssh.Signature {
+				digest := sha256.Sum256(signed)
+				r, s, err := ecdsa.Sign(rand.Reader, ecPriv, digest[:])
+				if err != nil {
+					t.Fatal(err)
+				}
+				blob := ssh.Marshal(struct{ R, S *big.Int }{r, s})
+				return &ssh.Signature{Format: KeyAlgoSKECDSA256, Blob: blob}
+			},
+		},
+	}
+
+	flags := []struct {
+		name  string
+		flags byte
+		want  error
+	}{
+		{"present", FlagUserPresent, nil},
+		{"present and verified", FlagUserPresent | 0x04, nil},
+		{"absent", 0, ErrNoUserPresence},
+		{"verified only", 0x04, ErrNoUserPresence},
+	}
+
+	data := []byte("message")
+	for _, k := range keys {
+		for _, f := range flags {
+			t.Run(k.name+"/"+f.name, func(t *testing.T) {
+				sig := k.sign(signedData("ssh:", f.flags, 1, data))
+				sig.Blob = append(sig.Blob, f.flags, 0, 0, 0, 1)
+
+				if err := k.key.Verify(data, sig); err != f.want {
+					t.Fatalf("got %v, want %v", err, f.want)
+				}
+			})
+		}
+	}
+}
+
+// The key with its application replaced
+func withApplication(t *testing.T, key ssh.PublicKey, application string) ssh.PublicKey {
+	switch k := key.(type) {
+	case *skEd25519Key:
+		return &skEd25519Key{key: k.key, application: application}
+	case *skECDSAKey:
+		return &skECDSAKey{key: k.key, application: application}
+	}
+	t.Fatalf("not a security key: %s", key.Type())
+	return nil
+}
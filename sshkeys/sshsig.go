@@ -0,0 +1,55 @@
+package sshkeys
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+)
+
+const sshsigMagic = "SSHSIG"
+
+// The bytes an SSHSIG signature over message signs, as ssh-keygen -Y sign
+// makes them
+func SSHSIGSignedData(namespace string, message []byte) []byte {
+	hash := sha512.Sum512(message)
+	return append([]byte(sshsigMagic), ssh.Marshal(struct {
+		Namespace string
+		Reserved  string
+		HashAlg   string
+		Hash      []byte
+	}{namespace, "", "sha512", hash[:]})...)
+}
+
+// Encode a signature made over SSHSIGSignedData in the armored format
+// ssh-keygen -Y sign writes
+func ArmorSSHSIG(key ssh.PublicKey, namespace string, sig *ssh.Signature) []byte {
+	blob := append([]byte(sshsigMagic), ssh.Marshal(struct {
+		Version   uint32
+		PublicKey []byte
+		Namespace string
+		Reserved  string
+		HashAlg   string
+		Signature []byte
+	}{1, key.Marshal(), namespace, "", "sha512", MarshalSignature(sig)})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	buf := bytes.Buffer{}
+	buf.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		buf.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	buf.WriteString(encoded + "\n")
+	buf.WriteString("-----END SSH SIGNATURE-----\n")
+	return buf.Bytes()
+}
+
+// Encode a signature in wire format, the inverse of ParseSignature
+func MarshalSignature(sig *ssh.Signature) []byte {
+	if IsSK(sig.Format) && len(sig.Blob) >= 5 {
+		i := len(sig.Blob) - 5
+		return append(ssh.Marshal(&ssh.Signature{Format: sig.Format, Blob: sig.Blob[:i]}), sig.Blob[i:]...)
+	}
+	return ssh.Marshal(sig)
+}
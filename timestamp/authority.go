@@ -0,0 +1,269 @@
+package timestamp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// A minimal timestamp authority, for testing against and for running a
+// local stand-in. It answers SHA-256 requests with tokens signed by its key,
+// whose certificate is issued by Root.
+type Authority struct {
+	Signer      crypto.Signer
+	Certificate *x509.Certificate
+	Root        *x509.Certificate
+	Policy      asn1.ObjectIdentifier
+	Clock       func() time.Time
+}
+
+// Policy OID stamped into tokens from a stand-in authority
+var StandInPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 4146, 1, 2, 3}
+
+// An authority with a fresh ECDSA key and timestamping certificate, issued
+// by a fresh root, both valid for a year
+func NewAuthority() (*Authority, error) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rootTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "signist stand-in timestamp root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root, err := createCertificate(rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "signist stand-in timestamp authority"},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.AddDate(1, 0, 0),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+		// RFC 3161 requires timestamping to be the only extended key
+		// usage, and critical
+		ExtraExtensions: []pkix.Extension{{
+			Id:       asn1.ObjectIdentifier{2, 5, 29, 37},
+			Critical: true,
+			Value:    mustMarshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 8}}),
+		}},
+		BasicConstraintsValid: true,
+	}
+	cert, err := createCertificate(template, root, &key.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authority{Signer: key, Certificate: cert, Root: root, Policy: StandInPolicy, Clock: time.Now}, nil
+}
+
+func createCertificate(template, parent *x509.Certificate, pub *ecdsa.PublicKey, priv *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// Answer a DER TimeStampReq with a DER TimeStampResp
+func (a *Authority) Timestamp(query []byte) ([]byte, error) {
+	req := timeStampReq{}
+	if rest, err := asn1.Unmarshal(query, &req); err != nil || len(rest) > 0 {
+		return rejection(2, "badDataFormat")
+	}
+	if !req.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || len(req.MessageImprint.HashedMessage) != sha256.Size {
+		return rejection(2, "badAlg")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         a.Policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   serial,
+		GenTime:        a.Clock().UTC().Truncate(time.Second),
+		Accuracy:       accuracy{Seconds: 1},
+		Nonce:          req.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.sign(info, req.CertReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: 0},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// Wrap a DER TSTInfo in CMS SignedData signed by the authority
+func (a *Authority) sign(info []byte, includeCert bool) ([]byte, error) {
+	if _, ok := a.Signer.Public().(*ecdsa.PublicKey); !ok {
+		return nil, errors.New("stand-in authority keys must be ECDSA")
+	}
+
+	infoDigest := sha256.Sum256(info)
+	certDigest := sha256.Sum256(a.Certificate.Raw)
+
+	essCert, err := asn1.Marshal(struct{ Certs []struct{ CertHash []byte } }{
+		Certs: []struct{ CertHash []byte }{{CertHash: certDigest[:]}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := asn1.Marshal(oidTSTInfo)
+	if err != nil {
+		return nil, err
+	}
+	messageDigest, err := asn1.Marshal(infoDigest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := marshalAttributes([]attribute{
+		{Type: oidContentType, Values: asn1.RawValue{Bytes: contentType}},
+		{Type: oidMessageDigest, Values: asn1.RawValue{Bytes: messageDigest}},
+		{Type: oidSigningCertificateV2, Values: asn1.RawValue{Bytes: essCert}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attrsDigest := sha256.Sum256(signedAttrsSet(attrs))
+	sig, err := a.Signer.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: a.Certificate.RawIssuer},
+		SerialNumber: a.Certificate.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: info},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          sig,
+		}},
+	}
+	if includeCert {
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: a.Certificate.Raw}
+	}
+
+	sdDER, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdDER},
+	})
+}
+
+// Serve RFC 3161 requests over HTTP
+func (a *Authority) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxResponseBytes))
+	if err != nil {
+		http.Error(w, "Could not read request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.Timestamp(query)
+	if err != nil {
+		http.Error(w, "Could not make a timestamp", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.Write(resp)
+}
+
+// A TimeStampResp refusing the request
+func rejection(status int, reason string) ([]byte, error) {
+	return asn1.Marshal(timeStampResp{Status: pkiStatusInfo{Status: status, StatusString: []string{reason}}})
+}
+
+// Encode attributes, each holding the single value in Values.Bytes, as the
+// contents of a DER SET OF, sorted by their encodings
+func marshalAttributes(attrs []attribute) ([]byte, error) {
+	encoded := [][]byte{}
+	for _, attr := range attrs {
+		der, err := asn1.Marshal(attribute{
+			Type:   attr.Type,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attr.Values.Bytes},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, der)
+	}
+
+	sort.Slice(encoded, func(i, j int) bool { return string(encoded[i]) < string(encoded[j]) })
+
+	out := []byte{}
+	for _, der := range encoded {
+		out = append(out, der...)
+	}
+	return out, nil
+}
+
+func mustMarshal(v interface{}) []byte {
+	der, err := asn1.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return der
+}
@@ -0,0 +1,457 @@
+// Package timestamp requests and verifies RFC 3161 timestamp tokens, which
+// prove that a SHA-256 digest existed at the time a timestamp authority
+// signed it. Tokens are CMS SignedData, kept in their DER encoding.
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const maxResponseBytes = 1 << 20
+
+var (
+	ErrDigestMismatch = errors.New("timestamp token is for a different digest")
+	ErrInvalidToken   = errors.New("timestamp token is malformed")
+)
+
+var (
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertificate   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 12}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidECDSAWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+var digestHashes = map[string]crypto.Hash{
+	oidSHA256.String(): crypto.SHA256,
+	oidSHA384.String(): crypto.SHA384,
+	oidSHA512.String(): crypto.SHA512,
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// Content is the [0] EXPLICIT wrapper itself: encoding/asn1 passes raw
+// values through untouched, tags and all
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// The ESS signingCertificate attributes of RFC 2634 and RFC 5035, which name
+// the signer's certificate by its hash. Version 1 hashes with SHA-1, and
+// version 2 with HashAlgorithm, SHA-256 when it is absent.
+type essCertID struct {
+	CertHash     []byte
+	IssuerSerial issuerSerial `asn1:"optional"`
+}
+
+type essCertIDv2 struct {
+	HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+	CertHash      []byte
+	IssuerSerial  issuerSerial `asn1:"optional"`
+}
+
+type issuerSerial struct {
+	Issuer       []asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signingCertificate struct {
+	Certs    []essCertID
+	Policies asn1.RawValue `asn1:"optional"`
+}
+
+type signingCertificateV2 struct {
+	Certs    []essCertIDv2
+	Policies asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional,default:false"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,explicit,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// A parsed timestamp token
+type Token struct {
+	GenTime      time.Time
+	SerialNumber *big.Int
+	Certificates []*x509.Certificate
+
+	info   tstInfo
+	signed signedData
+}
+
+// Ask the timestamp authority at url for a token over a SHA-256 digest
+func Fetch(ctx context.Context, url string, digest []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, HashedMessage: digest},
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp authority responded %s", res.Status)
+	}
+
+	resp := timeStampResp{}
+	if rest, err := asn1.Unmarshal(body, &resp); err != nil || len(rest) > 0 {
+		return nil, ErrInvalidToken
+	}
+	if resp.Status.Status > 1 || len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp authority refused the request: status %d %v", resp.Status.Status, resp.Status.StatusString)
+	}
+
+	token, err := Parse(resp.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if token.info.Nonce == nil || token.info.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("timestamp token does not answer this request")
+	}
+	if !bytes.Equal(token.info.MessageImprint.HashedMessage, digest) {
+		return nil, ErrDigestMismatch
+	}
+
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+// Parse a DER timestamp token without verifying it
+func Parse(der []byte) (*Token, error) {
+	ci := contentInfo{}
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil || len(rest) > 0 || !ci.ContentType.Equal(oidSignedData) || ci.Content.Tag != 0 {
+		return nil, ErrInvalidToken
+	}
+
+	token := &Token{}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &token.signed); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !token.signed.EncapContentInfo.EContentType.Equal(oidTSTInfo) || len(token.signed.SignerInfos) != 1 {
+		return nil, ErrInvalidToken
+	}
+	if _, err := asn1.Unmarshal(token.signed.EncapContentInfo.EContent, &token.info); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if len(token.signed.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(token.signed.Certificates.Bytes)
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		token.Certificates = certs
+	}
+
+	token.GenTime = token.info.GenTime
+	token.SerialNumber = token.info.SerialNumber
+	return token, nil
+}
+
+// Check that the token covers the SHA-256 digest and was signed by a
+// timestamping certificate that chains to roots, returning the time the
+// authority vouched for
+func (token *Token) Verify(digest []byte, roots *x509.CertPool) (time.Time, error) {
+	imprint := token.info.MessageImprint
+	if !imprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(imprint.HashedMessage, digest) {
+		return time.Time{}, ErrDigestMismatch
+	}
+
+	si := token.signed.SignerInfos[0]
+	signer := token.signer(si)
+	if signer == nil {
+		return time.Time{}, errors.New("timestamp token does not include its signing certificate")
+	}
+
+	hash, ok := digestHashes[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return time.Time{}, errors.New("timestamp token uses an unsupported digest algorithm")
+	}
+	if err := checkSignedAttrs(si, hash, token.signed.EncapContentInfo.EContent, signer); err != nil {
+		return time.Time{}, err
+	}
+	if err := checkSignature(signer, hash, signedAttrsSet(si.SignedAttrs.Bytes), si.Signature); err != nil {
+		return time.Time{}, err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range token.Certificates {
+		intermediates.AddCert(cert)
+	}
+	_, err := signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   token.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp authority is not trusted: %s", err.Error())
+	}
+
+	return token.GenTime, nil
+}
+
+// The certificate the signer info identifies
+func (token *Token) signer(si signerInfo) *x509.Certificate {
+	ias := issuerAndSerialNumber{}
+	_, err := asn1.Unmarshal(si.SID.FullBytes, &ias)
+
+	for _, cert := range token.Certificates {
+		if err == nil && bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return cert
+		}
+		if si.SID.Class == asn1.ClassContextSpecific && si.SID.Tag == 0 && bytes.Equal(cert.SubjectKeyId, si.SID.Bytes) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// Check that the signed attributes name the content as a TSTInfo, carry its
+// digest, and name signer in a signingCertificate or signingCertificateV2
+// attribute, as RFC 3161 and RFC 5816 require
+func checkSignedAttrs(si signerInfo, hash crypto.Hash, content []byte, signer *x509.Certificate) error {
+	if len(si.SignedAttrs.Bytes) == 0 {
+		return errors.New("timestamp token has no signed attributes")
+	}
+
+	h := hash.New()
+	h.Write(content)
+	contentDigest := h.Sum(nil)
+
+	var typeOK, digestOK, certFound bool
+	certOK := true
+	rest := si.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		attr := attribute{}
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return ErrInvalidToken
+		}
+
+		switch {
+		case attr.Type.Equal(oidContentType):
+			oid := asn1.ObjectIdentifier{}
+			_, err := asn1.Unmarshal(attr.Values.Bytes, &oid)
+			typeOK = err == nil && oid.Equal(oidTSTInfo)
+		case attr.Type.Equal(oidMessageDigest):
+			var digest []byte
+			_, err := asn1.Unmarshal(attr.Values.Bytes, &digest)
+			digestOK = err == nil && bytes.Equal(digest, contentDigest)
+		case attr.Type.Equal(oidSigningCertificate):
+			sc := signingCertificate{}
+			_, err := asn1.Unmarshal(attr.Values.Bytes, &sc)
+			certFound = true
+			certOK = certOK && err == nil && len(sc.Certs) > 0 && certIDMatches(crypto.SHA1, sc.Certs[0].CertHash, sc.Certs[0].IssuerSerial, signer)
+		case attr.Type.Equal(oidSigningCertificateV2):
+			sc := signingCertificateV2{}
+			_, err := asn1.Unmarshal(attr.Values.Bytes, &sc)
+			certFound = true
+			certOK = certOK && err == nil && len(sc.Certs) > 0 && certIDv2Matches(sc.Certs[0], signer)
+		}
+	}
+
+	if !typeOK || !digestOK {
+		return errors.New("timestamp token's signed attributes do not match its content")
+	}
+	if !certFound {
+		return errors.New("timestamp token's signed attributes do not name its signing certificate")
+	}
+	if !certOK {
+		return errors.New("timestamp token's signing certificate attribute does not match its signer")
+	}
+	return nil
+}
+
+// Whether the first ESSCertIDv2, which identifies the signer, names cert
+func certIDv2Matches(id essCertIDv2, cert *x509.Certificate) bool {
+	hash := crypto.SHA256
+	if len(id.HashAlgorithm.Algorithm) > 0 {
+		var ok bool
+		if hash, ok = digestHashes[id.HashAlgorithm.Algorithm.String()]; !ok {
+			return false
+		}
+	}
+	return certIDMatches(hash, id.CertHash, id.IssuerSerial, cert)
+}
+
+// Whether certHash is cert's hash and, when issuerSerial is given, it names
+// cert's issuer and serial number
+func certIDMatches(hash crypto.Hash, certHash []byte, is issuerSerial, cert *x509.Certificate) bool {
+	h := hash.New()
+	h.Write(cert.Raw)
+	if !bytes.Equal(h.Sum(nil), certHash) {
+		return false
+	}
+
+	if is.SerialNumber == nil {
+		return true
+	}
+	if is.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		return false
+	}
+
+	// The issuer is GeneralNames, of which a directoryName ([4]) must be
+	// the certificate's issuer
+	for _, name := range is.Issuer {
+		if name.Class == asn1.ClassContextSpecific && name.Tag == 4 && bytes.Equal(name.Bytes, cert.RawIssuer) {
+			return true
+		}
+	}
+	return false
+}
+
+// The DER SET OF attributes that the signature covers
+func signedAttrsSet(attrs []byte) []byte {
+	der, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	return der
+}
+
+func checkSignature(cert *x509.Certificate, hash crypto.Hash, signed []byte, sig []byte) error {
+	var algo x509.SignatureAlgorithm
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		algo = map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA256: x509.SHA256WithRSA, crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA}[hash]
+	case *ecdsa.PublicKey:
+		algo = map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA256: x509.ECDSAWithSHA256, crypto.SHA384: x509.ECDSAWithSHA384, crypto.SHA512: x509.ECDSAWithSHA512}[hash]
+	default:
+		return errors.New("timestamp authority key type is not supported")
+	}
+
+	if err := cert.CheckSignature(algo, signed, sig); err != nil {
+		return fmt.Errorf("timestamp token signature is invalid: %s", err.Error())
+	}
+	return nil
+}
+
+// Parse the certificates in a PEM file into a pool of trusted timestamp
+// authority roots
+func ParseRoots(pemData []byte) (*x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pemData) {
+		return nil, errors.New("no certificates found")
+	}
+	return roots, nil
+}
+
+// The digest a token over data must cover
+func Digest(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
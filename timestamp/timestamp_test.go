@@ -0,0 +1,214 @@
+package timestamp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	authority, err := NewAuthority()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewAuthority()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	authority.Clock = func() time.Time { return now }
+
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Root)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other.Root)
+
+	digest := Digest([]byte("signist"))
+	certHash := sha256.Sum256(authority.Certificate.Raw)
+	otherHash := sha256.Sum256(other.Certificate.Raw)
+	v1Hash := sha1.Sum(authority.Certificate.Raw)
+
+	tests := []struct {
+		name   string
+		digest []byte
+		roots  *x509.CertPool
+		tamper func(t *testing.T, token *Token)
+		err    string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:   "different digest",
+			digest: Digest([]byte("tsingi")),
+			err:    ErrDigestMismatch.Error(),
+		},
+		{
+			name:  "untrusted authority",
+			roots: otherRoots,
+			err:   "not trusted",
+		},
+		{
+			name: "signing certificate names another certificate",
+			tamper: func(t *testing.T, token *Token) {
+				resign(t, authority, token, replaceAttr(attributes(t, token), oidSigningCertificateV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: otherHash[:]}}}))
+			},
+			err: "does not match its signer",
+		},
+		{
+			name: "signing certificate names another serial number",
+			tamper: func(t *testing.T, token *Token) {
+				is := issuerSerial{
+					Issuer:       []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: authority.Certificate.RawIssuer}},
+					SerialNumber: new(big.Int).Add(authority.Certificate.SerialNumber, big.NewInt(1)),
+				}
+				resign(t, authority, token, replaceAttr(attributes(t, token), oidSigningCertificateV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:], IssuerSerial: is}}}))
+			},
+			err: "does not match its signer",
+		},
+		{
+			name: "signing certificate with issuer and serial number",
+			tamper: func(t *testing.T, token *Token) {
+				is := issuerSerial{
+					Issuer:       []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: authority.Certificate.RawIssuer}},
+					SerialNumber: authority.Certificate.SerialNumber,
+				}
+				resign(t, authority, token, replaceAttr(attributes(t, token), oidSigningCertificateV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:], IssuerSerial: is}}}))
+			},
+		},
+		{
+			name: "version 1 signing certificate",
+			tamper: func(t *testing.T, token *Token) {
+				attrs := removeAttr(attributes(t, token), oidSigningCertificateV2)
+				attrs = append(attrs, attribute{Type: oidSigningCertificate, Values: asn1.RawValue{Bytes: mustMarshal(signingCertificate{Certs: []essCertID{{CertHash: v1Hash[:]}}})}})
+				resign(t, authority, token, attrs)
+			},
+		},
+		{
+			name: "no signing certificate",
+			tamper: func(t *testing.T, token *Token) {
+				resign(t, authority, token, removeAttr(attributes(t, token), oidSigningCertificateV2))
+			},
+			err: "do not name its signing certificate",
+		},
+		{
+			name: "signed attributes changed after signing",
+			tamper: func(t *testing.T, token *Token) {
+				attrs := replaceAttr(attributes(t, token), oidSigningCertificateV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}, Policies: asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true}})
+				der, err := marshalAttributes(attrs)
+				if err != nil {
+					t.Fatal(err)
+				}
+				token.signed.SignerInfos[0].SignedAttrs.Bytes = der
+			},
+			err: "signature is invalid",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := stamp(t, authority, digest)
+			if test.tamper != nil {
+				test.tamper(t, token)
+			}
+
+			checkDigest := digest
+			if test.digest != nil {
+				checkDigest = test.digest
+			}
+			checkRoots := roots
+			if test.roots != nil {
+				checkRoots = test.roots
+			}
+
+			genTime, err := token.Verify(checkDigest, checkRoots)
+			if len(test.err) == 0 {
+				if err != nil {
+					t.Fatalf("Verify: %s", err)
+				}
+				if !genTime.Equal(now) {
+					t.Errorf("time is %s, want %s", genTime, now)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error is %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+// A token from authority over digest, including the signing certificate
+func stamp(t *testing.T, authority *Authority, digest []byte) *Token {
+	query := mustMarshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, HashedMessage: digest},
+		CertReq:        true,
+	})
+	der, err := authority.Timestamp(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := timeStampResp{}
+	if _, err := asn1.Unmarshal(der, &resp); err != nil {
+		t.Fatal(err)
+	}
+	token, err := Parse(resp.TimeStampToken.FullBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func attributes(t *testing.T, token *Token) []attribute {
+	attrs := []attribute{}
+	rest := token.signed.SignerInfos[0].SignedAttrs.Bytes
+	for len(rest) > 0 {
+		attr := attribute{}
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			t.Fatal(err)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+func removeAttr(attrs []attribute, oid asn1.ObjectIdentifier) []attribute {
+	kept := []attribute{}
+	for _, attr := range attrs {
+		if !attr.Type.Equal(oid) {
+			kept = append(kept, attr)
+		}
+	}
+	return kept
+}
+
+func replaceAttr(attrs []attribute, oid asn1.ObjectIdentifier, value interface{}) []attribute {
+	return append(removeAttr(attrs, oid), attribute{Type: oid, Values: asn1.RawValue{Bytes: mustMarshal(value)}})
+}
+
+// Replace the token's signed attributes with attrs, signed by authority
+func resign(t *testing.T, authority *Authority, token *Token, attrs []attribute) {
+	der, err := marshalAttributes(attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(signedAttrsSet(der))
+	sig, err := authority.Signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token.signed.SignerInfos[0].SignedAttrs.Bytes = der
+	token.signed.SignerInfos[0].Signature = sig
+}
@@ -0,0 +1,150 @@
+package translog
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSignature = errors.New("log signature does not verify")
+
+// The state of the log at one size, published as a signed note:
+//
+//	<origin>
+//	<size>
+//	<base64 root hash>
+//
+//	— <name> <base64 of a 4 byte key hint and an ECDSA signature>
+type Checkpoint struct {
+	Origin   string
+	Size     int
+	RootHash []byte
+}
+
+// The hex SHA-256 of the log's public key, which identifies the log
+func LogID(pub *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func (cp Checkpoint) text() string {
+	return cp.Origin + "\n" + strconv.Itoa(cp.Size) + "\n" + base64.StdEncoding.EncodeToString(cp.RootHash) + "\n"
+}
+
+// Sign the checkpoint as a note from name
+func (cp Checkpoint) Sign(name string, key *ecdsa.PrivateKey) (string, error) {
+	hint, err := keyHint(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	text := cp.text()
+	hash := sha256.Sum256([]byte(text))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return text + "\n— " + name + " " + base64.StdEncoding.EncodeToString(append(hint, sig...)) + "\n", nil
+}
+
+// Parse a signed checkpoint, requiring a signature by pub
+func ParseCheckpoint(note string, pub *ecdsa.PublicKey) (Checkpoint, error) {
+	i := strings.Index(note, "\n\n")
+	if i == -1 {
+		return Checkpoint{}, errors.New("checkpoint has no signatures")
+	}
+	text, sigs := note[:i+1], note[i+2:]
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) < 3 {
+		return Checkpoint{}, errors.New("checkpoint must have an origin, size and root hash")
+	}
+	size, err := strconv.Atoi(lines[1])
+	if err != nil || size < 0 {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint size %q", lines[1])
+	}
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return Checkpoint{}, errors.New("invalid checkpoint root hash")
+	}
+
+	hint, err := keyHint(pub)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	hash := sha256.Sum256([]byte(text))
+
+	for _, line := range strings.Split(sigs, "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) < 2 {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+		if err != nil || len(sig) < 5 || !bytes.Equal(sig[:4], hint) {
+			continue
+		}
+		if ecdsa.VerifyASN1(pub, hash[:], sig[4:]) {
+			return Checkpoint{Origin: lines[0], Size: size, RootHash: root}, nil
+		}
+	}
+	return Checkpoint{}, ErrInvalidSignature
+}
+
+func keyHint(pub *ecdsa.PublicKey) ([]byte, error) {
+	id, err := LogID(pub)
+	if err != nil {
+		return nil, err
+	}
+	hint, _ := hex.DecodeString(id[:8])
+	return hint, nil
+}
+
+// The canonical JSON an entry's signed entry timestamp covers. Keys are
+// sorted and there is no whitespace, as RFC 8785 requires for these values.
+func entryTimestampData(body string, integratedTime int64, logID string, logIndex int) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"body":           body,
+		"integratedTime": integratedTime,
+		"logID":          logID,
+		"logIndex":       logIndex,
+	})
+	return data
+}
+
+// Sign a promise that the entry with the base64 body was integrated into the
+// log at logIndex, returning the base64 signature
+func SignEntryTimestamp(key *ecdsa.PrivateKey, body string, integratedTime int64, logID string, logIndex int) (string, error) {
+	hash := sha256.Sum256(entryTimestampData(body, integratedTime, logID, logIndex))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Check a signed entry timestamp made by SignEntryTimestamp
+func VerifyEntryTimestamp(pub *ecdsa.PublicKey, set string, body string, integratedTime int64, logID string, logIndex int) error {
+	sig, err := base64.StdEncoding.DecodeString(set)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	hash := sha256.Sum256(entryTimestampData(body, integratedTime, logID, logIndex))
+	if !ecdsa.VerifyASN1(pub, hash[:], sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+// Package translog implements the RFC 6962 Merkle tree behind signist's
+// append-only log: leaf and root hashes, and inclusion and consistency
+// proofs and their verification. Trees are given as the leaf hashes in log
+// order, or as a NodeSource of the hashes of their perfect subtrees.
+package translog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+)
+
+var (
+	ErrInvalidProof = errors.New("proof does not verify against the root hash")
+	ErrOutOfRange   = errors.New("index or tree size out of range")
+)
+
+// The hash of a log entry's body as a leaf of the tree
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// The largest power of two smaller than n, for n > 1
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// The root hash of the tree with these leaves
+func RootHash(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	}
+
+	k := split(len(leaves))
+	return nodeHash(RootHash(leaves[:k]), RootHash(leaves[k:]))
+}
+
+// The audit path showing the leaf at index is in the tree of leaves
+func InclusionProof(index int, leaves [][]byte) ([][]byte, error) {
+	return InclusionProofFrom(Leaves(leaves), index, len(leaves))
+}
+
+// The proof that the tree of the first size leaves is a prefix of the tree
+// of leaves
+func ConsistencyProof(size int, leaves [][]byte) ([][]byte, error) {
+	return ConsistencyProofFrom(Leaves(leaves), size, len(leaves))
+}
+
+// The hashes of a tree's perfect subtrees, so that roots and proofs can be
+// made without every leaf. Node returns the hash of the subtree of 2^level
+// leaves that starts at leaf index << level. Level 0 holds the leaves.
+type NodeSource interface {
+	Node(level int, index int) ([]byte, error)
+}
+
+// A perfect subtree's hash, at the level and index NodeSource looks it up by
+type Node struct {
+	Level int
+	Index int
+	Hash  []byte
+}
+
+// A tree given by all of its leaves
+type Leaves [][]byte
+
+func (leaves Leaves) Node(level int, index int) ([]byte, error) {
+	start, end := index<<level, (index+1)<<level
+	if index < 0 || end > len(leaves) {
+		return nil, ErrOutOfRange
+	}
+	return RootHash(leaves[start:end]), nil
+}
+
+// The nodes that appending leaf at index completes: the leaf itself, then
+// each subtree it is the last leaf of. src must hold the nodes of the tree
+// of the first index leaves.
+func AppendNodes(src NodeSource, index int, leaf []byte) ([]Node, error) {
+	nodes := []Node{{Level: 0, Index: index, Hash: leaf}}
+	hash, level := leaf, 0
+	for ; index&1 == 1; index >>= 1 {
+		left, err := src.Node(level, index-1)
+		if err != nil {
+			return nil, err
+		}
+		hash = nodeHash(left, hash)
+		level++
+		nodes = append(nodes, Node{Level: level, Index: index >> 1, Hash: hash})
+	}
+	return nodes, nil
+}
+
+// The root hash of the tree of size leaves
+func RootHashFrom(src NodeSource, size int) ([]byte, error) {
+	if size < 0 {
+		return nil, ErrOutOfRange
+	}
+	if size == 0 {
+		return RootHash(nil), nil
+	}
+	return rangeHash(src, 0, size)
+}
+
+// The audit path showing the leaf at index is in the tree of size leaves
+func InclusionProofFrom(src NodeSource, index int, size int) ([][]byte, error) {
+	if index < 0 || index >= size {
+		return nil, ErrOutOfRange
+	}
+	return inclusionPath(src, index, 0, size)
+}
+
+// The proof that the tree of the first size1 leaves is a prefix of the tree
+// of size2 leaves
+func ConsistencyProofFrom(src NodeSource, size1 int, size2 int) ([][]byte, error) {
+	if size1 < 0 || size1 > size2 {
+		return nil, ErrOutOfRange
+	}
+	if size1 == 0 || size1 == size2 {
+		return [][]byte{}, nil
+	}
+	return subproof(src, size1, 0, size2, true)
+}
+
+// The hash of leaves start to end. Every range RFC 6962 splits a tree into
+// is a run of perfect subtrees aligned to their size.
+func rangeHash(src NodeSource, start int, end int) ([]byte, error) {
+	n := end - start
+	if n&(n-1) == 0 {
+		level := bits.TrailingZeros(uint(n))
+		return src.Node(level, start>>level)
+	}
+
+	k := split(n)
+	left, err := rangeHash(src, start, start+k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := rangeHash(src, start+k, end)
+	if err != nil {
+		return nil, err
+	}
+	return nodeHash(left, right), nil
+}
+
+func inclusionPath(src NodeSource, index int, start int, end int) ([][]byte, error) {
+	if end-start <= 1 {
+		return [][]byte{}, nil
+	}
+
+	k := split(end - start)
+	var path [][]byte
+	var sibling []byte
+	var err error
+	if index < start+k {
+		if path, err = inclusionPath(src, index, start, start+k); err == nil {
+			sibling, err = rangeHash(src, start+k, end)
+		}
+	} else {
+		if path, err = inclusionPath(src, index, start+k, end); err == nil {
+			sibling, err = rangeHash(src, start, start+k)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(path, sibling), nil
+}
+
+// RFC 6962's SUBPROOF(m, D[start:end], complete), with m counted from start
+func subproof(src NodeSource, m int, start int, end int, complete bool) ([][]byte, error) {
+	if m == end-start {
+		if complete {
+			return [][]byte{}, nil
+		}
+		root, err := rangeHash(src, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{root}, nil
+	}
+
+	k := split(end - start)
+	var proof [][]byte
+	var sibling []byte
+	var err error
+	if m <= k {
+		if proof, err = subproof(src, m, start, start+k, complete); err == nil {
+			sibling, err = rangeHash(src, start+k, end)
+		}
+	} else {
+		if proof, err = subproof(src, m-k, start+k, end, false); err == nil {
+			sibling, err = rangeHash(src, start, start+k)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, sibling), nil
+}
+
+// Check that leaf is at index in the tree of size with root, following
+// RFC 9162 section 2.1.3.2
+func VerifyInclusion(index int, size int, leaf []byte, proof [][]byte, root []byte) error {
+	if index < 0 || index >= size {
+		return ErrOutOfRange
+	}
+
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// Check that the tree of size1 with root1 is a prefix of the tree of size2
+// with root2, following RFC 9162 section 2.1.4.2
+func VerifyConsistency(size1 int, size2 int, root1 []byte, root2 []byte, proof [][]byte) error {
+	switch {
+	case size1 < 0 || size1 > size2:
+		return ErrOutOfRange
+	case size1 == size2:
+		if len(proof) != 0 || !bytes.Equal(root1, root2) {
+			return ErrInvalidProof
+		}
+		return nil
+	case size1 == 0:
+		if len(proof) != 0 {
+			return ErrInvalidProof
+		}
+		return nil
+	case len(proof) == 0:
+		return ErrInvalidProof
+	}
+
+	if size1&(size1-1) == 0 {
+		proof = append([][]byte{root1}, proof...)
+	}
+
+	fn, sn := size1-1, size2-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, root1) || !bytes.Equal(sr, root2) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// Nodes appended in memory over Base, which holds the nodes of the tree of
+// the first Size leaves
+type Overlay struct {
+	Base  NodeSource
+	Size  int
+	nodes map[[2]int][]byte
+}
+
+// Append a leaf, returning the nodes it completes
+func (o *Overlay) Append(leaf []byte) ([]Node, error) {
+	nodes, err := AppendNodes(o, o.Size, leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.nodes == nil {
+		o.nodes = map[[2]int][]byte{}
+	}
+	for _, node := range nodes {
+		o.nodes[[2]int{node.Level, node.Index}] = node.Hash
+	}
+	o.Size++
+	return nodes, nil
+}
+
+func (o *Overlay) Node(level int, index int) ([]byte, error) {
+	if hash, ok := o.nodes[[2]int{level, index}]; ok {
+		return hash, nil
+	}
+	return o.Base.Node(level, index)
+}
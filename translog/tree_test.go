@@ -0,0 +1,85 @@
+package translog
+
+import (
+	"strconv"
+	"testing"
+)
+
+// Trees built by appending leaves one at a time give the same roots as
+// hashing every leaf, and proofs that verify
+func TestNodeSourceMatchesLeaves(t *testing.T) {
+	leaves := [][]byte{}
+	tree := &Overlay{Base: Leaves(nil)}
+
+	for size := 1; size <= 70; size++ {
+		leaf := LeafHash([]byte(strconv.Itoa(size)))
+		leaves = append(leaves, leaf)
+		if _, err := tree.Append(leaf); err != nil {
+			t.Fatal(err)
+		}
+
+		root, err := RootHashFrom(tree, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(root) != string(RootHash(leaves)) {
+			t.Fatalf("size %d: root differs from hashing the leaves", size)
+		}
+
+		for index := 0; index < size; index++ {
+			proof, err := InclusionProofFrom(tree, index, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyInclusion(index, size, leaves[index], proof, root); err != nil {
+				t.Fatalf("size %d: inclusion of %d: %s", size, index, err)
+			}
+		}
+
+		for size1 := 0; size1 <= size; size1++ {
+			proof, err := ConsistencyProofFrom(tree, size1, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyConsistency(size1, size, RootHash(leaves[:size1]), root, proof); err != nil {
+				t.Fatalf("consistency of %d with %d: %s", size1, size, err)
+			}
+		}
+	}
+}
+
+func TestAppendNodes(t *testing.T) {
+	tests := []struct {
+		index  int
+		levels []int
+	}{
+		{0, []int{0}},
+		{1, []int{0, 1}},
+		{2, []int{0}},
+		{3, []int{0, 1, 2}},
+		{6, []int{0}},
+		{7, []int{0, 1, 2, 3}},
+		{11, []int{0, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		leaves := Leaves{}
+		for i := 0; i <= tt.index; i++ {
+			leaves = append(leaves, LeafHash([]byte(strconv.Itoa(i))))
+		}
+
+		nodes, err := AppendNodes(leaves[:tt.index], tt.index, leaves[tt.index])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nodes) != len(tt.levels) {
+			t.Fatalf("index %d: %d nodes, want %d", tt.index, len(nodes), len(tt.levels))
+		}
+		for i, node := range nodes {
+			want, _ := leaves.Node(node.Level, node.Index)
+			if node.Level != tt.levels[i] || string(node.Hash) != string(want) {
+				t.Errorf("index %d: node %d is level %d with the wrong hash", tt.index, i, node.Level)
+			}
+		}
+	}
+}
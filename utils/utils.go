@@ -3,19 +3,22 @@ package utils
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
 	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh/agent"
 	"github.com/andrewhamon/signist/github"
 	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/sshkeys"
 	"log"
 	"net"
 	"os"
+	"time"
 )
 
 // Convert agent.Key to ssh.PublicKey
 func keyToPubKey(in *agent.Key) (out ssh.PublicKey, err error) {
-	out, _, _, _, err = ssh.ParseAuthorizedKey([]byte(in.String()))
-	return out, err
+	return sshkeys.ParsePublicKey(in.Blob)
 }
 
 // Return a slice containing all the keys the agent knows about
@@ -66,58 +69,164 @@ func KeyInSlice(key ssh.PublicKey, keys []ssh.PublicKey) bool {
 	return false
 }
 
-// Finds the keys common to the user/org and the local SSH agent
-func commonKeys(login string) (keys []ssh.PublicKey) {
+var (
+	ErrIdentityNotFound = errors.New("github user or organization not found")
+	ErrNoMatchingKeys   = errors.New("no keys are in both the SSH agent and github")
+)
+
+// Finds the keys in the local SSH agent and those github lists for the
+// user/org
+func AgentAndGithubKeys(login string) (aks []ssh.PublicKey, gks []ssh.PublicKey, err error) {
 	user, err := github.UserFor(login)
-	if err != nil {
-		return []ssh.PublicKey{}
+	if github.IsNotFound(err) {
+		return nil, nil, ErrIdentityNotFound
+	} else if err != nil {
+		return nil, nil, err
 	}
 
-	aks := agentKeys()
-	gks := github.GithubKeysFor(user)
+	return agentKeys(), github.GithubKeysFor(user), nil
+}
 
-	if len(aks) > len(gks) {
-		keys = make([]ssh.PublicKey, 0, len(aks))
-	} else {
-		keys = make([]ssh.PublicKey, 0, len(gks))
-	}
+func PubKeyToString(key ssh.PublicKey) string {
+	return key.Type() + " " + base64.StdEncoding.EncodeToString(key.Marshal())
+}
+
+// Restricts which keys are used to sign
+type SignOptions struct {
+	// Only sign with keys with these SHA256 fingerprints
+	KeyFingerprints []string
+
+	// Sign with at most this many keys. Zero means no limit.
+	MaxSignatures int
+}
+
+// A key in the SSH agent or on github, and why it will not be used to sign
+type KeyChoice struct {
+	Key      ssh.PublicKey
+	InAgent  bool
+	OnGithub bool
+
+	// Set when Key is a certificate in the agent. Certificates sign without
+	// being on github, when the server trusts their authority.
+	Certificate *ssh.Certificate
+
+	// Empty when the key will be used
+	Excluded string
+}
+
+// Decide which keys to sign for login with, agent keys first in the order the
+// agent lists them, then keys that are only on github
+func ChooseKeys(login string, aks []ssh.PublicKey, gks []ssh.PublicKey, opts SignOptions) []KeyChoice {
+	choices := []KeyChoice{}
+	used := 0
+	now := uint64(time.Now().Unix())
 
 	for _, k := range aks {
-		if KeyInSlice(k, gks) {
-			keys = append(keys, k)
+		choice := KeyChoice{Key: k, InAgent: true, OnGithub: KeyInSlice(k, gks)}
+		choice.Certificate, _ = k.(*ssh.Certificate)
+		cert := choice.Certificate
+		policyErr := models.Algorithms.CheckKey(k)
+
+		switch {
+		case cert != nil && (cert.CertType != ssh.UserCert || !contains(cert.ValidPrincipals, login)):
+			choice.Excluded = "certificate does not name " + login + " as a principal"
+		case cert != nil && (now < cert.ValidAfter || now >= cert.ValidBefore):
+			choice.Excluded = "certificate is not valid now"
+		case cert == nil && !choice.OnGithub:
+			choice.Excluded = "not registered on github"
+		case policyErr != nil:
+			choice.Excluded = "rejected by the algorithm policy: " + policyErr.Message
+		case len(opts.KeyFingerprints) > 0 && !hasFingerprint(k, opts.KeyFingerprints):
+			choice.Excluded = "not selected by fingerprint"
+		case opts.MaxSignatures > 0 && used >= opts.MaxSignatures:
+			choice.Excluded = fmt.Sprintf("over the limit of %d signatures", opts.MaxSignatures)
+		default:
+			used++
 		}
+		choices = append(choices, choice)
 	}
 
-	return keys
-}
+	for _, k := range gks {
+		if !KeyInSlice(k, aks) {
+			choices = append(choices, KeyChoice{Key: k, OnGithub: true, Excluded: "not in the SSH agent"})
+		}
+	}
 
-func PubKeyToString(key ssh.PublicKey) string {
-	return key.Type() + " " + base64.StdEncoding.EncodeToString(key.Marshal())
+	return choices
 }
 
 // Sign data using any keys that can be found localy and remotely for the given user or org
 func Sign(name string, data []byte) (sigs []*models.Signature) {
+	sigs, _ = SignWith(name, data, SignOptions{})
+	return sigs
+}
+
+// Sign data like Sign, using only the keys opts allows. Fails with
+// ErrIdentityNotFound or ErrNoMatchingKeys when there is nothing to sign with.
+func SignWith(name string, data []byte, opts SignOptions) (sigs []*models.Signature, err error) {
 	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
 	if err != nil {
 		log.Printf("Error connecting to SSH agent: %s\n", err.Error())
-		return []*models.Signature{}
+		return nil, err
 	}
 
 	defer conn.Close()
 
-	ag := agent.NewClient(conn)
-	keys := commonKeys(name)
-	sigs = make([]*models.Signature, 0, len(keys))
+	aks, gks, err := AgentAndGithubKeys(name)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, key := range keys {
-		sig, err := ag.Sign(key, data)
+	sigs = []*models.Signature{}
+	for _, choice := range ChooseKeys(name, aks, gks, opts) {
+		if len(choice.Excluded) > 0 {
+			continue
+		}
+
+		sig, err := sshkeys.AgentSign(conn, choice.Key, data)
 		if err != nil {
-			log.Printf("Error signing data with key %q: %s\n", key.Marshal(), err.Error())
-		} else {
-			blob := base64.StdEncoding.EncodeToString(sig.Blob)
-			sigs = append(sigs, &models.Signature{Blob: &blob, Format: &sig.Format, Key: &models.PublicKey{key}})
+			log.Printf("Error signing data with key %s: %s\n", models.Fingerprint(choice.Key), err.Error())
+			continue
+		}
+
+		blob := base64.StdEncoding.EncodeToString(sig.Blob)
+		signature := &models.Signature{Blob: &blob, Format: &sig.Format, Key: &models.PublicKey{PublicKey: choice.Key}}
+		if choice.Certificate != nil {
+			signature.Key = &models.PublicKey{PublicKey: choice.Certificate.Key}
+			signature.Certificate = &models.PublicKey{PublicKey: choice.Certificate}
 		}
+		sigs = append(sigs, signature)
 	}
 
-	return sigs
+	if len(sigs) == 0 {
+		return nil, ErrNoMatchingKeys
+	}
+	return sigs, nil
+}
+
+// Check a key, or a certificate's key, against fingerprints, with or without
+// the SHA256: prefix
+func hasFingerprint(key ssh.PublicKey, fingerprints []string) bool {
+	candidates := []string{models.Fingerprint(key)}
+	if cert, ok := key.(*ssh.Certificate); ok {
+		candidates = append(candidates, models.Fingerprint(cert.Key))
+	}
+
+	for _, f := range fingerprints {
+		for _, fingerprint := range candidates {
+			if f == fingerprint || "SHA256:"+f == fingerprint {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+)
+
+func TestChooseKeysExcludesWeakRSAKeys(t *testing.T) {
+	key := func(bits int) ssh.PublicKey {
+		priv, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := ssh.NewPublicKey(&priv.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pub
+	}
+	weak, strong := key(1024), key(2048)
+	keys := []ssh.PublicKey{weak, strong}
+
+	choices := ChooseKeys("octocat", keys, keys, SignOptions{})
+	if len(choices) != 2 {
+		t.Fatalf("got %d choices, want 2", len(choices))
+	}
+
+	if !strings.HasPrefix(choices[0].Excluded, "rejected by the algorithm policy: RSA keys must be at least 2048 bits") {
+		t.Errorf("weak key excluded with %q", choices[0].Excluded)
+	}
+	if choices[1].Excluded != "" {
+		t.Errorf("strong key excluded with %q", choices[1].Excluded)
+	}
+}
@@ -0,0 +1,256 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/translog"
+	"io/ioutil"
+	"time"
+)
+
+// Bundles are version 2 since they carry the message's log entry. Version 1
+// bundles still load, but fail verification with ErrNotLogged.
+const BundleVersion = 2
+
+var (
+	ErrArtifactMismatch = errors.New("artifact does not match the signed message")
+	ErrNotLogged        = errors.New("bundle has no log entry for the message")
+	ErrLogEntryMismatch = errors.New("bundled log entry is not for the bundled message")
+	ErrLogEntryInvalid  = errors.New("bundled log entry did not verify")
+	ErrNoLogKey         = errors.New("no log key to check the bundled log entry against")
+)
+
+// Everything needed to verify a message with no network access: the message
+// as the server stored it, including its timestamp token, the signer's keys
+// when the bundle was made, and the message's log entry with its inclusion
+// proof and the signed checkpoint the proof is against
+type Bundle struct {
+	Version       int                 `json:"version"`
+	Message       *models.Message     `json:"message"`
+	Keys          []*models.PublicKey `json:"keys"`
+	KeysFetchedAt time.Time           `json:"keys_fetched_at"`
+	CreatedAt     time.Time           `json:"created_at"`
+
+	// Certificate authorities trusted for the signer, if any
+	CertificateAuthorities []*models.PublicKey `json:"certificate_authorities,omitempty"`
+
+	LogEntry *models.LogEntry `json:"log_entry,omitempty"`
+
+	// The PEM key of the log when the bundle was made. It is informational:
+	// anyone forging a bundle could replace it, so entries are only checked
+	// against a key the verifier pins.
+	LogPublicKey string `json:"log_public_key,omitempty"`
+}
+
+// Bundle a message with the keys keySource currently trusts for its signer
+func NewBundle(ctx context.Context, message *models.Message, keySource KeySource) (*Bundle, error) {
+	if message.GithubLogin == nil {
+		return nil, errors.New("message must have a github_login")
+	}
+
+	keys, err := keySource.Keys(ctx, *message.GithubLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{Version: BundleVersion, Message: message, KeysFetchedAt: time.Now().UTC()}
+	for _, k := range keys {
+		bundle.Keys = append(bundle.Keys, &models.PublicKey{PublicKey: k})
+	}
+
+	if caSource, ok := keySource.(CASource); ok {
+		cas, err := caSource.CertificateAuthorities(ctx, *message.GithubLogin)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range cas {
+			bundle.CertificateAuthorities = append(bundle.CertificateAuthorities, &models.PublicKey{PublicKey: k})
+		}
+	}
+
+	bundle.CreatedAt = bundle.KeysFetchedAt
+	return bundle, nil
+}
+
+func LoadBundle(filename string) (*Bundle, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{}
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, err
+	}
+
+	if bundle.Version != 1 && bundle.Version != BundleVersion {
+		return nil, errors.New("unsupported bundle version")
+	}
+	if bundle.Message == nil || bundle.Message.GithubLogin == nil || bundle.Message.Blob == nil {
+		return nil, errors.New("bundle is missing its message")
+	}
+	return bundle, nil
+}
+
+// The snapshot of keys and certificate authorities stored in the bundle
+func (bundle *Bundle) KeySource() KeySource {
+	login := *bundle.Message.GithubLogin
+	return WithCAs{
+		KeySource: Snapshot{login: unwrapKeys(bundle.Keys)},
+		CAs:       Snapshot{login: unwrapKeys(bundle.CertificateAuthorities)},
+	}
+}
+
+// Drop the bundled keys that are not pinned for the signer in ks, returning
+// them. The bundle's keys come from whoever made it, so once a signer is
+// pinned only pinned keys are trusted. A signer with no pins keeps every key.
+func (bundle *Bundle) RestrictToPins(ks *KnownSigners) (dropped []ssh.PublicKey) {
+	pinned, ok := ks.Pinned(*bundle.Message.GithubLogin)
+	if !ok {
+		return nil
+	}
+
+	kept := []*models.PublicKey{}
+	for _, k := range bundle.Keys {
+		if k.In(pinned) {
+			kept = append(kept, k)
+		} else {
+			dropped = append(dropped, k.PublicKey)
+		}
+	}
+	bundle.Keys = kept
+	return dropped
+}
+
+func unwrapKeys(keys []*models.PublicKey) []ssh.PublicKey {
+	out := make([]ssh.PublicKey, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k.PublicKey)
+	}
+	return out
+}
+
+// Check that artifact is exactly the signed blob and that the log whose key
+// is logKey included the message, then verify the message against the
+// bundled keys
+func (bundle *Bundle) Verify(ctx context.Context, artifact []byte, logKey *ecdsa.PublicKey, policy Policy) (Result, error) {
+	blob, err := base64.StdEncoding.DecodeString(*bundle.Message.Blob)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if !bytes.Equal(blob, artifact) {
+		return Result{}, ErrArtifactMismatch
+	}
+
+	if err := bundle.VerifyLogEntry(logKey); err != nil {
+		return Result{}, err
+	}
+
+	return Verify(ctx, bundle.Message, bundle.KeySource(), policy)
+}
+
+// Check that the bundled log entry holds the bundled message, that the log
+// whose key is logKey signed its entry timestamp and checkpoint, and that the
+// inclusion proof places the entry in the checkpoint's tree
+func (bundle *Bundle) VerifyLogEntry(logKey *ecdsa.PublicKey) error {
+	entry := bundle.LogEntry
+	if entry == nil || entry.Verification == nil || entry.Verification.InclusionProof == nil {
+		return ErrNotLogged
+	}
+	proof := entry.Verification.InclusionProof
+
+	if logKey == nil {
+		return ErrNoLogKey
+	}
+
+	spec, err := entry.Spec()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrLogEntryInvalid, err.Error())
+	}
+	if spec.Message == nil || !sameMessage(spec.Message, bundle.Message) {
+		return ErrLogEntryMismatch
+	}
+
+	logID, err := translog.LogID(logKey)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrLogEntryInvalid, err.Error())
+	}
+	if entry.LogID != logID {
+		return fmt.Errorf("%w: entry is from log %s, not %s", ErrLogEntryInvalid, entry.LogID, logID)
+	}
+	if err := translog.VerifyEntryTimestamp(logKey, entry.Verification.SignedEntryTimestamp, entry.Body, entry.IntegratedTime, entry.LogID, entry.LogIndex); err != nil {
+		return fmt.Errorf("%w: signed entry timestamp: %s", ErrLogEntryInvalid, err.Error())
+	}
+
+	cp, err := translog.ParseCheckpoint(proof.Checkpoint, logKey)
+	if err != nil {
+		return fmt.Errorf("%w: checkpoint: %s", ErrLogEntryInvalid, err.Error())
+	}
+	if cp.Size != proof.TreeSize || hex.EncodeToString(cp.RootHash) != proof.RootHash || proof.LogIndex != entry.LogIndex {
+		return fmt.Errorf("%w: inclusion proof does not match its checkpoint", ErrLogEntryInvalid)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrLogEntryInvalid, err.Error())
+	}
+	hashes := make([][]byte, len(proof.Hashes))
+	for i, h := range proof.Hashes {
+		if hashes[i], err = hex.DecodeString(h); err != nil {
+			return fmt.Errorf("%w: %s", ErrLogEntryInvalid, err.Error())
+		}
+	}
+	if err := translog.VerifyInclusion(entry.LogIndex, cp.Size, translog.LeafHash(body), hashes, cp.RootHash); err != nil {
+		return fmt.Errorf("%w: inclusion proof: %s", ErrLogEntryInvalid, err.Error())
+	}
+	return nil
+}
+
+// Whether the logged message is the bundled one: the same signer, title,
+// signed data, signatures and timestamp token
+func sameMessage(logged *models.Message, message *models.Message) bool {
+	if logged.ID == nil || message.ID == nil || *logged.ID != *message.ID ||
+		!equalStrings(logged.GithubLogin, message.GithubLogin) || !equalStrings(logged.Title, message.Title) ||
+		!equalStrings(logged.Blob, message.Blob) || !equalStrings(logged.TimestampToken, message.TimestampToken) {
+		return false
+	}
+
+	var err error
+	if logged.RawBlob, err = base64.StdEncoding.DecodeString(*logged.Blob); err != nil {
+		return false
+	}
+	if message.RawBlob, err = base64.StdEncoding.DecodeString(*message.Blob); err != nil {
+		return false
+	}
+	if !bytes.Equal(logged.SignedData(), message.SignedData()) || len(logged.Signatures) != len(message.Signatures) {
+		return false
+	}
+
+	for i, sig := range logged.Signatures {
+		other := message.Signatures[i]
+		if !equalStrings(sig.Format, other.Format) || !equalStrings(sig.Blob, other.Blob) || !sameKey(sig.Key, other.Key) || !sameKey(sig.Certificate, other.Certificate) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameKey(a *models.PublicKey, b *models.PublicKey) bool {
+	if a == nil || a.PublicKey == nil || b == nil || b.PublicKey == nil {
+		return (a == nil || a.PublicKey == nil) && (b == nil || b.PublicKey == nil)
+	}
+	return bytes.Equal(a.Marshal(), b.Marshal())
+}
+
+func equalStrings(a *string, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
@@ -0,0 +1,168 @@
+package verify
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/sshkeys"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// A KeySource supplies the keys that are trusted to sign for a login
+type KeySource interface {
+	Keys(ctx context.Context, login string) ([]ssh.PublicKey, error)
+}
+
+// Implemented by key sources that also trust OpenSSH certificate
+// authorities to certify keys for a login
+type CASource interface {
+	CertificateAuthorities(ctx context.Context, login string) ([]ssh.PublicKey, error)
+}
+
+// The certificate authorities of CAs, by login, alongside the keys of a
+// KeySource
+type WithCAs struct {
+	KeySource
+	CAs Snapshot
+}
+
+func (w WithCAs) CertificateAuthorities(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	return w.CAs[login], nil
+}
+
+// The keys github currently lists for a user, or for every admin of an
+// organization
+type GithubKeys struct{}
+
+func (GithubKeys) Keys(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	user, err := github.UserFor(login)
+	if err != nil {
+		return nil, err
+	}
+	return github.KeysFor(user)
+}
+
+// Keys recorded ahead of time, by login
+type Snapshot map[string][]ssh.PublicKey
+
+func (snapshot Snapshot) Keys(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	keys, ok := snapshot[login]
+	if !ok {
+		return nil, fmt.Errorf("no keys recorded for %q", login)
+	}
+	return keys, nil
+}
+
+// The same keys, whatever the login
+type PinnedKeys []ssh.PublicKey
+
+func (pinned PinnedKeys) Keys(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	return pinned, nil
+}
+
+type allowedSigner struct {
+	principals    []string
+	key           ssh.PublicKey
+	certAuthority bool
+}
+
+// Keys from an OpenSSH allowed_signers file, where each line is a comma
+// separated list of principal patterns followed by a key. Logins are matched
+// against the principals. Lines with the cert-authority option are trusted
+// as certificate authorities rather than as keys.
+type AllowedSigners []allowedSigner
+
+func ParseAllowedSigners(r io.Reader) (AllowedSigners, error) {
+	signers := AllowedSigners{}
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		i := strings.IndexAny(line, " \t")
+		if i == -1 {
+			return nil, fmt.Errorf("allowed_signers line %d: missing key", n)
+		}
+
+		key, _, options, _, err := sshkeys.ParseAuthorizedKey([]byte(line[i:]))
+		if err != nil {
+			return nil, fmt.Errorf("allowed_signers line %d: %s", n, err.Error())
+		}
+
+		principals := strings.Split(strings.Trim(line[:i], `"`), ",")
+		signers = append(signers, allowedSigner{principals: principals, key: key, certAuthority: hasOption(options, "cert-authority")})
+	}
+
+	return signers, scanner.Err()
+}
+
+func LoadAllowedSigners(filename string) (AllowedSigners, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseAllowedSigners(f)
+}
+
+func (signers AllowedSigners) Keys(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	keys, cas := signers.matching(login)
+	if len(keys) == 0 && len(cas) == 0 {
+		return nil, errors.New("no allowed signers match " + login)
+	}
+	return keys, nil
+}
+
+func (signers AllowedSigners) CertificateAuthorities(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	_, cas := signers.matching(login)
+	return cas, nil
+}
+
+func (signers AllowedSigners) matching(login string) (keys []ssh.PublicKey, cas []ssh.PublicKey) {
+	for _, signer := range signers {
+		if !principalMatches(signer.principals, login) {
+			continue
+		}
+
+		if signer.certAuthority {
+			cas = append(cas, signer.key)
+		} else {
+			keys = append(keys, signer.key)
+		}
+	}
+	return keys, cas
+}
+
+func hasOption(options []string, name string) bool {
+	for _, option := range options {
+		if strings.EqualFold(option, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check login against principal patterns, where a leading ! negates
+func principalMatches(patterns []string, login string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		if ok, _ := path.Match(strings.TrimPrefix(pattern, "!"), login); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
@@ -0,0 +1,169 @@
+package verify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"strings"
+	"testing"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// Whether keys holds exactly want, in any order
+func sameKeys(keys []ssh.PublicKey, want ...ssh.PublicKey) bool {
+	if len(keys) != len(want) {
+		return false
+	}
+	for _, k := range want {
+		if !(models.PublicKey{PublicKey: k}).In(keys) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPrincipalMatches(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		login    string
+		matches  bool
+	}{
+		{[]string{"octocat"}, "octocat", true},
+		{[]string{"octocat"}, "hubot", false},
+		{[]string{"hubot", "octocat"}, "octocat", true},
+		{[]string{"*"}, "octocat", true},
+		{[]string{"octo*"}, "octocat", true},
+		{[]string{"octo?at"}, "octocat", true},
+		{[]string{"octo?at"}, "octoat", false},
+		{[]string{"*", "!mallory"}, "octocat", true},
+		{[]string{"*", "!mallory"}, "mallory", false},
+		{[]string{"!mallory", "*"}, "mallory", false},
+		{[]string{"*", "!mal*"}, "malware", false},
+		{[]string{"!mallory"}, "octocat", false},
+		{[]string{"!mallory"}, "mallory", false},
+		{[]string{}, "octocat", false},
+	}
+
+	for _, test := range tests {
+		if matches := principalMatches(test.patterns, test.login); matches != test.matches {
+			t.Errorf("principalMatches(%q, %q) is %v, want %v", test.patterns, test.login, matches, test.matches)
+		}
+	}
+}
+
+func TestParseAllowedSigners(t *testing.T) {
+	octocat, hubot, ca, anyone := newSigner(t).PublicKey(), newSigner(t).PublicKey(), newSigner(t).PublicKey(), newSigner(t).PublicKey()
+
+	file := strings.Join([]string{
+		"# signers for the release process",
+		"",
+		"octocat " + authorizedKey(octocat),
+		`"octocat,hubot" namespaces="git" ` + authorizedKey(hubot),
+		"octocat,hubot cert-authority " + authorizedKey(ca),
+		"*,!octocat,!hubot " + authorizedKey(anyone),
+	}, "\n")
+
+	signers, err := ParseAllowedSigners(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		login string
+		keys  []ssh.PublicKey
+		cas   []ssh.PublicKey
+	}{
+		{"octocat", []ssh.PublicKey{octocat, hubot}, []ssh.PublicKey{ca}},
+		{"hubot", []ssh.PublicKey{hubot}, []ssh.PublicKey{ca}},
+		{"mallory", []ssh.PublicKey{anyone}, nil},
+	}
+
+	for _, test := range tests {
+		keys, err := signers.Keys(context.Background(), test.login)
+		if err != nil {
+			t.Fatalf("%s: %s", test.login, err)
+		}
+		if !sameKeys(keys, test.keys...) {
+			t.Errorf("%s: keys are %d keys, want %d", test.login, len(keys), len(test.keys))
+		}
+
+		cas, err := signers.CertificateAuthorities(context.Background(), test.login)
+		if err != nil {
+			t.Fatalf("%s: %s", test.login, err)
+		}
+		if !sameKeys(cas, test.cas...) {
+			t.Errorf("%s: certificate authorities are %d keys, want %d", test.login, len(cas), len(test.cas))
+		}
+	}
+}
+
+func TestAllowedSignersOnlyAuthorities(t *testing.T) {
+	ca := newSigner(t).PublicKey()
+
+	signers, err := ParseAllowedSigners(strings.NewReader("octocat cert-authority " + authorizedKey(ca)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A login trusted only through an authority still has an entry
+	if keys, err := signers.Keys(context.Background(), "octocat"); err != nil || len(keys) != 0 {
+		t.Errorf("keys are %v, %v, want none and no error", keys, err)
+	}
+	if _, err := signers.Keys(context.Background(), "hubot"); err == nil {
+		t.Error("expected an error for a login no line matches")
+	}
+}
+
+func TestParseAllowedSignersErrors(t *testing.T) {
+	key := authorizedKey(newSigner(t).PublicKey())
+
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{"principals only", "octocat\n", "line 1: missing key"},
+		{"not a key", "# comment\noctocat ssh-ed25519 !!!\n", "line 2"},
+		{"truncated key", "octocat " + key[:len(key)-20] + "\n", "line 1"},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseAllowedSigners(strings.NewReader(test.file)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error is %v, want one containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestWithCAs(t *testing.T) {
+	key, ca := newSigner(t).PublicKey(), newSigner(t).PublicKey()
+	source := WithCAs{KeySource: Snapshot{"octocat": {key}}, CAs: Snapshot{"octocat": {ca}}}
+
+	if keys, err := source.Keys(context.Background(), "octocat"); err != nil || !sameKeys(keys, key) {
+		t.Errorf("keys are %v, %v", keys, err)
+	}
+	if cas, err := source.CertificateAuthorities(context.Background(), "octocat"); err != nil || !sameKeys(cas, ca) {
+		t.Errorf("certificate authorities are %v, %v", cas, err)
+	}
+	if cas, err := source.CertificateAuthorities(context.Background(), "hubot"); err != nil || len(cas) != 0 {
+		t.Errorf("certificate authorities for another login are %v, %v, want none", cas, err)
+	}
+}
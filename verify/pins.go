@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrPinsChanged = errors.New("signer's keys have changed since they were pinned")
+	ErrNoKeysToPin = errors.New("signer has no keys to pin")
+)
+
+// Keys pinned per login, like ssh's known_hosts. The file holds one
+// "login key" line per key, which is also a valid allowed_signers file.
+type KnownSigners struct {
+	path string
+	keys map[string][]ssh.PublicKey
+}
+
+// Load pins from path. A missing file has no pins.
+func LoadKnownSigners(path string) (*KnownSigners, error) {
+	ks := &KnownSigners{path: path, keys: map[string][]ssh.PublicKey{}}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ks, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	signers, err := ParseAllowedSigners(f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, signer := range signers {
+		login := signer.principals[0]
+		ks.keys[login] = append(ks.keys[login], signer.key)
+	}
+	return ks, nil
+}
+
+// Write the pins back to the file they were loaded from
+func (ks *KnownSigners) Save() error {
+	buf := bytes.Buffer{}
+	for _, login := range ks.Logins() {
+		for _, key := range ks.keys[login] {
+			buf.WriteString(login + " ")
+			buf.Write(ssh.MarshalAuthorizedKey(key))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ks.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := ks.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.path)
+}
+
+func (ks *KnownSigners) Logins() []string {
+	logins := make([]string, 0, len(ks.keys))
+	for login := range ks.keys {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	return logins
+}
+
+// The keys pinned for login, and whether there are any
+func (ks *KnownSigners) Pinned(login string) ([]ssh.PublicKey, bool) {
+	keys, ok := ks.keys[login]
+	return keys, ok
+}
+
+// Replace login's pins with keys. An empty set of keys is never pinned, since
+// it would make every later key look like a change.
+func (ks *KnownSigners) Pin(login string, keys []ssh.PublicKey) error {
+	if len(keys) == 0 {
+		return ErrNoKeysToPin
+	}
+	ks.keys[login] = keys
+	return nil
+}
+
+// Forget login's pins, reporting whether there were any
+func (ks *KnownSigners) Remove(login string) bool {
+	_, ok := ks.keys[login]
+	delete(ks.keys, login)
+	return ok
+}
+
+// A KeySource that pins a login's keys the first time they are seen, and
+// afterwards trusts only pinned keys that Source still returns
+type TOFU struct {
+	Source KeySource
+	Store  *KnownSigners
+
+	// Fail with ErrPinsChanged rather than continuing when keys change
+	Strict bool
+
+	// Called when Source returns a different set of keys than were pinned
+	OnChange func(login string, added []ssh.PublicKey, removed []ssh.PublicKey)
+}
+
+func (tofu TOFU) Keys(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	live, err := tofu.Source.Keys(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are only pinned once the lookup has succeeded and found some
+	pinned, ok := tofu.Store.Pinned(login)
+	if !ok {
+		if err := tofu.Store.Pin(login, live); err == ErrNoKeysToPin {
+			return live, nil
+		}
+		return live, tofu.Store.Save()
+	}
+
+	trusted, added, removed := diffKeys(pinned, live)
+	if len(added) > 0 || len(removed) > 0 {
+		if tofu.OnChange != nil {
+			tofu.OnChange(login, added, removed)
+		}
+		if tofu.Strict {
+			return nil, ErrPinsChanged
+		}
+	}
+
+	return trusted, nil
+}
+
+// The certificate authorities of Source, which are not pinned
+func (tofu TOFU) CertificateAuthorities(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	if caSource, ok := tofu.Source.(CASource); ok {
+		return caSource.CertificateAuthorities(ctx, login)
+	}
+	return nil, nil
+}
+
+// Split keys into those in both pinned and live, only in live, and only in
+// pinned
+func diffKeys(pinned []ssh.PublicKey, live []ssh.PublicKey) (both []ssh.PublicKey, added []ssh.PublicKey, removed []ssh.PublicKey) {
+	for _, k := range live {
+		if (models.PublicKey{PublicKey: k}).In(pinned) {
+			both = append(both, k)
+		} else {
+			added = append(added, k)
+		}
+	}
+
+	for _, k := range pinned {
+		if !(models.PublicKey{PublicKey: k}).In(live) {
+			removed = append(removed, k)
+		}
+	}
+	return both, added, removed
+}
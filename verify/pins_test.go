@@ -0,0 +1,206 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A KeySource whose keys can be changed between lookups
+type liveKeys struct {
+	keys map[string][]ssh.PublicKey
+	err  error
+}
+
+func (live *liveKeys) Keys(ctx context.Context, login string) ([]ssh.PublicKey, error) {
+	return live.keys[login], live.err
+}
+
+func tempKnownSigners(t *testing.T) (*KnownSigners, string) {
+	path := filepath.Join(t.TempDir(), "config", "known_signers")
+	ks, err := LoadKnownSigners(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks, path
+}
+
+func TestKnownSignersSaveAndLoad(t *testing.T) {
+	ks, path := tempKnownSigners(t)
+	a, b, c := newSigner(t).PublicKey(), newSigner(t).PublicKey(), newSigner(t).PublicKey()
+
+	if len(ks.Logins()) != 0 {
+		t.Fatalf("a missing file has pins for %v", ks.Logins())
+	}
+	if err := ks.Pin("octocat", nil); err != ErrNoKeysToPin {
+		t.Errorf("pinning no keys: error is %v, want %v", err, ErrNoKeysToPin)
+	}
+	if err := ks.Pin("octocat", []ssh.PublicKey{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Pin("hubot", []ssh.PublicKey{c}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadKnownSigners(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logins := loaded.Logins(); len(logins) != 2 || logins[0] != "hubot" || logins[1] != "octocat" {
+		t.Fatalf("logins are %v", logins)
+	}
+	if keys, ok := loaded.Pinned("octocat"); !ok || !sameKeys(keys, a, b) {
+		t.Errorf("octocat's pins did not survive saving")
+	}
+
+	if !loaded.Remove("hubot") || loaded.Remove("hubot") {
+		t.Error("removing pins should report whether there were any")
+	}
+	if _, ok := loaded.Pinned("hubot"); ok {
+		t.Error("hubot is still pinned")
+	}
+}
+
+func TestDiffKeys(t *testing.T) {
+	a, b, c := newSigner(t).PublicKey(), newSigner(t).PublicKey(), newSigner(t).PublicKey()
+
+	tests := []struct {
+		name                 string
+		pinned, live         []ssh.PublicKey
+		both, added, removed []ssh.PublicKey
+	}{
+		{"unchanged", []ssh.PublicKey{a, b}, []ssh.PublicKey{b, a}, []ssh.PublicKey{a, b}, nil, nil},
+		{"added", []ssh.PublicKey{a}, []ssh.PublicKey{a, b}, []ssh.PublicKey{a}, []ssh.PublicKey{b}, nil},
+		{"removed", []ssh.PublicKey{a, b}, []ssh.PublicKey{a}, []ssh.PublicKey{a}, nil, []ssh.PublicKey{b}},
+		{"replaced", []ssh.PublicKey{a, b}, []ssh.PublicKey{b, c}, []ssh.PublicKey{b}, []ssh.PublicKey{c}, []ssh.PublicKey{a}},
+		{"all removed", []ssh.PublicKey{a}, nil, nil, nil, []ssh.PublicKey{a}},
+	}
+
+	for _, test := range tests {
+		both, added, removed := diffKeys(test.pinned, test.live)
+		if !sameKeys(both, test.both...) || !sameKeys(added, test.added...) || !sameKeys(removed, test.removed...) {
+			t.Errorf("%s: got %d in both, %d added and %d removed, want %d, %d and %d", test.name, len(both), len(added), len(removed), len(test.both), len(test.added), len(test.removed))
+		}
+	}
+}
+
+func TestTOFU(t *testing.T) {
+	a, b, c := newSigner(t).PublicKey(), newSigner(t).PublicKey(), newSigner(t).PublicKey()
+
+	tests := []struct {
+		name   string
+		pinned []ssh.PublicKey
+		live   []ssh.PublicKey
+		strict bool
+
+		trusted        []ssh.PublicKey
+		err            error
+		added, removed []ssh.PublicKey
+		changed        bool
+
+		// The pins afterwards
+		pins []ssh.PublicKey
+	}{
+		{name: "first use pins", live: []ssh.PublicKey{a, b}, trusted: []ssh.PublicKey{a, b}, pins: []ssh.PublicKey{a, b}},
+		{name: "first use with no keys pins nothing", trusted: nil},
+		{name: "unchanged", pinned: []ssh.PublicKey{a, b}, live: []ssh.PublicKey{a, b}, trusted: []ssh.PublicKey{a, b}, pins: []ssh.PublicKey{a, b}},
+		{
+			name: "added key is not trusted", pinned: []ssh.PublicKey{a}, live: []ssh.PublicKey{a, b},
+			trusted: []ssh.PublicKey{a}, changed: true, added: []ssh.PublicKey{b}, pins: []ssh.PublicKey{a},
+		},
+		{
+			name: "removed key is not trusted", pinned: []ssh.PublicKey{a, b}, live: []ssh.PublicKey{a},
+			trusted: []ssh.PublicKey{a}, changed: true, removed: []ssh.PublicKey{b}, pins: []ssh.PublicKey{a, b},
+		},
+		{
+			name: "replaced keys trust nothing", pinned: []ssh.PublicKey{a}, live: []ssh.PublicKey{c},
+			trusted: nil, changed: true, added: []ssh.PublicKey{c}, removed: []ssh.PublicKey{a}, pins: []ssh.PublicKey{a},
+		},
+		{
+			name: "strict fails on an added key", pinned: []ssh.PublicKey{a}, live: []ssh.PublicKey{a, b}, strict: true,
+			err: ErrPinsChanged, changed: true, added: []ssh.PublicKey{b}, pins: []ssh.PublicKey{a},
+		},
+		{
+			name: "strict fails on a removed key", pinned: []ssh.PublicKey{a, b}, live: []ssh.PublicKey{a}, strict: true,
+			err: ErrPinsChanged, changed: true, removed: []ssh.PublicKey{b}, pins: []ssh.PublicKey{a, b},
+		},
+		{name: "strict passes unchanged keys", pinned: []ssh.PublicKey{a}, live: []ssh.PublicKey{a}, strict: true, trusted: []ssh.PublicKey{a}, pins: []ssh.PublicKey{a}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ks, path := tempKnownSigners(t)
+			if test.pinned != nil {
+				if err := ks.Pin("octocat", test.pinned); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			changed := false
+			var added, removed []ssh.PublicKey
+			tofu := TOFU{
+				Source: &liveKeys{keys: map[string][]ssh.PublicKey{"octocat": test.live}},
+				Store:  ks,
+				Strict: test.strict,
+				OnChange: func(login string, a []ssh.PublicKey, r []ssh.PublicKey) {
+					changed, added, removed = true, a, r
+				},
+			}
+
+			trusted, err := tofu.Keys(context.Background(), "octocat")
+			if err != test.err {
+				t.Fatalf("error is %v, want %v", err, test.err)
+			}
+			if !sameKeys(trusted, test.trusted...) {
+				t.Errorf("%d keys trusted, want %d", len(trusted), len(test.trusted))
+			}
+			if changed != test.changed || !sameKeys(added, test.added...) || !sameKeys(removed, test.removed...) {
+				t.Errorf("OnChange called %v with %d added and %d removed, want %v with %d and %d", changed, len(added), len(removed), test.changed, len(test.added), len(test.removed))
+			}
+
+			pins, ok := ks.Pinned("octocat")
+			if ok != (test.pins != nil) || !sameKeys(pins, test.pins...) {
+				t.Errorf("%d keys pinned, want %d", len(pins), len(test.pins))
+			}
+
+			// Only a first use saves, since changes must be accepted
+			if _, err := os.Stat(path); os.IsNotExist(err) == (test.pinned == nil && test.pins != nil) {
+				t.Errorf("known signers saved: %v", !os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func TestTOFUSourceError(t *testing.T) {
+	ks, _ := tempKnownSigners(t)
+	lookupErr := errors.New("github is down")
+
+	tofu := TOFU{Source: &liveKeys{err: lookupErr}, Store: ks}
+	if _, err := tofu.Keys(context.Background(), "octocat"); err != lookupErr {
+		t.Fatalf("error is %v, want %v", err, lookupErr)
+	}
+	if _, ok := ks.Pinned("octocat"); ok {
+		t.Error("a failed lookup pinned keys")
+	}
+}
+
+func TestTOFUCertificateAuthorities(t *testing.T) {
+	ks, _ := tempKnownSigners(t)
+	key, ca := newSigner(t).PublicKey(), newSigner(t).PublicKey()
+
+	withCAs := TOFU{Source: WithCAs{KeySource: Snapshot{"octocat": {key}}, CAs: Snapshot{"octocat": {ca}}}, Store: ks}
+	if cas, err := withCAs.CertificateAuthorities(context.Background(), "octocat"); err != nil || !sameKeys(cas, ca) {
+		t.Errorf("certificate authorities are %v, %v", cas, err)
+	}
+
+	withoutCAs := TOFU{Source: Snapshot{"octocat": {key}}, Store: ks}
+	if cas, err := withoutCAs.CertificateAuthorities(context.Background(), "octocat"); err != nil || cas != nil {
+		t.Errorf("certificate authorities of a source without any are %v, %v", cas, err)
+	}
+}
@@ -0,0 +1,256 @@
+// Package verify checks signist messages against the keys of their signers,
+// without depending on the signist server or client.
+package verify
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/timestamp"
+	"time"
+)
+
+var (
+	ErrSignatureInvalid    = errors.New("a signature did not verify")
+	ErrNotEnoughSignatures = errors.New("not enough valid signatures")
+	ErrRevoked             = errors.New("message has been revoked")
+	ErrExpired             = errors.New("message has expired")
+	ErrNotYetValid         = errors.New("message is not valid yet")
+	ErrTimestampMissing    = errors.New("message has no timestamp token")
+	ErrTimestampInvalid    = errors.New("message's timestamp token did not verify")
+)
+
+// What a message must satisfy to be accepted
+type Policy struct {
+	// The time validity windows are checked at. Zero means now.
+	Now time.Time
+
+	// The number of signatures that must verify. Zero means one.
+	MinSignatures int
+
+	// The number of distinct keys a revocation must be signed by for it to
+	// be trusted. Zero means one.
+	MinRevocationSignatures int
+
+	// Accept messages that would otherwise fail for these reasons
+	AllowRevoked bool
+	AllowExpired bool
+
+	// Signature formats and keys to accept. Nil accepts any the signature
+	// verifies with, so that messages signed before a policy was adopted
+	// still verify.
+	Algorithms *models.AlgorithmPolicy
+
+	// Timestamp authority roots the message's RFC 3161 token must verify
+	// against. Nil does not check the token.
+	TimestampRoots *x509.CertPool
+}
+
+type SignatureResult struct {
+	Index       int
+	Key         ssh.PublicKey
+	Fingerprint string
+	Err         *models.Error
+}
+
+type Result struct {
+	// Whether the message satisfied the policy
+	Valid bool
+
+	Signatures      []SignatureResult
+	ValidSignatures int
+
+	Expired     bool
+	NotYetValid bool
+
+	Revoked    bool
+	Revocation *models.Revocation
+
+	// Whether the revocation's own signatures verified. An unverified
+	// revocation still fails verification.
+	RevocationVerified bool
+
+	// When the timestamp authority vouched the signatures existed, if the
+	// token was checked and verified, and why it did not verify otherwise
+	TimestampedAt  time.Time
+	TimestampError error
+}
+
+// Verify a message's signatures against the keys keySource trusts for the
+// message's GithubLogin. The result describes every signature. A non-nil
+// error means the message did not satisfy policy, or keys could not be found.
+func Verify(ctx context.Context, message *models.Message, keySource KeySource, policy Policy) (Result, error) {
+	result := Result{}
+	var err error
+
+	if message.GithubLogin == nil || message.Blob == nil {
+		return result, errors.New("message must have a github_login and blob")
+	}
+
+	signers := trust{login: *message.GithubLogin}
+	if signers.keys, err = keySource.Keys(ctx, signers.login); err != nil {
+		return result, err
+	}
+
+	if caSource, ok := keySource.(CASource); ok {
+		if signers.cas, err = caSource.CertificateAuthorities(ctx, signers.login); err != nil {
+			return result, err
+		}
+	}
+
+	if message.RawBlob == nil {
+		if message.RawBlob, err = base64.StdEncoding.DecodeString(*message.Blob); err != nil {
+			return result, err
+		}
+	}
+
+	now := policy.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	signers.at = now
+	if message.CreatedAt != nil {
+		signers.at = *message.CreatedAt
+	}
+	signers.algorithms = policy.Algorithms
+
+	// A verified timestamp is better evidence of when the message was
+	// signed than the server's own clock
+	var timestampErr error
+	if policy.TimestampRoots != nil {
+		result.TimestampedAt, result.TimestampError = verifyTimestamp(message, policy.TimestampRoots)
+		if result.TimestampError == nil {
+			signers.at = result.TimestampedAt
+		} else if message.TimestampToken == nil {
+			timestampErr = ErrTimestampMissing
+		} else {
+			timestampErr = ErrTimestampInvalid
+		}
+	}
+
+	result.Signatures, result.ValidSignatures = verifySignatures(message.Signatures, signers, message.SignedData())
+	message.CheckValidity(now)
+	result.Expired = message.Expired
+	result.NotYetValid = message.NotYetValid
+
+	if message.Revoked && message.Revocation != nil {
+		rev := message.Revocation
+		result.Revoked = true
+		result.Revocation = rev
+		// The revocation names the message by its digest, so a revocation
+		// copied from another message with the same ID does not verify
+		if rev.MessageID != nil && rev.Reason != nil && rev.MessageDigest != nil && message.Title != nil && *rev.MessageDigest == message.RevocationDigest() {
+			signers.at = now
+			if rev.CreatedAt != nil {
+				signers.at = *rev.CreatedAt
+			}
+			results, valid := verifySignatures(rev.Signatures, signers, models.RevocationData(*rev.MessageID, *rev.MessageDigest, *rev.Reason))
+
+			minRevocation := policy.MinRevocationSignatures
+			if minRevocation == 0 {
+				minRevocation = 1
+			}
+			result.RevocationVerified = valid == len(rev.Signatures) && distinctKeys(results) >= minRevocation
+		}
+	}
+
+	minSignatures := policy.MinSignatures
+	if minSignatures == 0 {
+		minSignatures = 1
+	}
+
+	switch {
+	case result.ValidSignatures < len(message.Signatures):
+		err = ErrSignatureInvalid
+	case result.ValidSignatures < minSignatures:
+		err = ErrNotEnoughSignatures
+	case timestampErr != nil:
+		err = timestampErr
+	case result.NotYetValid:
+		err = ErrNotYetValid
+	case result.Expired && !policy.AllowExpired:
+		err = ErrExpired
+	case result.Revoked && !policy.AllowRevoked:
+		err = ErrRevoked
+	}
+
+	result.Valid = err == nil
+	return result, err
+}
+
+// Check the message's timestamp token covers its signatures, returning the
+// time it vouches for
+func verifyTimestamp(message *models.Message, roots *x509.CertPool) (time.Time, error) {
+	if message.TimestampToken == nil {
+		return time.Time{}, ErrTimestampMissing
+	}
+
+	der, err := base64.StdEncoding.DecodeString(*message.TimestampToken)
+	if err != nil {
+		return time.Time{}, timestamp.ErrInvalidToken
+	}
+	token, err := timestamp.Parse(der)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return token.Verify(message.TimestampDigest(), roots)
+}
+
+// The number of different keys the valid signatures in results were made with
+func distinctKeys(results []SignatureResult) int {
+	seen := map[string]bool{}
+	for _, result := range results {
+		if result.Err == nil {
+			seen[result.Fingerprint] = true
+		}
+	}
+	return len(seen)
+}
+
+// The keys and certificate authorities trusted for a login
+type trust struct {
+	login string
+	keys  []ssh.PublicKey
+	cas   []ssh.PublicKey
+
+	// When certificates must have been valid
+	at time.Time
+
+	algorithms *models.AlgorithmPolicy
+}
+
+func verifySignatures(sigs []*models.Signature, signers trust, data []byte) (results []SignatureResult, valid int) {
+	results = make([]SignatureResult, len(sigs))
+
+	for i, sig := range sigs {
+		results[i].Index = i
+
+		err := sig.ValidatePresence()
+		if err == nil {
+			err = sig.ValidateBlob()
+		}
+		if err == nil && sig.Certificate != nil {
+			err = sig.VerifyCertificate(signers.cas, signers.login, signers.at, data)
+		} else if err == nil {
+			err = sig.Verify(signers.keys, data)
+		}
+		if err == nil && signers.algorithms != nil {
+			err = signers.algorithms.Check(sig)
+		}
+
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].Key = sig.Key.PublicKey
+		results[i].Fingerprint = models.Fingerprint(sig.Key.PublicKey)
+		valid++
+	}
+
+	return results, valid
+}
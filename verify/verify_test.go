@@ -0,0 +1,207 @@
+package verify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/andrewhamon/signist/Godeps/_workspace/src/golang.org/x/crypto/ssh"
+	"github.com/andrewhamon/signist/models"
+	"testing"
+	"time"
+)
+
+var signedAt = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func signature(t *testing.T, signer ssh.Signer, data []byte) *models.Signature {
+	sig, err := signer.Sign(rand.Reader, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := base64.StdEncoding.EncodeToString(sig.Blob)
+	return &models.Signature{Format: &sig.Format, Blob: &blob}
+}
+
+// A message from octocat signed by each of signers
+func signedMessage(t *testing.T, signers ...ssh.Signer) *models.Message {
+	return signedMessageWith(t, nil, signers...)
+}
+
+// A message from octocat, with signed attributes set by attrs, signed by each
+// of signers
+func signedMessageWith(t *testing.T, attrs func(*models.Message), signers ...ssh.Signer) *models.Message {
+	id, login, title := 1, "octocat", "release"
+	blob := base64.StdEncoding.EncodeToString([]byte("v1.0.0"))
+	createdAt := signedAt
+	message := &models.Message{Version: models.SignedDataV2, ID: &id, GithubLogin: &login, Title: &title, Blob: &blob, CreatedAt: &createdAt, RawBlob: []byte("v1.0.0")}
+	if attrs != nil {
+		attrs(message)
+	}
+
+	for _, signer := range signers {
+		message.Signatures = append(message.Signatures, signature(t, signer, message.SignedData()))
+	}
+	return message
+}
+
+// Revoke message with signatures from each of signers
+func revoke(t *testing.T, message *models.Message, signers ...ssh.Signer) *models.Message {
+	reason, digest := "key_compromise", message.RevocationDigest()
+	createdAt := signedAt.Add(time.Hour)
+	rev := &models.Revocation{MessageID: message.ID, GithubLogin: message.GithubLogin, Reason: &reason, MessageDigest: &digest, CreatedAt: &createdAt}
+
+	for _, signer := range signers {
+		rev.Signatures = append(rev.Signatures, signature(t, signer, models.RevocationData(*message.ID, digest, reason)))
+	}
+	message.Revoked, message.Revocation = true, rev
+	return message
+}
+
+func TestVerify(t *testing.T) {
+	a, b, stranger := newSigner(t), newSigner(t), newSigner(t)
+	keys := Snapshot{"octocat": {a.PublicKey(), b.PublicKey()}}
+	now := signedAt.Add(24 * time.Hour)
+
+	expiring := func(at time.Time) func(*models.Message) {
+		return func(m *models.Message) { m.ExpiresAt = &at }
+	}
+
+	tests := []struct {
+		name    string
+		message *models.Message
+		change  func(*models.Message)
+		policy  Policy
+
+		err                error
+		valid              int
+		revocationVerified bool
+	}{
+		{name: "one signature", message: signedMessage(t, a), valid: 1},
+		{name: "two signatures", message: signedMessage(t, a, b), policy: Policy{MinSignatures: 2}, valid: 2},
+		{name: "too few signatures", message: signedMessage(t, a), policy: Policy{MinSignatures: 2}, err: ErrNotEnoughSignatures, valid: 1},
+		{name: "a signature by another key", message: signedMessage(t, a, stranger), err: ErrSignatureInvalid, valid: 1},
+		{name: "no signatures", message: signedMessage(t), err: ErrNotEnoughSignatures},
+		{
+			name: "signed data changed", message: signedMessage(t, a), err: ErrSignatureInvalid,
+			change: func(m *models.Message) { m.RawBlob = []byte("v1.0.1") },
+		},
+		{name: "expired", message: signedMessageWith(t, expiring(now.Add(-time.Minute)), a), err: ErrExpired, valid: 1},
+		{name: "expired but allowed", message: signedMessageWith(t, expiring(now.Add(-time.Minute)), a), policy: Policy{AllowExpired: true}, valid: 1},
+		{name: "not expired yet", message: signedMessageWith(t, expiring(now.Add(time.Minute)), a), valid: 1},
+		{name: "expiry changed after signing", message: signedMessage(t, a), change: expiring(now.Add(time.Minute)), err: ErrSignatureInvalid},
+		{
+			name: "not yet valid", err: ErrNotYetValid, valid: 1,
+			message: signedMessageWith(t, func(m *models.Message) { notBefore := now.Add(time.Hour); m.NotBefore = &notBefore }, a),
+		},
+		{name: "revoked", message: revoke(t, signedMessage(t, a), a), err: ErrRevoked, valid: 1, revocationVerified: true},
+		{name: "revoked but allowed", message: revoke(t, signedMessage(t, a), a), policy: Policy{AllowRevoked: true}, valid: 1, revocationVerified: true},
+		{name: "revocation by another key", message: revoke(t, signedMessage(t, a), stranger), err: ErrRevoked, valid: 1},
+		{
+			name: "revocation below the threshold", message: revoke(t, signedMessage(t, a), a, a), err: ErrRevoked, valid: 1,
+			policy: Policy{MinRevocationSignatures: 2},
+		},
+		{
+			name: "revocation at the threshold", message: revoke(t, signedMessage(t, a), a, b), err: ErrRevoked, valid: 1, revocationVerified: true,
+			policy: Policy{MinRevocationSignatures: 2},
+		},
+		{
+			name: "revocation of another message", message: revoke(t, signedMessage(t, a), a), err: ErrRevoked, valid: 1,
+			change: func(m *models.Message) {
+				digest := signedMessage(t).RevocationDigest() + "0"
+				m.Revocation.MessageDigest = &digest
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.change != nil {
+				test.change(test.message)
+			}
+			test.policy.Now = now
+
+			result, err := Verify(context.Background(), test.message, keys, test.policy)
+			if err != test.err {
+				t.Fatalf("error is %v, want %v", err, test.err)
+			}
+			if result.Valid != (test.err == nil) {
+				t.Errorf("Valid is %v with error %v", result.Valid, err)
+			}
+			if result.ValidSignatures != test.valid {
+				t.Errorf("%d valid signatures, want %d", result.ValidSignatures, test.valid)
+			}
+			if result.RevocationVerified != test.revocationVerified {
+				t.Errorf("RevocationVerified is %v, want %v", result.RevocationVerified, test.revocationVerified)
+			}
+		})
+	}
+}
+
+func TestVerifyReportsEachSignature(t *testing.T) {
+	a, stranger := newSigner(t), newSigner(t)
+	message := signedMessage(t, stranger, a)
+
+	result, err := Verify(context.Background(), message, Snapshot{"octocat": {a.PublicKey()}}, Policy{Now: signedAt})
+	if err != ErrSignatureInvalid {
+		t.Fatalf("error is %v, want %v", err, ErrSignatureInvalid)
+	}
+	if len(result.Signatures) != 2 || result.Signatures[0].Err == nil || result.Signatures[1].Err != nil {
+		t.Fatalf("signature results are %+v", result.Signatures)
+	}
+	if result.Signatures[1].Fingerprint != models.Fingerprint(a.PublicKey()) {
+		t.Errorf("signature 1 verified with %s, want %s", result.Signatures[1].Fingerprint, models.Fingerprint(a.PublicKey()))
+	}
+}
+
+func TestVerifyUnknownSigner(t *testing.T) {
+	message := signedMessage(t, newSigner(t))
+	if _, err := Verify(context.Background(), message, Snapshot{}, Policy{}); err == nil {
+		t.Fatal("expected an error when the key source has no keys for the signer")
+	}
+}
+
+func TestVerifyCertificates(t *testing.T) {
+	ca, otherCA, user := newSigner(t), newSigner(t), newSigner(t)
+
+	certify := func(authority ssh.Signer, principal string) *models.PublicKey {
+		cert := &ssh.Certificate{
+			Key:             user.PublicKey(),
+			CertType:        ssh.UserCert,
+			ValidPrincipals: []string{principal},
+			ValidAfter:      uint64(signedAt.Add(-time.Hour).Unix()),
+			ValidBefore:     uint64(signedAt.Add(time.Hour).Unix()),
+		}
+		if err := cert.SignCert(rand.Reader, authority); err != nil {
+			t.Fatal(err)
+		}
+		return &models.PublicKey{PublicKey: cert}
+	}
+
+	tests := []struct {
+		name   string
+		cert   *models.PublicKey
+		source KeySource
+		err    error
+	}{
+		{"certified by a trusted authority", certify(ca, "octocat"), WithCAs{KeySource: Snapshot{"octocat": nil}, CAs: Snapshot{"octocat": {ca.PublicKey()}}}, nil},
+		{"certified by an untrusted authority", certify(otherCA, "octocat"), WithCAs{KeySource: Snapshot{"octocat": nil}, CAs: Snapshot{"octocat": {ca.PublicKey()}}}, ErrSignatureInvalid},
+		{"certified for another login", certify(ca, "hubot"), WithCAs{KeySource: Snapshot{"octocat": nil}, CAs: Snapshot{"octocat": {ca.PublicKey()}}}, ErrSignatureInvalid},
+		{"source without authorities", certify(ca, "octocat"), Snapshot{"octocat": {ca.PublicKey()}}, ErrSignatureInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := signedMessage(t, user)
+			message.Signatures[0].Certificate = test.cert
+
+			// Certificates are checked when the message was signed, long
+			// after they expired
+			result, err := Verify(context.Background(), message, test.source, Policy{Now: signedAt.Add(30 * 24 * time.Hour)})
+			if err != test.err {
+				t.Fatalf("error is %v, want %v", err, test.err)
+			}
+			if test.err == nil && result.Signatures[0].Fingerprint != models.Fingerprint(user.PublicKey()) {
+				t.Errorf("verified with %s, want the certified key", result.Signatures[0].Fingerprint)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/andrewhamon/signist/github"
+	"github.com/andrewhamon/signist/models"
+	"github.com/andrewhamon/signist/utils"
+	"time"
+)
+
+// Register a webhook for name's messages with titles starting with prefix
+func addWebhook(name string, url string, prefix string, opts utils.SignOptions) {
+	r := &report{Command: "webhooks add", Login: name}
+
+	// Leave time for signing with a security key before the request expires
+	expiresAt := time.Now().Add(models.MaxRequestValidity / 2).Truncate(time.Second)
+
+	sigs, err := utils.SignWith(name, models.WebhookData(name, url, prefix, expiresAt), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(sigs)
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	hook := models.Webhook{GithubLogin: &name, URL: &url, TitlePrefix: prefix, ExpiresAt: &expiresAt, Signatures: sigs}
+	created, err := apiClient().CreateWebhook(context.Background(), *user.ID, &hook)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.Webhooks = []*models.Webhook{created}
+
+	succeed(r, "Webhook %d registered for %s\nDeliveries are signed with the secret %s\nIt will not be shown again.\n", *created.ID, *created.URL, *created.Secret)
+}
+
+// List name's webhooks, which only name may see since receiver URLs often
+// embed credentials
+func listWebhooks(name string, opts utils.SignOptions) {
+	r := &report{Command: "webhooks list", Login: name}
+
+	expiresAt := time.Now().Add(models.MaxRequestValidity / 2).Truncate(time.Second)
+
+	sigs, err := utils.SignWith(name, models.WebhookListData(name, expiresAt), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(sigs)
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	lr := models.WebhookListRequest{GithubLogin: &name, ExpiresAt: &expiresAt, Signatures: sigs}
+	r.Webhooks, err = apiClient().ListWebhooks(context.Background(), *user.ID, &lr)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	if outputFormat() == "text" {
+		for _, hook := range r.Webhooks {
+			prefix := hook.TitlePrefix
+			if len(prefix) == 0 {
+				prefix = "(all titles)"
+			}
+			fmt.Printf("%d %s %s\n", *hook.ID, *hook.URL, prefix)
+		}
+	}
+	succeed(r, "")
+}
+
+func removeWebhook(name string, id int, opts utils.SignOptions) {
+	r := &report{Command: "webhooks remove", Login: name}
+
+	expiresAt := time.Now().Add(models.MaxRequestValidity / 2).Truncate(time.Second)
+
+	sigs, err := utils.SignWith(name, models.WebhookDeletionData(name, id, expiresAt), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(sigs)
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	del := models.WebhookDeletion{WebhookID: &id, GithubLogin: &name, ExpiresAt: &expiresAt, Signatures: sigs}
+	deleted, err := apiClient().DeleteWebhook(context.Background(), *user.ID, &del)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.Webhooks = []*models.Webhook{deleted}
+
+	succeed(r, "Webhook %d for %s removed\n", id, *deleted.URL)
+}
+
+// List a webhook's recent deliveries, which only the identity that
+// registered it may see
+func listWebhookDeliveries(name string, id int, opts utils.SignOptions) {
+	r := &report{Command: "webhooks deliveries", Login: name}
+
+	expiresAt := time.Now().Add(models.MaxRequestValidity / 2).Truncate(time.Second)
+
+	sigs, err := utils.SignWith(name, models.WebhookDeliveriesData(id, expiresAt), opts)
+	if err != nil {
+		failWith(r, err)
+	}
+	r.addSignatures(sigs)
+
+	user, err := github.UserFor(name)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	dr := models.WebhookDeliveriesRequest{WebhookID: &id, GithubLogin: &name, ExpiresAt: &expiresAt, Signatures: sigs}
+	r.Deliveries, err = apiClient().ListWebhookDeliveries(context.Background(), *user.ID, &dr)
+	if err != nil {
+		failWith(r, err)
+	}
+
+	if outputFormat() == "text" {
+		for _, d := range r.Deliveries {
+			line := fmt.Sprintf("%d %s %s %s attempts=%d", *d.ID, d.CreatedAt.Format(time.RFC3339), d.Event, d.Status, d.Attempts)
+			if d.ResponseStatus != nil {
+				line += fmt.Sprintf(" response=%d", *d.ResponseStatus)
+			}
+			if d.LastError != nil {
+				line += fmt.Sprintf(" error=%q", *d.LastError)
+			}
+			if d.NextAttemptAt != nil {
+				line += " next=" + d.NextAttemptAt.Format(time.RFC3339)
+			}
+			fmt.Println(line)
+		}
+	}
+	succeed(r, "")
+}